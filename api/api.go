@@ -4,10 +4,10 @@ import (
 	"database/sql"
 	"fmt"
 	_ "github.com/mattn/go-sqlite3"
+	"math"
+	"os"
 	"sync"
 	"time"
-
-	"github.com/golang/glog"
 )
 
 // -------------- API --------------
@@ -17,6 +17,15 @@ import (
 type TickRequest struct {
 	// The label (i.e. task) on which the user is currently working
 	Label string
+
+	// The time zone in which the tick occurred, as an IANA zone name (e.g.
+	// "America/New_York") or a UTC offset (e.g. "-07:00"). Optional: ticks with
+	// no zone are always rendered in the viewer's time zone
+	Zone string
+
+	// The client that sent the tick (e.g. "t", or an editor plugin's name).
+	// Optional, and only used to break down the ticks-received metric
+	Source string
 }
 
 // GetIntervalsRequest is the object sent to the /get-intervals endpoint.
@@ -25,6 +34,15 @@ type GetIntervalsRequest struct {
 	// If an interval in the result overlaps with 'Start' or 'End', it will be
 	// truncated.
 	Start, End int64
+
+	// If set, only ticks received by this host are used, so that one machine's
+	// view can be rebuilt from a merged DB (see Sync)
+	Host string
+
+	// If set, intervals are split wherever the label changes, and each interval
+	// in the response has a Label. The time between two consecutive ticks is
+	// attributed to the later tick's label
+	ByLabel bool
 }
 
 // Interval represents a time interval in which the caller was working. Used in
@@ -35,6 +53,10 @@ type Interval struct {
 	// The activity that was done in this interval (or "" if multiple activities
 	// may have occurred)
 	Label string
+
+	// The time zone in which this interval's first tick was recorded (or "" if
+	// unknown). See InRecordedZone
+	Zone string
 }
 
 // GetIntervalsResponse contains all activity intervals, clamped to the
@@ -44,11 +66,373 @@ type GetIntervalsResponse struct {
 	EndGap    int64
 }
 
+// TimeRange is a range of time, as seconds since epoch
+type TimeRange struct {
+	Start, End int64
+}
+
+// BatchIntervalsRequest is the object sent to the /intervals/batch endpoint,
+// to get the intervals in several ranges (e.g. each day of a week) at once.
+// The ranges are either listed in Ranges, or are [Start, Start+Step),
+// [Start+Step, Start+2*Step), etc. up to End
+type BatchIntervalsRequest struct {
+	Ranges           []TimeRange
+	Start, End, Step int64
+
+	// As in GetIntervalsRequest
+	Host    string
+	ByLabel bool
+}
+
+// IntervalsBucket holds the intervals in one range of a BatchIntervalsRequest,
+// clamped to the range
+type IntervalsBucket struct {
+	Start, End int64
+	Intervals  []Interval
+	Total      int64 // the total length of Intervals, in seconds
+}
+
+// BatchIntervalsResponse is returned by the /intervals/batch endpoint. It has
+// one bucket per requested range, in the order requested
+type BatchIntervalsResponse struct {
+	Buckets []IntervalsBucket
+	EndGap  int64 // as in GetIntervalsResponse
+}
+
+// TickRecord is a single tick as stored in a server's DB. Used in TickSet
+type TickRecord struct {
+	Time  int64 // the time at which the tick occurred, as seconds since epoch
+	Nanos int64 // the sub-second part of the tick's time, in nanoseconds
+	Label string
+	Zone  string // see TickRequest.Zone
+	Host  string // the host whose time-tracker server received the tick
+}
+
+// ListTicksRequest is the object sent to the /ticks endpoint (with GET)
+type ListTicksRequest struct {
+	// The ticks in [Start, End) (in seconds since epoch) are listed
+	Start, End int64
+
+	// If set, only ticks with this label (or received by this host) are listed
+	Label, Host string
+
+	// Cursor is "" to list the first page, or the NextCursor of the previous
+	// page to list the next one
+	Cursor string
+
+	// The most ticks to return (0 means DefaultTicksLimit). At most
+	// MaxTicksLimit ticks are returned
+	Limit int
+}
+
+// StoredTick is a tick as listed by ListTicks: a TickRecord and the id of its
+// row in the DB (which orders ticks that share a time)
+type StoredTick struct {
+	ID int64
+	TickRecord
+}
+
+// ListTicksResponse is returned by the /ticks endpoint (with GET)
+type ListTicksResponse struct {
+	Ticks []StoredTick
+
+	// If there are more ticks, NextCursor is the Cursor that lists them.
+	// Otherwise it's ""
+	NextCursor string
+}
+
+// ExportRequest is the object sent to the /export endpoint
+type ExportRequest struct {
+	// If set, only export ticks received by this host
+	Host string
+}
+
+// TickSet is a set of ticks exported from one server's DB (by /export), to be
+// merged into another's (by /sync). It's also the format of export files read
+// by 't sync --from'
+type TickSet struct {
+	Ticks []TickRecord
+}
+
+// SyncResponse is returned by the /sync endpoint
+type SyncResponse struct {
+	// The number of ticks that were added to the DB, and the number that were
+	// already present
+	Added, Duplicates int
+}
+
+// CreateTokenRequest is the object sent to the /tokens endpoint to create a
+// new bearer token for authenticating requests made over TCP
+type CreateTokenRequest struct {
+	// A human-readable description of the token (e.g. the client that uses it)
+	Name string
+
+	// In multi-user mode, the user whose data the token grants access to ("" is
+	// the server's owner)
+	User string
+}
+
+// CreateTokenResponse contains a newly-created bearer token. Only a hash of
+// the token is stored, so this is the only time the token can be retrieved
+type CreateTokenResponse struct {
+	Token string
+}
+
+// TokenInfo describes a token created by CreateToken
+type TokenInfo struct {
+	Name, User string
+}
+
+// UserInfo describes one user of a multi-user time-tracker server. Used in
+// ListUsersResponse
+type UserInfo struct {
+	Name    string
+	Owner   bool  // true if this user runs the server (and may call admin endpoints)
+	DBBytes int64 // size of the user's DB
+}
+
+// LabelInfo is the metadata of one label, sent to and returned by the /labels
+// endpoint
+type LabelInfo struct {
+	Name string
+	// The colour in which the label's intervals are drawn, as "#rrggbb". If
+	// empty, a colour is derived from the label's name (see LabelColors)
+	Color string
+}
+
+// GetLabelsResponse is returned by the /labels endpoint
+type GetLabelsResponse struct {
+	Labels []LabelInfo
+}
+
+// BackupRequest is the object sent to the /backups endpoint
+type BackupRequest struct {
+	// The file to which the DB is copied. It must not exist, and must be an
+	// absolute path (it's interpreted by the server, not the client)
+	Path string
+}
+
+// BackupResponse is returned by the /backups endpoint
+type BackupResponse struct {
+	Path  string
+	Bytes int64 // the size of the backup
+}
+
+// RestoreRequest is the object sent to the /restore endpoint
+type RestoreRequest struct {
+	// A backup created by Backup (or a copy of a time-tracker DB), as an
+	// absolute path
+	Path string
+}
+
+// RestoreResponse is returned by the /restore endpoint
+type RestoreResponse struct {
+	// A backup of the DB that was replaced, in case the restore was a mistake
+	Previous string
+}
+
+// DeleteTicksRequest is the object sent to the /ticks/delete endpoint. The
+// selected ticks are moved to the trash (see Undo)
+type DeleteTicksRequest struct {
+	// The ticks in [Start, End) (seconds since epoch) are deleted
+	Start, End int64
+	// If set, only ticks with this label are deleted
+	Label string
+}
+
+// RelabelRequest is the object sent to the /ticks/relabel endpoint. The
+// original ticks are copied to the trash (see Undo)
+type RelabelRequest struct {
+	// The ticks in [Start, End) (seconds since epoch) are relabelled
+	Start, End int64
+	// If set, only ticks with this label are relabelled
+	From string
+	// The new label
+	To string
+}
+
+// TrashBatch describes the ticks moved to the trash by one operation (e.g. a
+// DeleteTicks call). It's returned by the operation, and by /trash
+type TrashBatch struct {
+	ID          int64 // 0 if the operation didn't affect any ticks
+	Created     int64 // seconds since epoch
+	Description string
+	Ticks       int // the number of ticks in the batch
+}
+
+// ListTrashResponse is returned by the /trash endpoint
+type ListTrashResponse struct {
+	Batches []TrashBatch
+}
+
+// UndoRequest is the object sent to the /undo endpoint
+type UndoRequest struct {
+	Batch int64 // a TrashBatch's ID
+}
+
+// UndoResponse is returned by the /undo endpoint
+type UndoResponse struct {
+	Restored int // the number of ticks restored
+
+	// The batch's ticks that couldn't be restored, because a tick with the same
+	// time, host and label was added after the batch was made. A relabelled
+	// tick that couldn't get its old label back keeps its new one
+	Skipped []TickRecord
+}
+
+// RebuildIntervalsRequest is the object sent to the /intervals/rebuild
+// endpoint
+type RebuildIntervalsRequest struct {
+	// The range (in seconds since epoch) whose stored intervals are recomputed
+	// from its ticks. If both are 0, every interval is recomputed
+	Start, End int64
+}
+
+// RebuildIntervalsResponse is returned by the /intervals/rebuild endpoint
+type RebuildIntervalsResponse struct {
+	Intervals int // the number of (union) intervals in the rebuilt range
+}
+
+// SummaryRequest is the object sent to the /summary endpoint
+type SummaryRequest struct {
+	// The time range to summarize, as seconds since epoch. Every bucket that
+	// overlaps it is returned in full
+	Start, End int64
+
+	// The length of each bucket: Hour, Day or Week (in the server's time zone)
+	Period string
+
+	// If set, only time worked on this label is counted
+	Label string
+
+	// If set, each bucket also has the Focus of its work on every label (even
+	// if Label is set). This reads the range's intervals, so it takes time
+	// proportional to the range's length
+	Focus bool
+}
+
+// SummaryBucket is the time worked in one hour, day or week
+type SummaryBucket struct {
+	Start, End int64            // the bucket's bounds, as seconds since epoch
+	Seconds    map[string]int64 // seconds worked, by label
+	Total      int64            // seconds worked on any label
+
+	// How fragmented the bucket's work was (only set if the request's Focus
+	// was set). Unlike Seconds, this includes time since the last tick
+	Focus *Focus `json:",omitempty"`
+}
+
+// SummaryResponse is returned by the /summary endpoint. It has every bucket
+// in the requested range (including empty ones), in order
+type SummaryResponse struct {
+	Buckets []SummaryBucket
+}
+
+// HistogramRequest is the object sent to the /stats/histogram endpoint
+type HistogramRequest struct {
+	// The time range to count, as seconds since epoch. Every hour (or day)
+	// that overlaps it is counted in full
+	Start, End int64
+
+	// How to bin time: ByHour (by hour of the day) or ByWeekday (by day of the
+	// week), in the server's time zone
+	By string
+
+	// If set, only time worked on this label is counted
+	Label string
+}
+
+// HistogramBin is the time worked in one hour of the day or day of the week
+type HistogramBin struct {
+	Name    string // e.g. "09" or "Mon"
+	Seconds int64
+}
+
+// HistogramResponse is returned by the /stats/histogram endpoint. It has
+// every bin (24 hours, or 7 days starting on Monday), in order
+type HistogramResponse struct {
+	By    string
+	Bins  []HistogramBin
+	Total int64 // seconds worked in all bins
+}
+
+// ListUsersResponse is returned by the /admin/users endpoint
+type ListUsersResponse struct {
+	Users []UserInfo
+}
+
 // APIServer is the interface exported by the TrackingServer API
 type APIServer interface {
 	Tick(req *TickRequest) error
 	GetIntervals(req *GetIntervalsRequest) (*GetIntervalsResponse, error)
+	// Clear moves every tick to the trash
 	Clear() error
+
+	// DeleteTicks moves a range of ticks to the trash
+	DeleteTicks(req *DeleteTicksRequest) (*TrashBatch, error)
+	// Relabel changes the label of a range of ticks, keeping the originals in
+	// the trash
+	Relabel(req *RelabelRequest) (*TrashBatch, error)
+	// ListTrash returns the batches of ticks in the trash. Batches older than
+	// TrashRetention are purged
+	ListTrash() (*ListTrashResponse, error)
+	// Undo reverts the operation that created a trash batch, and reports any
+	// ticks that couldn't be restored
+	Undo(req *UndoRequest) (*UndoResponse, error)
+	// PurgeTrash permanently deletes trash batches older than TrashRetention.
+	// The daemon calls it on a schedule
+	PurgeTrash() error
+
+	CreateToken(req *CreateTokenRequest) (*CreateTokenResponse, error)
+	// LookupToken returns the info of 'token' if it was created by
+	// CreateToken, or nil otherwise
+	LookupToken(token string) (*TokenInfo, error)
+
+	// DBSize returns the size of the server's DB, in bytes
+	DBSize() (int64, error)
+
+	// Export returns the ticks in the server's DB, for merging into another
+	// server's DB with Sync
+	Export(req *ExportRequest) (*TickSet, error)
+	// ListTicks returns a page of the ticks in a range, in order
+	ListTicks(req *ListTicksRequest) (*ListTicksResponse, error)
+	// Sync merges the ticks in 'req' into the server's DB. Merging the same
+	// ticks more than once has no further effect
+	Sync(req *TickSet) (*SyncResponse, error)
+
+	// Subscribe returns a channel that receives Events as ticks arrive and
+	// intervals start and end, until 'cancel' is called
+	Subscribe() (events <-chan Event, cancel func())
+
+	// SetLabel stores the metadata (e.g. colour) of a label
+	SetLabel(info *LabelInfo) error
+	// GetLabels returns the metadata of every label that has any
+	GetLabels() (*GetLabelsResponse, error)
+
+	// Backup copies the DB to a new file while the server keeps serving
+	Backup(req *BackupRequest) (*BackupResponse, error)
+	// Restore checks that the file in 'req' is a valid DB, and replaces the
+	// server's DB with it
+	Restore(req *RestoreRequest) (*RestoreResponse, error)
+
+	// RebuildIntervals recomputes the stored intervals (which GetIntervals
+	// reads) from the ticks in a range, or from every tick
+	RebuildIntervals(req *RebuildIntervalsRequest) (*RebuildIntervalsResponse, error)
+	// BatchIntervals returns the intervals in each of several ranges, reading
+	// the DB once
+	BatchIntervals(req *BatchIntervalsRequest) (*BatchIntervalsResponse, error)
+
+	// Summary returns the time worked on each label in each hour, day or week
+	// of a range, from the stored roll-ups. Time since the last tick isn't
+	// included, even if its interval may still be going
+	Summary(req *SummaryRequest) (*SummaryResponse, error)
+	// Histogram returns the time worked in each hour of the day or day of the
+	// week over a range, from the stored roll-ups
+	Histogram(req *HistogramRequest) (*HistogramResponse, error)
+
+	// Close stops the server's background work (e.g. ending intervals after
+	// MaxEventGap) and closes its DB. The server can't be used afterwards
+	Close() error
 }
 
 // --------- Implementation --------
@@ -59,11 +443,22 @@ type server struct {
 	clock Clock
 
 	//// Owned
-	db *sql.DB
+	host string // the name of the host running this server (see Sync)
+	path string // the DB file
+	db   *sql.DB
 	// The sqlite driver does not allow for concurrent writes. See
 	// https://github.com/mattn/go-sqlite3#faq
 	// This allows for safe concurrent use of 'db'
 	mu sync.RWMutex
+
+	// Subscribers to, and state for generating, Events (see events.go)
+	events eventBus
+
+	// Closed by Close, to stop the goroutines started by NewServer, which mark
+	// 'running' done as they exit
+	stop     chan struct{}
+	stopOnce sync.Once
+	running  sync.WaitGroup
 }
 
 // NewServer returns an implementation of the TrackingServer api
@@ -78,17 +473,38 @@ func NewServer(clock Clock, dbPath string) (APIServer, error) {
 		time.Sleep(time.Second)
 		err = db.Ping()
 	}
-	// Take advantage of sqlite INTEGER PRIMARY KEY table for fast range scan of
-	// ticks: https://sqlite.org/lang_createtable.html#rowid
-	if _, err = db.Exec(
-		`CREATE TABLE IF NOT EXISTS ticks (time INTEGER PRIMARY KEY ASC, labels TEXT)`,
-	); err != nil {
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("could not get hostname: %v", err)
+	}
+	if err := initSchema(db, host); err != nil {
 		return nil, err
 	}
-	return &server{
+	s := &server{
+		host:  host,
+		path:  dbPath,
 		db:    db,
 		clock: clock,
-	}, nil
+		stop:  make(chan struct{}),
+	}
+	if err := s.checkIntervals(); err != nil {
+		return nil, err
+	}
+	if err := s.initEvents(); err != nil {
+		return nil, err
+	}
+	s.running.Add(1)
+	go s.watchGaps()
+	return s, nil
+}
+
+// Close stops the server's background goroutines and closes its DB
+func (s *server) Close() error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	s.running.Wait()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.db.Close()
 }
 
 // Tick handles the /tick http endpoint
@@ -98,30 +514,91 @@ func (s *server) Tick(req *TickRequest) error {
 		return fmt.Errorf("tick request must have a label (\"\" is used to " +
 			"indicate intervals formed by the union of all ticks in GetIntervals")
 	}
+	if req.Zone != "" {
+		if _, err := LoadZone(req.Zone); err != nil {
+			return fmt.Errorf("invalid tick zone: %v", err)
+		}
+	}
 
-	// Write tick to DB
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	_, err := s.db.Exec(fmt.Sprintf(
-		"INSERT INTO ticks VALUES (%d, \"%s\")", s.clock.Now().Unix(), EscapeLabel(req.Label),
-	))
-	return err
+	// Hold s.events.mu from reading the clock until the tick's events are
+	// published, so that concurrent ticks are written and published in the
+	// same order (and lastTick never moves backwards). It's always taken
+	// before s.mu
+	s.events.mu.Lock()
+	defer s.events.mu.Unlock()
+
+	// Write tick to DB. Ticks with the same time and label are redundant, so
+	// an identical tick is ignored rather than returned as an error
+	now := s.clock.Now()
+	insertStart := time.Now()
+	err := s.inTx(func(tx *sql.Tx) error {
+		var latest sql.NullInt64
+		if err := tx.QueryRow(`SELECT MAX(time) FROM ticks`).Scan(&latest); err != nil {
+			return err
+		}
+		result, err := tx.Exec(
+			"INSERT OR IGNORE INTO ticks (time, labels, zone, host) VALUES (?, ?, ?, ?)",
+			now.UnixNano(), EscapeLabel(req.Label), req.Zone, s.host)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			return err // identical tick, so the intervals haven't changed
+		}
+		// Ticks merged from other hosts may be later than 'now' if clocks are
+		// skewed, in which case this tick lands in the middle of history
+		if latest.Valid && now.UnixNano() < latest.Int64 {
+			_, err := rebuildIntervals(tx, now.Unix(), now.Unix())
+			return err
+		}
+		return appendTick(tx, now.Unix(), req.Label, req.Zone)
+	})
+	tickInsertSeconds.Observe(time.Since(insertStart).Seconds())
+	if err != nil {
+		return err
+	}
+	source := req.Source
+	if source == "" {
+		source = unknownSource
+	}
+	ticksReceived.Inc(req.Label, source)
+	s.publishTick(now, req.Label, req.Zone)
+	return nil
 }
 
+// GetIntervals reads the stored intervals that overlap the range in 'req'.
+// Requests for one host's intervals are computed from that host's ticks
 func (s *server) GetIntervals(req *GetIntervalsRequest) (*GetIntervalsResponse, error) {
+	defer func(start time.Time) {
+		getIntervalsSeconds.Observe(time.Since(start).Seconds())
+	}(time.Now())
+	if req.Host != "" {
+		return s.getTickIntervals(req)
+	}
+	return s.getCachedIntervals(req)
+}
+
+// getTickIntervals implements GetIntervals by computing intervals from the
+// ticks in the range in 'req'
+func (s *server) getTickIntervals(req *GetIntervalsRequest) (*GetIntervalsResponse, error) {
 	// Get list of times in the 'req' range from DB
 	var rows *sql.Rows
 	var err error
 	func() {
 		s.mu.RLock()
 		defer s.mu.RUnlock()
-		// check maxEventGap before and after request, to handle the case where a time
-		// interval overlaps with the request interval
-		start := req.Start - maxEventGap
-		end := req.End + maxEventGap
-		rows, err = s.db.Query(fmt.Sprintf(
-			"SELECT * FROM ticks WHERE time BETWEEN %d AND %d", start, end,
-		))
+		// check MaxEventGap before and after request, to handle the case where a time
+		// interval overlaps with the request interval. Tick times are stored in
+		// nanoseconds
+		start, end := nanoRange(widen(req.Start, req.End))
+		if req.Host != "" {
+			rows, err = s.db.Query("SELECT time, labels, zone FROM ticks "+
+				"WHERE time BETWEEN ? AND ? AND host = ? ORDER BY time, id",
+				start, end, req.Host)
+		} else {
+			rows, err = s.db.Query("SELECT time, labels, zone FROM ticks "+
+				"WHERE time BETWEEN ? AND ? ORDER BY time, id", start, end)
+		}
 	}()
 	if err != nil {
 		return nil, err
@@ -133,16 +610,23 @@ func (s *server) GetIntervals(req *GetIntervalsRequest) (*GetIntervalsResponse,
 		l: req.Start,
 		r: req.End,
 	}
+	byLabel := &labelCollector{
+		l: req.Start,
+		r: req.End,
+	}
 	var (
+		numRows   int    // ticks read from the DB
 		prevLabel string // label that no tick will have initially
 		prevT     int64  // prev tick's time (unix seconds)
+		prevZone  string // prev tick's zone
 	)
 	for rows.Next() {
 		// parse SQL record
-		var escapedLabel string
-		var t int64
-		rows.Scan(&t, &escapedLabel)
-		glog.Infof("%s, %s\n", time.Unix(t, 0), escapedLabel)
+		var escapedLabel, zone string
+		var nanos int64
+		rows.Scan(&nanos, &escapedLabel, &zone)
+		numRows++
+		t := nanos / int64(time.Second)
 		label := UnescapeLabel(escapedLabel)
 
 		// initialize collector for current activity
@@ -158,42 +642,68 @@ func (s *server) GetIntervals(req *GetIntervalsRequest) (*GetIntervalsResponse,
 			// New activity was started--this activity's interval starts at the end
 			// of the previous activity's interval (if there is one)
 			if prevT > 0 {
-				collector[label].Add(t)
+				collector[label].AddInZone(t, zone)
 			}
 			prevLabel = label
 		}
 
 		// Add timestamp to collectors
-		prevT = t
-		collector[label].Add(t)
-		collector[""].Add(t)
+		prevT, prevZone = t, zone
+		collector[label].AddInZone(t, zone)
+		collector[""].AddInZone(t, zone)
+		byLabel.Add(t, label, zone)
 	}
 
+	getIntervalsRows.Observe(float64(numRows))
+
 	// If we could extend the leftmost interval, proactively extend it and
-	// indicate how much time has elapsed since the past tick to the caller
+	// indicate how much time has elapsed since the past tick to the caller.
+	// (Ticks merged from other hosts may be later than 'now' if clocks are
+	// skewed; don't move those intervals' ends backwards)
 	now := s.clock.Now().Unix()
 	endGap := int64(0)
-	if (now - prevT) < maxEventGap {
+	if prevT <= now && (now-prevT) < MaxEventGap {
 		collector[prevLabel].Add(now)
 		collector[""].Add(now)
+		byLabel.Add(now, prevLabel, prevZone)
 		endGap = now - prevT
 	}
 
-	// TODO include labelled intervals in response
+	if req.ByLabel {
+		return &GetIntervalsResponse{
+			Intervals: byLabel.Finish(),
+			EndGap:    endGap,
+		}, nil
+	}
 	return &GetIntervalsResponse{
 		Intervals: collector[""].Finish(),
 		EndGap:    endGap,
 	}, nil
 }
 
-func (s *server) Clear() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	if _, err := s.db.Exec(`
-	  DROP TABLE ticks;
-	  CREATE TABLE IF NOT EXISTS ticks (time INTEGER PRIMARY KEY ASC, labels TEXT);
-	`); err != nil {
-		return err
+// toNanos converts 'secs' (seconds since epoch) to nanoseconds since epoch,
+// saturating rather than overflowing
+func toNanos(secs int64) int64 {
+	const maxSecs = math.MaxInt64 / int64(time.Second)
+	switch {
+	case secs > maxSecs:
+		return math.MaxInt64
+	case secs < -maxSecs:
+		return math.MinInt64
 	}
-	return nil
+	return secs * int64(time.Second)
+}
+
+// DBSize returns the size of the server's DB, in bytes
+func (s *server) DBSize() (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var pages, pageSize int64
+	if err := s.db.QueryRow(`PRAGMA page_count`).Scan(&pages); err != nil {
+		return 0, err
+	}
+	if err := s.db.QueryRow(`PRAGMA page_size`).Scan(&pageSize); err != nil {
+		return 0, err
+	}
+	return pages * pageSize, nil
 }
@@ -0,0 +1,172 @@
+// backup.go copies the DB to and from backup files, using SQLite's online
+// backup API (https://sqlite.org/backup.html) so that the server can keep
+// serving requests while it does
+
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// copyDB copies the main database of 'src' into that of 'dest', replacing
+// its contents
+func copyDB(dest, src *sql.DB) error {
+	ctx := context.Background()
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+	return destConn.Raw(func(d interface{}) error {
+		return srcConn.Raw(func(s interface{}) error {
+			destSQLite, ok := d.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected DB connection type %T", d)
+			}
+			srcSQLite, ok := s.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected DB connection type %T", s)
+			}
+			b, err := destSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return err
+			}
+			// Copy every page in one step. Writers are blocked by s.mu anyway
+			if _, err := b.Step(-1); err != nil {
+				b.Finish()
+				return err
+			}
+			return b.Finish()
+		})
+	})
+}
+
+// Backup copies the DB to req.Path. The copy is written to a temporary file
+// and then renamed, so that a failed backup never looks complete
+func (s *server) Backup(req *BackupRequest) (*BackupResponse, error) {
+	if !filepath.IsAbs(req.Path) {
+		return nil, fmt.Errorf("backup path %q must be absolute", req.Path)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.backup(req.Path)
+}
+
+// backup implements Backup. The caller must hold s.mu (for reading or
+// writing), so that the copy is consistent
+func (s *server) backup(path string) (*BackupResponse, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("%s already exists", path)
+	}
+	tmpPath := path + ".tmp"
+	os.Remove(tmpPath) // left over from a failed backup
+	dest, err := sql.Open("sqlite3", tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	err = copyDB(dest, s.db)
+	dest.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("could not back up DB: %v", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &BackupResponse{Path: path, Bytes: info.Size()}, nil
+}
+
+// checkBackup returns an error if 'db' isn't an intact time-tracker DB that
+// this version of time-tracker can read
+func checkBackup(db *sql.DB) error {
+	var result string
+	if err := db.QueryRow(`PRAGMA integrity_check`).Scan(&result); err != nil {
+		return fmt.Errorf("not a readable SQLite DB: %v", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("DB is corrupt: %s", result)
+	}
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master ` +
+		`WHERE type = 'table' AND name = 'ticks'`).Scan(&tables); err != nil {
+		return err
+	}
+	if tables == 0 {
+		return fmt.Errorf("not a time-tracker DB (it has no ticks table)")
+	}
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return fmt.Errorf("could not read schema version: %v", err)
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("DB has schema version %d, but this version of "+
+			"time-tracker only understands versions <= %d", version, len(migrations))
+	}
+	return nil
+}
+
+// Restore replaces the contents of the DB with the backup at req.Path, after
+// checking it. The replaced DB is first backed up next to the DB file, and the
+// restored DB is migrated to the current schema if it's older
+func (s *server) Restore(req *RestoreRequest) (*RestoreResponse, error) {
+	if !filepath.IsAbs(req.Path) {
+		return nil, fmt.Errorf("backup path %q must be absolute", req.Path)
+	}
+	// Don't let sql.Open create an empty DB if the backup doesn't exist
+	if _, err := os.Stat(req.Path); err != nil {
+		return nil, err
+	}
+	src, err := sql.Open("sqlite3", req.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	if err := checkBackup(src); err != nil {
+		return nil, fmt.Errorf("invalid backup %s: %v", req.Path, err)
+	}
+
+	previous, err := filepath.Abs(s.path + ".before-restore")
+	if err != nil {
+		return nil, err
+	}
+	err = func() error {
+		// Back up the replaced DB under the same lock as the restore, so that no
+		// tick can arrive between the two and be lost
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		os.Remove(previous) // only the most recent restore can be undone
+		if _, err := s.backup(previous); err != nil {
+			return err
+		}
+		if err := copyDB(s.db, src); err != nil {
+			return fmt.Errorf("could not restore DB: %v", err)
+		}
+		return initSchema(s.db, s.host)
+	}()
+	if err != nil {
+		return nil, err
+	}
+	// The backup's intervals may have been computed with a different
+	// MaxEventGap
+	if err := s.checkIntervals(); err != nil {
+		return nil, err
+	}
+	s.historyChanged()
+	return &RestoreResponse{Previous: previous}, nil
+}
@@ -0,0 +1,94 @@
+// batch.go answers requests for the intervals in many ranges at once (e.g.
+// each day of a week), so that clients don't need a request per range
+
+package api
+
+import (
+	"fmt"
+	"sort"
+)
+
+// maxBatchRanges is the largest number of ranges in a BatchIntervalsRequest
+const maxBatchRanges = 10000
+
+// batchRanges returns the ranges requested by 'req'
+func batchRanges(req *BatchIntervalsRequest) ([]TimeRange, error) {
+	if len(req.Ranges) > 0 {
+		if req.Step != 0 {
+			return nil, fmt.Errorf("must give either ranges or a step, not both")
+		}
+		if len(req.Ranges) > maxBatchRanges {
+			return nil, fmt.Errorf("too many ranges (%d; the limit is %d)", len(req.Ranges), maxBatchRanges)
+		}
+		for _, r := range req.Ranges {
+			if r.End <= r.Start {
+				return nil, fmt.Errorf("invalid range: end (%d) must be after start (%d)", r.End, r.Start)
+			}
+		}
+		return req.Ranges, nil
+	}
+	if req.Step <= 0 {
+		return nil, fmt.Errorf("must give either ranges or a positive step")
+	}
+	if req.End <= req.Start {
+		return nil, fmt.Errorf("invalid range: end (%d) must be after start (%d)", req.End, req.Start)
+	}
+	if (req.End-req.Start)/req.Step >= maxBatchRanges {
+		return nil, fmt.Errorf("too many ranges (the limit is %d)", maxBatchRanges)
+	}
+	var ranges []TimeRange
+	for start := req.Start; start < req.End; start += req.Step {
+		ranges = append(ranges, TimeRange{Start: start, End: min(start+req.Step, req.End)})
+	}
+	return ranges, nil
+}
+
+// BatchIntervals gets the intervals in every range in 'req'. Ranges that
+// overlap or touch are grouped, and each group's intervals are read with one
+// GetIntervals call and then divided among its ranges, so that (e.g.) a week
+// of consecutive days is one query, but two far-apart days don't read all the
+// time between them
+func (s *server) BatchIntervals(req *BatchIntervalsRequest) (*BatchIntervalsResponse, error) {
+	ranges, err := batchRanges(req)
+	if err != nil {
+		return nil, err
+	}
+	// Sort the ranges (by index, so that buckets stay in the requested order)
+	// and group them
+	order := make([]int, len(ranges))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return ranges[order[i]].Start < ranges[order[j]].Start })
+	resp := &BatchIntervalsResponse{Buckets: make([]IntervalsBucket, len(ranges))}
+	for len(order) > 0 {
+		group, n := ranges[order[0]], 1
+		for ; n < len(order) && ranges[order[n]].Start <= group.End; n++ {
+			group.End = max(group.End, ranges[order[n]].End)
+		}
+		all, err := s.GetIntervals(&GetIntervalsRequest{
+			Start:   group.Start,
+			End:     group.End,
+			Host:    req.Host,
+			ByLabel: req.ByLabel,
+		})
+		if err != nil {
+			return nil, err
+		}
+		resp.EndGap = all.EndGap // groups are in order, so the last one's is kept
+		for _, i := range order[:n] {
+			r := ranges[i]
+			b := IntervalsBucket{
+				Start:     r.Start,
+				End:       r.End,
+				Intervals: ClampIntervals(all.Intervals, r.Start, r.End),
+			}
+			for _, interval := range b.Intervals {
+				b.Total += interval.End - interval.Start
+			}
+			resp.Buckets[i] = b
+		}
+		order = order[n:]
+	}
+	return resp, nil
+}
@@ -1,6 +1,9 @@
 package api
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 // Clock is an interface wrapping time.Now(), so that clocks can be injected
 // into the TimeTracker server for testing
@@ -19,22 +22,30 @@ func (s systemClock) Now() time.Time {
 
 var SystemClock Clock = systemClock{} // really a const
 
-// TestingClock is an implementation of the Clock API that's useful for testing
+// TestingClock is an implementation of the Clock API that's useful for testing.
+// It may be read by a server's goroutines while a test sets it
 type TestingClock struct {
 	time.Time
+	mu sync.Mutex
 }
 
 // Now returns the current time according to 't'
 func (t *TestingClock) Now() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	return t.Time
 }
 
 // Add advances 't' by the duration 'd'
 func (t *TestingClock) Add(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	t.Time = t.Time.Add(d)
 }
 
 // Set sets the current time in 't' to 'to'
 func (t *TestingClock) Set(to time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	t.Time = to
 }
@@ -0,0 +1,245 @@
+// events.go notifies subscribers (e.g. the /events endpoint) as work happens:
+// when ticks arrive, when intervals start and end, and when the day's total
+// changes. Interval ends are detected by polling the server's Clock, so that
+// tests can drive them with a TestingClock
+
+package api
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// EventType identifies the kind of an Event
+type EventType string
+
+const (
+	// TickReceived is sent for every tick
+	TickReceived EventType = "tick-received"
+	// IntervalStarted is sent when a tick starts a new interval
+	IntervalStarted EventType = "interval-started"
+	// IntervalEnded is sent once MaxEventGap has elapsed since the last tick
+	IntervalEnded EventType = "interval-ended"
+	// DayTotalChanged is sent when the time worked today changes
+	DayTotalChanged EventType = "day-total-changed"
+)
+
+// Event is sent to subscribers of the /events endpoint
+type Event struct {
+	Type EventType
+	// When the event occurred, as seconds since epoch (per the server's Clock)
+	Time int64
+	// The tick's label (TickReceived only)
+	Label string `json:",omitempty"`
+	// The interval that started or ended (IntervalStarted and IntervalEnded
+	// only). An ended interval's End is the time of its last tick
+	Interval *Interval `json:",omitempty"`
+	// The number of seconds worked today, in the server's time zone
+	// (DayTotalChanged only)
+	DayTotal int64 `json:",omitempty"`
+}
+
+// eventBufSize is the number of events buffered for each subscriber. If a
+// subscriber falls this far behind, events are dropped
+const eventBufSize = 64
+
+// gapPollInterval is how often watchGaps checks whether the current interval
+// has ended
+const gapPollInterval = time.Second
+
+// eventBus tracks subscribers and the state needed to generate events
+type eventBus struct {
+	mu   sync.Mutex // if both are needed, taken before server.mu
+	subs map[chan Event]struct{}
+
+	// The current interval, for detecting interval starts and ends
+	open       bool  // true until MaxEventGap elapses after lastTick
+	start      int64 // start of the current (or last) interval
+	lastTick   int64 // time of the most recent tick (seconds since epoch)
+	lastLabel  string
+	lastZone   string
+	morning    int64 // start of the day whose total is 'dayTotal'
+	dayTotal   int64
+	totalKnown bool
+}
+
+// initEvents initializes the state of the current interval from the most
+// recent tick in the DB (so that a tick shortly after a restart doesn't
+// announce a new interval)
+func (s *server) initEvents() error {
+	s.events.mu.Lock()
+	defer s.events.mu.Unlock()
+	return s.loadLastTick()
+}
+
+// loadLastTick reads the most recent tick from the DB into s.events.
+// s.events.mu must be held
+func (s *server) loadLastTick() error {
+	s.mu.RLock()
+	var nanos int64
+	var escapedLabel, zone string
+	err := s.db.QueryRow("SELECT time, labels, zone FROM ticks ORDER BY time DESC, id DESC LIMIT 1").
+		Scan(&nanos, &escapedLabel, &zone)
+	s.mu.RUnlock()
+	if err == sql.ErrNoRows {
+		s.events.open = false
+		return nil
+	} else if err != nil {
+		return err
+	}
+	t := nanos / int64(time.Second)
+	s.events.lastTick, s.events.lastLabel, s.events.lastZone = t, UnescapeLabel(escapedLabel), zone
+	s.events.open = s.clock.Now().Unix()-t <= MaxEventGap
+	if s.events.open {
+		// Find the start of the interval containing the last tick
+		resp, err := s.GetIntervals(&GetIntervalsRequest{Start: t - 24*60*60, End: t})
+		if err != nil {
+			return err
+		}
+		s.events.start = t
+		if n := len(resp.Intervals); n > 0 {
+			s.events.start = resp.Intervals[n-1].Start
+		}
+	}
+	return nil
+}
+
+// historyChanged is called after ticks are added or removed other than by
+// Tick (e.g. by Sync or Clear). It reloads the current interval from the DB
+// and publishes the new day total
+func (s *server) historyChanged() {
+	s.events.mu.Lock()
+	defer s.events.mu.Unlock()
+	if err := s.loadLastTick(); err != nil {
+		glog.Errorf("could not reload last tick: %v", err)
+	}
+	s.updateDayTotal(s.clock.Now())
+}
+
+// Subscribe returns a channel that receives every Event generated by 's' until
+// 'cancel' is called
+func (s *server) Subscribe() (events <-chan Event, cancel func()) {
+	ch := make(chan Event, eventBufSize)
+	s.events.mu.Lock()
+	defer s.events.mu.Unlock()
+	if s.events.subs == nil {
+		s.events.subs = make(map[chan Event]struct{})
+	}
+	s.events.subs[ch] = struct{}{}
+	return ch, func() {
+		s.events.mu.Lock()
+		defer s.events.mu.Unlock()
+		if _, ok := s.events.subs[ch]; ok {
+			delete(s.events.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// publish sends 'e' to all subscribers. s.events.mu must be held
+func (s *server) publish(e Event) {
+	for ch := range s.events.subs {
+		select {
+		case ch <- e:
+		default:
+			glog.Warningf("event subscriber is not keeping up; dropping %s event", e.Type)
+		}
+	}
+}
+
+// publishTick generates the events caused by a tick with 'label' at 'now'.
+// s.events.mu must be held (see Tick)
+func (s *server) publishTick(now time.Time, label, zone string) {
+	t := now.Unix()
+	if s.events.open && t-s.events.lastTick > MaxEventGap {
+		// watchGaps hasn't noticed yet that the last interval ended
+		s.endInterval()
+	}
+	if !s.events.open {
+		s.events.open = true
+		s.events.start = t
+		s.publish(Event{
+			Type:     IntervalStarted,
+			Time:     t,
+			Interval: &Interval{Start: t, End: t, Label: label, Zone: zone},
+		})
+	}
+	s.events.lastTick, s.events.lastLabel, s.events.lastZone = t, label, zone
+	s.publish(Event{Type: TickReceived, Time: t, Label: label})
+	s.updateDayTotal(now)
+}
+
+// endInterval publishes an IntervalEnded event for the current interval.
+// s.events.mu must be held
+func (s *server) endInterval() {
+	s.events.open = false
+	s.publish(Event{
+		Type: IntervalEnded,
+		Time: s.clock.Now().Unix(),
+		Interval: &Interval{
+			Start: s.events.start,
+			End:   s.events.lastTick,
+			Label: s.events.lastLabel,
+			Zone:  s.events.lastZone,
+		},
+	})
+}
+
+// updateDayTotal recomputes the time worked on the day containing 'now' and
+// publishes a DayTotalChanged event if it changed. s.events.mu must be held
+func (s *server) updateDayTotal(now time.Time) {
+	if len(s.events.subs) == 0 {
+		s.events.totalKnown = false // recompute once there are subscribers
+		return
+	}
+	morning := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	resp, err := s.GetIntervals(&GetIntervalsRequest{
+		Start: morning.Unix(),
+		End:   morning.AddDate(0, 0, 1).Unix(),
+	})
+	if err != nil {
+		glog.Errorf("could not compute day total: %v", err)
+		return
+	}
+	var total int64
+	for _, i := range resp.Intervals {
+		total += i.End - i.Start
+	}
+	if s.events.totalKnown && s.events.morning == morning.Unix() && s.events.dayTotal == total {
+		return
+	}
+	s.events.totalKnown = true
+	s.events.morning, s.events.dayTotal = morning.Unix(), total
+	s.publish(Event{Type: DayTotalChanged, Time: now.Unix(), DayTotal: total})
+}
+
+// watchGaps runs until 's' is closed. It ends the current interval once
+// MaxEventGap has elapsed since the last tick, and notices when the day rolls
+// over
+func (s *server) watchGaps() {
+	defer s.running.Done()
+	ticker := time.NewTicker(gapPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		now := s.clock.Now()
+		s.events.mu.Lock()
+		if s.events.open && now.Unix()-s.events.lastTick > MaxEventGap {
+			s.endInterval()
+			s.updateDayTotal(now)
+		} else if s.events.totalKnown {
+			morning := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+			if morning.Unix() != s.events.morning {
+				s.updateDayTotal(now)
+			}
+		}
+		s.events.mu.Unlock()
+	}
+}
@@ -0,0 +1,63 @@
+// focus.go computes metrics of how focused a period's work was: a day of long
+// intervals on one label and a day of constant switching can have the same
+// total, but these tell them apart
+
+package api
+
+import "sort"
+
+// DeepWorkThreshold is the length (in seconds) beyond which an interval counts
+// as deep work in Focus.DeepShare
+const DeepWorkThreshold int64 = 50 * 60
+
+// Focus describes how fragmented the work in some period was
+type Focus struct {
+	// The number of times the label changed in the middle of an interval
+	Switches int
+
+	// The lengths (in seconds) of the longest interval and the median interval
+	Longest, Median int64
+
+	// The fraction of worked time that was in intervals longer than
+	// DeepWorkThreshold (0 if no time was worked)
+	DeepShare float64
+}
+
+// ComputeFocus computes the Focus of the per-label intervals in 'byLabel'
+// (sorted by start time, as returned by GetIntervals with ByLabel set).
+// Consecutive intervals that touch are parts of one interval of work
+func ComputeFocus(byLabel []Interval) Focus {
+	var f Focus
+	var lengths []int64
+	for i, interval := range byLabel {
+		if i > 0 && byLabel[i-1].End == interval.Start {
+			lengths[len(lengths)-1] += interval.End - interval.Start
+			if byLabel[i-1].Label != interval.Label {
+				f.Switches++
+			}
+			continue
+		}
+		lengths = append(lengths, interval.End-interval.Start)
+	}
+	if len(lengths) == 0 {
+		return f
+	}
+	sort.Slice(lengths, func(i, j int) bool { return lengths[i] < lengths[j] })
+	f.Longest = lengths[len(lengths)-1]
+	if n := len(lengths); n%2 == 1 {
+		f.Median = lengths[n/2]
+	} else {
+		f.Median = (lengths[n/2-1] + lengths[n/2]) / 2
+	}
+	var total, deep int64
+	for _, l := range lengths {
+		total += l
+		if l > DeepWorkThreshold {
+			deep += l
+		}
+	}
+	if total > 0 {
+		f.DeepShare = float64(deep) / float64(total)
+	}
+	return f
+}
@@ -0,0 +1,66 @@
+// histogram.go adds up the time worked in each hour of the day or each day of
+// the week over a range, to show when work actually happens. It reads the
+// hourly and daily roll-ups (see rollup.go), so it's fast over long ranges
+
+package api
+
+import (
+	"fmt"
+	"time"
+)
+
+// The ways in which Histogram can bin time
+const (
+	ByHour    = "hour"
+	ByWeekday = "weekday"
+)
+
+// Histogram returns the time worked in each hour of the day (from 00 to 23) or
+// each day of the week (from Monday to Sunday) during [req.Start, req.End)
+func (s *server) Histogram(req *HistogramRequest) (*HistogramResponse, error) {
+	if req.End <= req.Start {
+		return nil, fmt.Errorf("invalid range: end (%d) must be after start (%d)", req.End, req.Start)
+	}
+	var period string
+	var bin func(t time.Time) int // the bin that a bucket starting at 't' goes in
+	resp := &HistogramResponse{By: req.By}
+	switch req.By {
+	case ByHour:
+		period, bin = Hour, func(t time.Time) int { return t.Hour() }
+		for h := 0; h < 24; h++ {
+			resp.Bins = append(resp.Bins, HistogramBin{Name: fmt.Sprintf("%02d", h)})
+		}
+	case ByWeekday:
+		// time.Weekday starts on Sunday, but weeks start on Monday
+		period, bin = Day, func(t time.Time) int { return (int(t.Weekday()) + 6) % 7 }
+		for d := 1; d <= 7; d++ {
+			resp.Bins = append(resp.Bins, HistogramBin{Name: time.Weekday(d % 7).String()[:3]})
+		}
+	default:
+		return nil, fmt.Errorf("invalid histogram %q (must be %q or %q)", req.By, ByHour, ByWeekday)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	query := `SELECT bucket, SUM(seconds) FROM rollups WHERE period = ? AND bucket >= ? AND bucket < ?`
+	args := []interface{}{period, bucketStart(period, time.Unix(req.Start, 0)).Unix(), req.End}
+	if req.Label != "" {
+		query += ` AND label = ?`
+		args = append(args, req.Label)
+	}
+	rows, err := s.db.Query(query+` GROUP BY bucket`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bucket, seconds int64
+		if err := rows.Scan(&bucket, &seconds); err != nil {
+			return nil, err
+		}
+		b := &resp.Bins[bin(time.Unix(bucket, 0).In(time.Local))]
+		b.Seconds += seconds
+		resp.Total += seconds
+	}
+	return resp, rows.Err()
+}
@@ -7,8 +7,6 @@ package api
 import (
 	"fmt"
 	"time"
-
-	"github.com/golang/glog"
 )
 
 func (i Interval) String() string {
@@ -19,15 +17,16 @@ func (i Interval) String() string {
 
 // If this many seconds elapses between consecutive work ticks, then the gap
 // will "break" the previous work interval
-const maxEventGap int64 = 23 * 60
+const MaxEventGap int64 = 23 * 60
 
 // Collector is a data structure for converting a sequence of ticks into a
-// sequence of intervals (ticks separated by t < maxEventGap)
+// sequence of intervals (ticks separated by t < MaxEventGap)
 type Collector struct {
 	// lower (left) and upper (right) bound times for all intervals in the
 	// collection (overlapping intervals are truncated)
 	l, r       int64
-	start, end int64 // Start and end time of the 'current' interval (end advances until a 'wide' gap is encountered)
+	start, end int64  // Start and end time of the 'current' interval (end advances until a 'wide' gap is encountered)
+	zone       string // time zone of the tick that started the 'current' interval
 	intervals  []Interval
 	label      string
 }
@@ -35,18 +34,21 @@ type Collector struct {
 // Add adds a tick to 'c'. 's' is the time at which the tick occurred, as a Unix
 // timestamp (seconds since epoch)
 func (c *Collector) Add(t int64) bool {
-	logline := fmt.Sprintf("Add(%s)", time.Unix(t, 0))
+	return c.AddInZone(t, "")
+}
+
+// AddInZone is like Add, but also records the time zone in which the tick
+// occurred. If the tick starts a new interval, the interval gets its zone
+func (c *Collector) AddInZone(t int64, zone string) bool {
 	if c.start > c.r { // no overlap with [l, r]. Nothing to do
-		logline += " - no overlap"
 		return false
-	} else if t-c.end <= maxEventGap { // Check for interval break
-		logline += " - still going"
+	} else if t-c.end <= MaxEventGap { // Check for interval break
 		c.end = t // work interval still going: move 'end' to the right
 		return true
 	}
-	glog.Infof(logline)
 	c.addInterval()
 	c.start, c.end = t, t // start/end of next interval (end will advance)
+	c.zone = zone
 	return true
 }
 
@@ -62,8 +64,8 @@ func (c *Collector) addInterval() {
 		Start: max(c.l, c.start),
 		End:   min(c.r, c.end),
 		Label: c.label,
+		Zone:  c.zone,
 	}
-	glog.Infof("%v [max(%s, %s), min(%s, %s)]\n", toAdd, time.Unix(c.l, 0), time.Unix(c.start, 0), time.Unix(c.r, 0), time.Unix(c.end, 0))
 	if toAdd.End <= toAdd.Start {
 		return // toAdd has duration of 0 (or req.End < toAdd.Start) -- skip
 	}
@@ -83,3 +85,45 @@ func max(l, r int64) int64 {
 	}
 	return r
 }
+
+// labelCollector is like Collector, but splits intervals wherever the label
+// changes (see GetIntervalsRequest.ByLabel). The time between two consecutive
+// ticks in the same interval is attributed to the later tick's label
+type labelCollector struct {
+	// lower (left) and upper (right) bound times for all intervals in the
+	// collection (overlapping intervals are truncated)
+	l, r      int64
+	prevT     int64    // time of the previous tick
+	started   bool     // false until the first tick is added
+	cur       Interval // the 'current' interval (End advances until the label changes)
+	intervals []Interval
+}
+
+// Add adds a tick with the label 'label', recorded in 'zone', to 'c'
+func (c *labelCollector) Add(t int64, label, zone string) {
+	if c.started && t-c.prevT <= MaxEventGap {
+		if label == c.cur.Label && c.cur.End == c.prevT {
+			c.cur.End = t // same activity: move 'end' to the right
+		} else {
+			c.addInterval()
+			c.cur = Interval{Start: c.prevT, End: t, Label: label, Zone: zone}
+		}
+	}
+	c.prevT, c.started = t, true
+}
+
+// Finish indicates that no more ticks will be added. It closes the last
+// interval and returns the complete collection
+func (c *labelCollector) Finish() []Interval {
+	c.addInterval()
+	return c.intervals
+}
+
+func (c *labelCollector) addInterval() {
+	toAdd := c.cur
+	toAdd.Start, toAdd.End = max(c.l, toAdd.Start), min(c.r, toAdd.End)
+	if toAdd.End <= toAdd.Start {
+		return // toAdd has duration of 0 (or is outside of [l, r]) -- skip
+	}
+	c.intervals = append(c.intervals, toAdd)
+}
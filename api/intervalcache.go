@@ -0,0 +1,362 @@
+// intervalcache.go maintains the 'intervals' table, a materialized copy of the
+// intervals that GetIntervals would compute from the ticks table. Tick
+// extends the most recent intervals in place, and operations that change
+// older history (Sync, DeleteTicks, Relabel, Undo) rebuild just the window of
+// intervals that they touched, so that GetIntervals can read a range's
+// intervals directly instead of rescanning its ticks.
+//
+// Stored intervals are not clamped to any request's range, and keep their
+// zero-length intervals (e.g. the union interval of a lone tick), which later
+// ticks may extend. GetIntervals clamps them and drops empty ones
+
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Values of the 'kind' column of the 'intervals' table
+const (
+	unionIntervals = 0 // intervals formed by the union of all ticks (Label is "")
+	labelIntervals = 1 // intervals split wherever the label changes (see ByLabel)
+)
+
+// widen returns [start-MaxEventGap, end+MaxEventGap], saturating rather than
+// overflowing
+func widen(start, end int64) (int64, int64) {
+	lo, hi := start-MaxEventGap, end+MaxEventGap
+	if lo > start {
+		lo = math.MinInt64 // underflow
+	}
+	if hi < end {
+		hi = math.MaxInt64 // overflow
+	}
+	return lo, hi
+}
+
+// nanoRange converts the range [start, end] (in seconds) to nanoseconds,
+// including every tick in the range's last second
+func nanoRange(start, end int64) (int64, int64) {
+	start, end = toNanos(start), toNanos(end)
+	if end < math.MaxInt64 {
+		end += int64(time.Second) - 1
+	}
+	return start, end
+}
+
+// intervalBuilder converts a sequence of ticks into unclamped union and
+// per-label intervals, following the same rules as Collector and
+// labelCollector
+type intervalBuilder struct {
+	union, labels []Interval
+	prevT         int64 // time of the previous tick
+	started       bool  // false until the first tick is added
+}
+
+// add adds a tick at 't' (seconds since epoch) with the label 'label',
+// recorded in 'zone'. Ticks must be added in order
+func (b *intervalBuilder) add(t int64, label, zone string) {
+	if b.started && t-b.prevT <= MaxEventGap {
+		b.union[len(b.union)-1].End = t
+		if n := len(b.labels); n > 0 && b.labels[n-1].Label == label && b.labels[n-1].End == b.prevT {
+			b.labels[n-1].End = t
+		} else {
+			b.labels = append(b.labels, Interval{Start: b.prevT, End: t, Label: label, Zone: zone})
+		}
+	} else {
+		b.union = append(b.union, Interval{Start: t, End: t, Zone: zone})
+	}
+	b.prevT, b.started = t, true
+}
+
+// buildIntervals computes the intervals formed by the ticks matching 'where'
+// and inserts them into the 'intervals' table, adding their time to 'rollups'
+// (if it isn't nil). It returns the number of union intervals inserted
+func buildIntervals(tx *sql.Tx, rollups rollupDelta, where string, args ...interface{}) (int, error) {
+	rows, err := tx.Query(`SELECT time, labels, zone FROM ticks WHERE `+where+
+		` ORDER BY time, id`, args...)
+	if err != nil {
+		return 0, err
+	}
+	var b intervalBuilder
+	for rows.Next() {
+		var nanos int64
+		var escapedLabel, zone string
+		if err := rows.Scan(&nanos, &escapedLabel, &zone); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		b.add(nanos/int64(time.Second), UnescapeLabel(escapedLabel), zone)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	insert, err := tx.Prepare(
+		`INSERT INTO intervals (kind, start, end, label, zone) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer insert.Close()
+	for kind, intervals := range [][]Interval{unionIntervals: b.union, labelIntervals: b.labels} {
+		for _, i := range intervals {
+			if _, err := insert.Exec(kind, i.Start, i.End, i.Label, i.Zone); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if rollups != nil {
+		for _, i := range b.labels {
+			if err := rollups.add(i, 1); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(b.union), nil
+}
+
+// rebuildAllIntervals replaces every stored interval (and roll-up) with ones
+// computed from the ticks table. It returns the number of union intervals
+func rebuildAllIntervals(tx *sql.Tx) (int, error) {
+	if _, err := tx.Exec(`DELETE FROM intervals; DELETE FROM rollups`); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE intervals_meta SET max_event_gap = ?`, MaxEventGap); err != nil {
+		return 0, err
+	}
+	rollups := make(rollupDelta)
+	n, err := buildIntervals(tx, rollups, "1")
+	if err != nil {
+		return 0, err
+	}
+	return n, rollups.apply(tx)
+}
+
+// rebuildIntervals recomputes the stored intervals that may have been changed
+// by adding or removing ticks in [start, end] (in seconds). Any union interval
+// that comes within MaxEventGap of the range is rebuilt in full, as its ticks
+// may now form different intervals. It returns the number of union intervals
+// in the rebuilt window
+func rebuildIntervals(tx *sql.Tx, start, end int64) (int, error) {
+	lo, hi := widen(start, end)
+	var first, last sql.NullInt64
+	if err := tx.QueryRow(`SELECT MIN(start), MAX(end) FROM intervals `+
+		`WHERE kind = ? AND end >= ? AND start <= ?`, unionIntervals, lo, hi).Scan(&first, &last); err != nil {
+		return 0, err
+	}
+	if first.Valid && first.Int64 < lo {
+		lo = first.Int64
+	}
+	if last.Valid && last.Int64 > hi {
+		hi = last.Int64
+	}
+	// Per-label intervals lie within union intervals, so this deletes exactly
+	// the intervals formed by ticks in [lo, hi]. Their time is taken out of the
+	// roll-ups, and the rebuilt intervals' time is added back
+	rollups := make(rollupDelta)
+	rows, err := tx.Query(`SELECT start, end, label FROM intervals `+
+		`WHERE kind = ? AND end >= ? AND start <= ?`, labelIntervals, lo, hi)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var i Interval
+		if err := rows.Scan(&i.Start, &i.End, &i.Label); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if err := rollups.add(i, -1); err != nil {
+			rows.Close()
+			return 0, err
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`DELETE FROM intervals WHERE end >= ? AND start <= ?`, lo, hi); err != nil {
+		return 0, err
+	}
+	loNanos, hiNanos := nanoRange(lo, hi)
+	n, err := buildIntervals(tx, rollups, "time BETWEEN ? AND ?", loNanos, hiNanos)
+	if err != nil {
+		return 0, err
+	}
+	return n, rollups.apply(tx)
+}
+
+// appendTick updates the stored intervals after a tick at 't' (in seconds) was
+// inserted. The tick must be later than every other tick (otherwise, call
+// rebuildIntervals)
+func appendTick(tx *sql.Tx, t int64, label, zone string) error {
+	var id, prevT int64
+	err := tx.QueryRow(`SELECT id, end FROM intervals WHERE kind = ? `+
+		`ORDER BY end DESC, id DESC LIMIT 1`, unionIntervals).Scan(&id, &prevT)
+	if err == sql.ErrNoRows || (err == nil && t-prevT > MaxEventGap) {
+		_, err := tx.Exec(`INSERT INTO intervals (kind, start, end, label, zone) `+
+			`VALUES (?, ?, ?, '', ?)`, unionIntervals, t, t, zone)
+		return err
+	} else if err != nil {
+		return err
+	}
+	// The tick continues the latest union interval, which ends at the previous
+	// tick
+	if _, err := tx.Exec(`UPDATE intervals SET end = ? WHERE id = ?`, t, id); err != nil {
+		return err
+	}
+	var end int64
+	var prevLabel string
+	err = tx.QueryRow(`SELECT id, end, label FROM intervals WHERE kind = ? `+
+		`ORDER BY end DESC, id DESC LIMIT 1`, labelIntervals).Scan(&id, &end, &prevLabel)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil && end == prevT && prevLabel == label {
+		_, err = tx.Exec(`UPDATE intervals SET end = ? WHERE id = ?`, t, id)
+	} else {
+		_, err = tx.Exec(`INSERT INTO intervals (kind, start, end, label, zone) `+
+			`VALUES (?, ?, ?, ?, ?)`, labelIntervals, prevT, t, label, zone)
+	}
+	if err != nil {
+		return err
+	}
+	// Either way, the time since the previous tick is attributed to 'label'
+	rollups := make(rollupDelta)
+	if err := rollups.add(Interval{Start: prevT, End: t, Label: label}, 1); err != nil {
+		return err
+	}
+	return rollups.apply(tx)
+}
+
+// checkIntervals rebuilds the stored intervals if they were computed with a
+// different MaxEventGap than this version of time-tracker uses
+func (s *server) checkIntervals() error {
+	var gap int64
+	if err := s.db.QueryRow(`SELECT max_event_gap FROM intervals_meta`).Scan(&gap); err != nil {
+		return err
+	}
+	if gap == MaxEventGap {
+		return nil
+	}
+	glog.Infof("MaxEventGap changed from %ds to %ds; rebuilding intervals", gap, MaxEventGap)
+	return s.inTx(func(tx *sql.Tx) error {
+		_, err := rebuildAllIntervals(tx)
+		return err
+	})
+}
+
+// RebuildIntervals recomputes the stored intervals (and roll-ups) in the range
+// in 'req' (or all of them) from the ticks table
+func (s *server) RebuildIntervals(req *RebuildIntervalsRequest) (*RebuildIntervalsResponse, error) {
+	if req.End < req.Start {
+		return nil, fmt.Errorf("invalid range: end (%d) is before start (%d)", req.End, req.Start)
+	}
+	resp := &RebuildIntervalsResponse{}
+	err := s.inTx(func(tx *sql.Tx) error {
+		var err error
+		if req.Start == 0 && req.End == 0 {
+			resp.Intervals, err = rebuildAllIntervals(tx)
+		} else {
+			resp.Intervals, err = rebuildIntervals(tx, req.Start, req.End)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.historyChanged()
+	return resp, nil
+}
+
+// getCachedIntervals implements GetIntervals (for requests without a Host)
+// by reading the 'intervals' table
+func (s *server) getCachedIntervals(req *GetIntervalsRequest) (*GetIntervalsResponse, error) {
+	kind := unionIntervals
+	if req.ByLabel {
+		kind = labelIntervals
+	}
+	var (
+		intervals []Interval
+		found     bool   // true if there's a tick in the range
+		prevT     int64  // the last tick's time (unix seconds)
+		prevLabel string // the last tick's label
+		prevZone  string // the last tick's zone
+	)
+	err := func() error {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		lo, hi := widen(req.Start, req.End)
+		loNanos, hiNanos := nanoRange(lo, hi)
+		var nanos int64
+		var escapedLabel string
+		err := s.db.QueryRow(`SELECT time, labels, zone FROM ticks WHERE time BETWEEN ? AND ? `+
+			`ORDER BY time DESC, id DESC LIMIT 1`, loNanos, hiNanos).Scan(&nanos, &escapedLabel, &prevZone)
+		switch {
+		case err == sql.ErrNoRows:
+			return nil // no ticks, so no intervals
+		case err != nil:
+			return err
+		}
+		found, prevT, prevLabel = true, nanos/int64(time.Second), UnescapeLabel(escapedLabel)
+
+		// Intervals of one kind don't overlap, so the last interval that can
+		// overlap the range is the first to end at or after req.End. Bounding
+		// 'end' by it keeps the index scan from reading the rest of history
+		rows, err := s.db.Query(`SELECT start, end, label, zone FROM intervals `+
+			`WHERE kind = ? AND end >= ? AND start <= ? AND end <= COALESCE(`+
+			`(SELECT MIN(end) FROM intervals WHERE kind = ? AND end >= ?), ?) `+
+			`ORDER BY start, end, id`, kind, lo, req.End, kind, req.End, int64(math.MaxInt64))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var i Interval
+			if err := rows.Scan(&i.Start, &i.End, &i.Label, &i.Zone); err != nil {
+				return err
+			}
+			intervals = append(intervals, i)
+		}
+		return rows.Err()
+	}()
+	if err != nil {
+		return nil, err
+	}
+	getIntervalsRows.Observe(float64(len(intervals)))
+
+	// As in getTickIntervals, extend the interval containing the last tick to
+	// now, if it may still be going
+	now := s.clock.Now().Unix()
+	endGap := int64(0)
+	if found && prevT <= now && (now-prevT) < MaxEventGap {
+		extended := false
+		for i := len(intervals) - 1; i >= 0; i-- {
+			if intervals[i].End == prevT && (kind == unionIntervals || intervals[i].Label == prevLabel) {
+				intervals[i].End = now
+				extended = true
+				break
+			}
+		}
+		if !extended && kind == labelIntervals {
+			// The last tick started its union interval, so no per-label interval
+			// ends at it yet
+			intervals = append(intervals, Interval{Start: prevT, End: now, Label: prevLabel, Zone: prevZone})
+		}
+		endGap = now - prevT
+	}
+
+	var result []Interval
+	for _, i := range intervals {
+		i.Start, i.End = max(req.Start, i.Start), min(req.End, i.End)
+		if i.End <= i.Start {
+			continue // i has duration of 0 (or is outside of the range) -- skip
+		}
+		result = append(result, i)
+	}
+	return &GetIntervalsResponse{Intervals: result, EndGap: endGap}, nil
+}
@@ -0,0 +1,126 @@
+// label.go stores per-label metadata (currently just the colour in which each
+// label's intervals are drawn) and derives a stable colour for labels that
+// don't have one
+
+package api
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"regexp"
+)
+
+// UnlabelledColor is the colour of intervals with no label (and of all
+// intervals, when they aren't split by label)
+const UnlabelledColor = "#ffb915"
+
+var colorRe = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// SetLabel stores the metadata in 'info'. Setting a label's colour to ""
+// reverts it to its default colour
+func (s *server) SetLabel(info *LabelInfo) error {
+	if info.Color != "" && !colorRe.MatchString(info.Color) {
+		return fmt.Errorf("invalid colour %q (must look like \"#rrggbb\")", info.Color)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if info.Color == "" {
+		_, err := s.db.Exec(`DELETE FROM labels WHERE name = ?`, info.Name)
+		return err
+	}
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO labels (name, color) VALUES (?, ?)`,
+		info.Name, info.Color)
+	return err
+}
+
+// GetLabels returns the metadata of every label that has any
+func (s *server) GetLabels() (*GetLabelsResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rows, err := s.db.Query(`SELECT name, color FROM labels ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	resp := &GetLabelsResponse{Labels: []LabelInfo{}}
+	for rows.Next() {
+		var info LabelInfo
+		if err := rows.Scan(&info.Name, &info.Color); err != nil {
+			return nil, err
+		}
+		resp.Labels = append(resp.Labels, info)
+	}
+	return resp, rows.Err()
+}
+
+// LabelColors maps labels to the colours in which their intervals are drawn
+type LabelColors map[string]string
+
+// NewLabelColors returns the LabelColors set in 'labels' (e.g. as returned by
+// GetLabels)
+func NewLabelColors(labels []LabelInfo) LabelColors {
+	c := make(LabelColors)
+	for _, l := range labels {
+		if l.Color != "" {
+			c[l.Name] = l.Color
+		}
+	}
+	return c
+}
+
+// Color returns the colour of 'label', as "#rrggbb": either the colour set for
+// it with SetLabel, or one derived from its name
+func (c LabelColors) Color(label string) string {
+	if color, ok := c[label]; ok {
+		return color
+	}
+	return DefaultLabelColor(label)
+}
+
+// DefaultLabelColor derives a colour from 'label's name, so that each label
+// keeps the same colour across pages, restarts and machines
+func DefaultLabelColor(label string) string {
+	if label == "" {
+		return UnlabelledColor
+	}
+	h := fnv.New32a()
+	h.Write([]byte(label))
+	hue := float64(h.Sum32() % 360)
+	return hslToHex(hue, 0.65, 0.55)
+}
+
+// hslToHex converts a colour from HSL (hue in degrees, saturation and
+// lightness in [0, 1]) to "#rrggbb"
+func hslToHex(h, s, l float64) string {
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return fmt.Sprintf("#%02x%02x%02x",
+		int(math.Round((r+m)*255)), int(math.Round((g+m)*255)), int(math.Round((b+m)*255)))
+}
+
+// ParseColor returns the red, green and blue components of 'color', which must
+// look like "#rrggbb"
+func ParseColor(color string) (r, g, b uint8, err error) {
+	if !colorRe.MatchString(color) {
+		return 0, 0, 0, fmt.Errorf("invalid colour %q (must look like \"#rrggbb\")", color)
+	}
+	_, err = fmt.Sscanf(color, "#%02x%02x%02x", &r, &g, &b)
+	return r, g, b, err
+}
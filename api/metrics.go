@@ -0,0 +1,24 @@
+// metrics.go defines the metrics recorded by the API server (see the metrics
+// package). They're shared by every server in the process (e.g. all users'
+// servers in multi-user mode)
+
+package api
+
+import (
+	"github.com/msteffen/golang-time-tracker/metrics"
+)
+
+var (
+	ticksReceived = metrics.NewCounterVec("timetracker_ticks_received_total",
+		"Ticks received, by label and source (see TickRequest.Source)", "label", "source")
+	tickInsertSeconds = metrics.NewHistogram("timetracker_tick_insert_seconds",
+		"Time taken to write a tick to the DB", metrics.LatencyBuckets)
+	getIntervalsSeconds = metrics.NewHistogram("timetracker_get_intervals_seconds",
+		"Time taken by GetIntervals, including reading ticks from the DB", metrics.LatencyBuckets)
+	getIntervalsRows = metrics.NewHistogram("timetracker_get_intervals_rows",
+		"Ticks read from the DB by each GetIntervals call",
+		[]float64{0, 10, 100, 1000, 10000, 100000, 1000000})
+)
+
+// unknownSource is the source recorded for ticks whose TickRequest has none
+const unknownSource = "unknown"
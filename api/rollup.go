@@ -0,0 +1,242 @@
+// rollup.go maintains the 'rollups' table, which stores the seconds worked on
+// each label in each hour, day and week. Roll-ups are derived from the stored
+// per-label intervals (see intervalcache.go), and are adjusted whenever those
+// change, so that Summary can report on long ranges by reading one row per
+// bucket and label instead of every interval in the range.
+//
+// Buckets start on the hour, at midnight, and at midnight on Monday in the
+// server's local time zone. If that zone changes, 't rebuild-intervals'
+// recomputes them
+
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// The periods into which roll-ups divide time
+const (
+	Hour = "hour"
+	Day  = "day"
+	Week = "week"
+)
+
+// rollupPeriods are the periods for which roll-ups are stored
+var rollupPeriods = []string{Hour, Day, Week}
+
+// maxSummaryBuckets is the largest number of buckets that Summary returns
+const maxSummaryBuckets = 10000
+
+// bucketStart returns the start of the bucket of 'period' that contains 't'
+func bucketStart(period string, t time.Time) time.Time {
+	t = t.In(time.Local)
+	switch period {
+	case Hour:
+		// Step back on the absolute timeline rather than with time.Date, which
+		// can't tell apart the two 1:00s of a night when clocks go back
+		return t.Add(-time.Duration(t.Minute())*time.Minute -
+			time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	case Week:
+		// time.Weekday starts on Sunday, but weeks start on Monday
+		t = t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// nextBucket returns the start of the bucket of 'period' after the one that
+// starts at 'start'
+func nextBucket(period string, start time.Time) (time.Time, error) {
+	var next time.Time
+	switch period {
+	case Hour:
+		next = bucketStart(Hour, start.Add(time.Hour))
+	case Week:
+		next = start.AddDate(0, 0, 7)
+	default:
+		next = start.AddDate(0, 0, 1)
+	}
+	// Every loop over buckets depends on this; if it failed, they would never
+	// finish (while holding the DB lock)
+	if !next.After(start) {
+		return time.Time{}, fmt.Errorf("%s bucket after %s doesn't advance (got %s)", period, start, next)
+	}
+	return next, nil
+}
+
+// validPeriod returns an error if 'period' isn't a roll-up period
+func validPeriod(period string) error {
+	for _, p := range rollupPeriods {
+		if period == p {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid period %q (must be %q, %q or %q)", period, Hour, Day, Week)
+}
+
+// rollupKey identifies one row of the 'rollups' table
+type rollupKey struct {
+	period string
+	bucket int64 // the bucket's start time, as seconds since epoch
+	label  string
+}
+
+// rollupDelta accumulates changes to the 'rollups' table (in seconds), so
+// that they can be written with one statement per row
+type rollupDelta map[rollupKey]int64
+
+// add adds the time in 'i' (if 'sign' is 1) or removes it (if 'sign' is -1)
+// from every bucket that 'i' overlaps
+func (d rollupDelta) add(i Interval, sign int64) error {
+	if i.End <= i.Start {
+		return nil
+	}
+	for _, period := range rollupPeriods {
+		for b := bucketStart(period, time.Unix(i.Start, 0)); b.Unix() < i.End; {
+			next, err := nextBucket(period, b)
+			if err != nil {
+				return err
+			}
+			d[rollupKey{period, b.Unix(), i.Label}] += sign * (min(i.End, next.Unix()) - max(i.Start, b.Unix()))
+			b = next
+		}
+	}
+	return nil
+}
+
+// apply writes the changes in 'd' to the 'rollups' table, deleting rows that
+// fall to zero
+func (d rollupDelta) apply(tx *sql.Tx) error {
+	if len(d) == 0 {
+		return nil
+	}
+	insert, err := tx.Prepare(`INSERT OR IGNORE INTO rollups (period, bucket, label, seconds) ` +
+		`VALUES (?, ?, ?, 0)`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	update, err := tx.Prepare(`UPDATE rollups SET seconds = seconds + ? ` +
+		`WHERE period = ? AND bucket = ? AND label = ?`)
+	if err != nil {
+		return err
+	}
+	defer update.Close()
+	del, err := tx.Prepare(`DELETE FROM rollups ` +
+		`WHERE period = ? AND bucket = ? AND label = ? AND seconds <= 0`)
+	if err != nil {
+		return err
+	}
+	defer del.Close()
+	for k, seconds := range d {
+		if seconds == 0 {
+			continue
+		}
+		if _, err := insert.Exec(k.period, k.bucket, k.label); err != nil {
+			return err
+		}
+		if _, err := update.Exec(seconds, k.period, k.bucket, k.label); err != nil {
+			return err
+		}
+		if seconds > 0 {
+			continue
+		}
+		if _, err := del.Exec(k.period, k.bucket, k.label); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Summary returns the time worked in each bucket of req.Period that overlaps
+// [req.Start, req.End), read from the roll-ups
+func (s *server) Summary(req *SummaryRequest) (*SummaryResponse, error) {
+	if err := validPeriod(req.Period); err != nil {
+		return nil, err
+	}
+	if req.End <= req.Start {
+		return nil, fmt.Errorf("invalid range: end (%d) must be after start (%d)", req.End, req.Start)
+	}
+	resp := &SummaryResponse{Buckets: []SummaryBucket{}}
+	index := make(map[int64]int) // bucket start -> index in resp.Buckets
+	for b := bucketStart(req.Period, time.Unix(req.Start, 0)); b.Unix() < req.End; {
+		if len(resp.Buckets) == maxSummaryBuckets {
+			return nil, fmt.Errorf("range has more than %d %s buckets", maxSummaryBuckets, req.Period)
+		}
+		next, err := nextBucket(req.Period, b)
+		if err != nil {
+			return nil, err
+		}
+		index[b.Unix()] = len(resp.Buckets)
+		resp.Buckets = append(resp.Buckets, SummaryBucket{
+			Start:   b.Unix(),
+			End:     next.Unix(),
+			Seconds: map[string]int64{},
+		})
+		b = next
+	}
+	if len(resp.Buckets) == 0 {
+		return resp, nil
+	}
+
+	if err := s.readRollups(req, resp, index); err != nil {
+		return nil, err
+	}
+	if req.Focus {
+		if err := s.addFocus(req, resp); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// readRollups adds the roll-ups of the buckets in 'resp' (whose indices are in
+// 'index', by start time) to them
+func (s *server) readRollups(req *SummaryRequest, resp *SummaryResponse, index map[int64]int) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	query := `SELECT bucket, label, seconds FROM rollups WHERE period = ? AND bucket >= ? AND bucket < ?`
+	args := []interface{}{req.Period, resp.Buckets[0].Start, req.End}
+	if req.Label != "" {
+		query += ` AND label = ?`
+		args = append(args, req.Label)
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bucket, seconds int64
+		var label string
+		if err := rows.Scan(&bucket, &label, &seconds); err != nil {
+			return err
+		}
+		i, ok := index[bucket]
+		if !ok {
+			continue // bucketed in a different time zone; see 't rebuild-intervals'
+		}
+		resp.Buckets[i].Seconds[label] += seconds
+		resp.Buckets[i].Total += seconds
+	}
+	return rows.Err()
+}
+
+// addFocus computes the Focus of each bucket in 'resp' from its intervals
+func (s *server) addFocus(req *SummaryRequest, resp *SummaryResponse) error {
+	first, last := resp.Buckets[0], resp.Buckets[len(resp.Buckets)-1]
+	intervals, err := s.GetIntervals(&GetIntervalsRequest{
+		Start:   first.Start,
+		End:     last.End,
+		ByLabel: true,
+	})
+	if err != nil {
+		return err
+	}
+	for i, b := range resp.Buckets {
+		focus := ComputeFocus(ClampIntervals(intervals.Intervals, b.Start, b.End))
+		resp.Buckets[i].Focus = &focus
+	}
+	return nil
+}
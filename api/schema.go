@@ -0,0 +1,196 @@
+// schema.go creates time-tracker's SQLite tables and migrates databases
+// created by older versions of time-tracker. The schema version is stored in
+// SQLite's 'user_version' pragma: https://sqlite.org/pragma.html#pragma_user_version
+
+package api
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/golang/glog"
+)
+
+// migration upgrades a DB by one schema version, inside of 'tx'. 'host' is the
+// name of the host running the server, for migrations that need it
+type migration func(tx *sql.Tx, host string) error
+
+// stmt returns a migration that just executes the SQL statement(s) in 'query'
+func stmt(query string) migration {
+	return func(tx *sql.Tx, _ string) error {
+		_, err := tx.Exec(query)
+		return err
+	}
+}
+
+// migrations[i] upgrades a DB from schema version i to schema version i+1.
+// Version 0 is the original schema (a 'ticks' table with 'time' and 'labels'
+// columns). New migrations must only ever be appended to this list.
+var migrations = []migration{
+	// 0 -> 1: record the time zone in which each tick occurred ("" if unknown)
+	stmt(`ALTER TABLE ticks ADD COLUMN zone TEXT NOT NULL DEFAULT ''`),
+
+	// 1 -> 2: store (hashes of) the bearer tokens that authenticate requests
+	// which don't arrive over the unix socket
+	stmt(`CREATE TABLE tokens (hash TEXT PRIMARY KEY, name TEXT NOT NULL, created INTEGER NOT NULL)`),
+
+	// 2 -> 3: in multi-user mode, record the user that each token authenticates
+	// ("" is the server's owner)
+	stmt(`ALTER TABLE tokens ADD COLUMN user TEXT NOT NULL DEFAULT ''`),
+
+	// 3 -> 4: record the host that received each tick, so that DBs from
+	// several machines can be merged. Existing ticks were received here
+	func(tx *sql.Tx, host string) error {
+		if _, err := tx.Exec(`ALTER TABLE ticks ADD COLUMN host TEXT NOT NULL DEFAULT ''`); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`UPDATE ticks SET host = ?`, host)
+		return err
+	},
+
+	// 4 -> 5: store tick times in nanoseconds, and let several ticks share a
+	// time (e.g. two editors saving at once). Ticks are now keyed by an
+	// autoincrementing id; the UNIQUE constraint's index (which starts with
+	// 'time') keeps range scans fast and makes merges idempotent
+	stmt(`
+	  CREATE TABLE ticks_v5 (
+	    id INTEGER PRIMARY KEY,
+	    time INTEGER NOT NULL,
+	    labels TEXT NOT NULL,
+	    zone TEXT NOT NULL DEFAULT '',
+	    host TEXT NOT NULL DEFAULT '',
+	    UNIQUE (time, host, labels)
+	  );
+	  INSERT INTO ticks_v5 (time, labels, zone, host)
+	    SELECT time * 1000000000, COALESCE(labels, ''), zone, host FROM ticks ORDER BY time;
+	  DROP TABLE ticks;
+	  ALTER TABLE ticks_v5 RENAME TO ticks;
+	`),
+
+	// 5 -> 6: store per-label metadata (see SetLabel)
+	stmt(`CREATE TABLE labels (name TEXT PRIMARY KEY, color TEXT NOT NULL DEFAULT '')`),
+
+	// 6 -> 7: keep ticks that are deleted or relabelled in a trash, grouped by
+	// the operation that changed them, so that it can be undone (see trash.go).
+	// 'tick_id' is the trashed tick's id in 'ticks'
+	stmt(`
+	  CREATE TABLE trash_batches (
+	    id INTEGER PRIMARY KEY,
+	    created INTEGER NOT NULL,
+	    description TEXT NOT NULL
+	  );
+	  CREATE TABLE trash (
+	    batch INTEGER NOT NULL,
+	    tick_id INTEGER NOT NULL,
+	    time INTEGER NOT NULL,
+	    labels TEXT NOT NULL,
+	    zone TEXT NOT NULL DEFAULT '',
+	    host TEXT NOT NULL DEFAULT ''
+	  );
+	  CREATE INDEX trash_by_batch ON trash (batch);
+	`),
+
+	// 7 -> 8: store the intervals formed by the ticks, so that GetIntervals
+	// doesn't have to recompute them (see intervalcache.go). 'kind' is
+	// unionIntervals or labelIntervals, and 'start' and 'end' are in seconds.
+	// 'intervals_meta' records the MaxEventGap with which they were computed
+	func(tx *sql.Tx, _ string) error {
+		if _, err := tx.Exec(`
+		  CREATE TABLE intervals (
+		    id INTEGER PRIMARY KEY,
+		    kind INTEGER NOT NULL,
+		    start INTEGER NOT NULL,
+		    end INTEGER NOT NULL,
+		    label TEXT NOT NULL DEFAULT '',
+		    zone TEXT NOT NULL DEFAULT ''
+		  );
+		  CREATE INDEX intervals_by_end ON intervals (kind, end);
+		  CREATE TABLE intervals_meta (max_event_gap INTEGER NOT NULL);
+		`); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO intervals_meta (max_event_gap) VALUES (?)`,
+			MaxEventGap); err != nil {
+			return err
+		}
+		_, err := buildIntervals(tx, nil, "1")
+		return err
+	},
+
+	// 8 -> 9: store the seconds worked on each label in each hour, day and week
+	// (see rollup.go), computed from the per-label intervals
+	func(tx *sql.Tx, _ string) error {
+		if _, err := tx.Exec(`
+		  CREATE TABLE rollups (
+		    period TEXT NOT NULL,
+		    bucket INTEGER NOT NULL,
+		    label TEXT NOT NULL,
+		    seconds INTEGER NOT NULL,
+		    PRIMARY KEY (period, bucket, label)
+		  ) WITHOUT ROWID;
+		`); err != nil {
+			return err
+		}
+		rows, err := tx.Query(`SELECT start, end, label FROM intervals WHERE kind = ?`, labelIntervals)
+		if err != nil {
+			return err
+		}
+		rollups := make(rollupDelta)
+		for rows.Next() {
+			var i Interval
+			if err := rows.Scan(&i.Start, &i.End, &i.Label); err != nil {
+				rows.Close()
+				return err
+			}
+			if err := rollups.add(i, 1); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return rollups.apply(tx)
+	},
+}
+
+// initSchema creates the 'ticks' table (if it doesn't exist) and then applies
+// any migrations that haven't been applied to 'db' yet
+func initSchema(db *sql.DB, host string) error {
+	// Take advantage of sqlite INTEGER PRIMARY KEY table for fast range scan of
+	// ticks: https://sqlite.org/lang_createtable.html#rowid
+	if _, err := db.Exec(
+		`CREATE TABLE IF NOT EXISTS ticks (time INTEGER PRIMARY KEY ASC, labels TEXT)`,
+	); err != nil {
+		return err
+	}
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return fmt.Errorf("could not read schema version: %v", err)
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("DB has schema version %d, but this version of "+
+			"time-tracker only understands versions <= %d", version, len(migrations))
+	}
+	for ; version < len(migrations); version++ {
+		glog.Infof("migrating DB from schema version %d to %d", version, version+1)
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := migrations[version](tx, host); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("could not migrate DB to schema version %d: %v", version+1, err)
+		}
+		// PRAGMA doesn't accept bound parameters
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+// sync.go merges ticks from other time-tracker servers (e.g. the same user's
+// laptop and desktop) into this server's DB. Ticks are append-only and
+// identified by (time, host, label), so merging is just a union of tick sets,
+// and each tick keeps the host that originally received it so that either
+// machine's view can be rebuilt (see GetIntervalsRequest.Host)
+
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Export returns the ticks in the server's DB, sorted by time
+func (s *server) Export(req *ExportRequest) (*TickSet, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var rows *sql.Rows
+	var err error
+	if req.Host != "" {
+		rows, err = s.db.Query("SELECT time, labels, zone, host FROM ticks "+
+			"WHERE host = ? ORDER BY time, id", req.Host)
+	} else {
+		rows, err = s.db.Query("SELECT time, labels, zone, host FROM ticks ORDER BY time, id")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return scanTicks(rows, time.Nanosecond)
+}
+
+// scanTicks reads (time, labels, zone, host) rows into a TickSet. 'unit' is
+// the unit of the 'time' column (time.Second in DBs with schema version < 5)
+func scanTicks(rows *sql.Rows, unit time.Duration) (*TickSet, error) {
+	defer rows.Close()
+	result := &TickSet{}
+	for rows.Next() {
+		var t TickRecord
+		var escapedLabel string
+		var tickTime int64
+		if err := rows.Scan(&tickTime, &escapedLabel, &t.Zone, &t.Host); err != nil {
+			return nil, err
+		}
+		tickTime *= int64(unit)
+		t.Time, t.Nanos = tickTime/int64(time.Second), tickTime%int64(time.Second)
+		t.Label = UnescapeLabel(escapedLabel)
+		result.Ticks = append(result.Ticks, t)
+	}
+	return result, rows.Err()
+}
+
+// Sync merges the ticks in 'req' into the server's DB
+func (s *server) Sync(req *TickSet) (*SyncResponse, error) {
+	resp, err := s.mergeTicks(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Added > 0 {
+		s.historyChanged()
+	}
+	return resp, nil
+}
+
+// mergeTicks inserts the ticks in 'req' into the DB in one transaction,
+// skipping ticks that are already present
+func (s *server) mergeTicks(req *TickSet) (*SyncResponse, error) {
+	for _, t := range req.Ticks {
+		if t.Label == "" {
+			return nil, fmt.Errorf("tick at %d has no label", t.Time)
+		}
+		if t.Host == "" {
+			return nil, fmt.Errorf("tick at %d has no host", t.Time)
+		}
+		if t.Nanos < 0 || t.Nanos >= int64(time.Second) {
+			return nil, fmt.Errorf("tick at %d has invalid nanoseconds %d", t.Time, t.Nanos)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // no-op after Commit
+	insert, err := tx.Prepare(
+		"INSERT OR IGNORE INTO ticks (time, labels, zone, host) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return nil, err
+	}
+	defer insert.Close()
+	resp := &SyncResponse{}
+	var first, last int64 // the range of added ticks, whose intervals change
+	for _, t := range req.Ticks {
+		result, err := insert.Exec(t.Time*int64(time.Second)+t.Nanos,
+			EscapeLabel(t.Label), t.Zone, t.Host)
+		if err != nil {
+			return nil, err
+		}
+		// If no row was inserted, the tick was merged previously
+		if n, _ := result.RowsAffected(); n == 0 {
+			resp.Duplicates++
+			continue
+		}
+		if resp.Added == 0 || t.Time < first {
+			first = t.Time
+		}
+		if resp.Added == 0 || t.Time > last {
+			last = t.Time
+		}
+		resp.Added++
+	}
+	if resp.Added > 0 {
+		if _, err := rebuildIntervals(tx, first, last); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ReadTickDB reads all ticks from the time-tracker DB at 'dbPath' (e.g. a DB
+// copied from another machine), without modifying it. Ticks in DBs that
+// predate host tracking are attributed to 'defaultHost'
+func ReadTickDB(dbPath, defaultHost string) (*TickSet, error) {
+	db, err := sql.Open("sqlite3", "file:"+dbPath+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	// Older DBs may be missing columns
+	columns := make(map[string]bool)
+	rows, err := db.Query("PRAGMA table_info(ticks)")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, colType    string
+			defaultValue     sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		columns[name] = true
+	}
+	rows.Close()
+	if !columns["time"] || !columns["labels"] {
+		return nil, fmt.Errorf("%s is not a time-tracker DB (it has no ticks table)", dbPath)
+	}
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return nil, err
+	}
+	unit := time.Second
+	if version >= 5 {
+		unit = time.Nanosecond
+	}
+	zoneCol, hostCol := "''", "''"
+	if columns["zone"] {
+		zoneCol = "zone"
+	}
+	if columns["host"] {
+		hostCol = "host"
+	}
+	rows, err = db.Query(fmt.Sprintf(
+		"SELECT time, labels, %s, %s FROM ticks ORDER BY time", zoneCol, hostCol))
+	if err != nil {
+		return nil, err
+	}
+	result, err := scanTicks(rows, unit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range result.Ticks {
+		if result.Ticks[i].Host == "" {
+			if defaultHost == "" {
+				return nil, fmt.Errorf("ticks in %s don't record which host "+
+					"received them; must provide a default host", dbPath)
+			}
+			result.Ticks[i].Host = defaultHost
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,106 @@
+// ticks.go lists raw ticks, a page at a time, for debugging the intervals that
+// they form. Pages are keyed by the (time, id) of the last tick on the
+// previous page, so listing stays fast however far into a range it gets, and
+// isn't thrown off by ticks that arrive between pages
+
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTicksLimit is the number of ticks that ListTicks returns if the
+	// request has no Limit
+	DefaultTicksLimit = 1000
+
+	// MaxTicksLimit is the most ticks that ListTicks returns
+	MaxTicksLimit = 10000
+)
+
+// encodeCursor returns the cursor that lists the ticks after the tick with the
+// given time (in nanoseconds) and id
+func encodeCursor(nanos, id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d,%d", nanos, id)))
+}
+
+// decodeCursor parses a cursor returned by encodeCursor
+func decodeCursor(cursor string) (nanos, id int64, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	parts := strings.Split(string(b), ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	if nanos, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	if id, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return nanos, id, nil
+}
+
+// ListTicks returns a page of the ticks selected by 'req', ordered by time
+func (s *server) ListTicks(req *ListTicksRequest) (*ListTicksResponse, error) {
+	if req.End <= req.Start {
+		return nil, fmt.Errorf("invalid range: end (%d) must be after start (%d)", req.End, req.Start)
+	}
+	limit := req.Limit
+	switch {
+	case limit < 0:
+		return nil, fmt.Errorf("invalid limit %d", limit)
+	case limit == 0:
+		limit = DefaultTicksLimit
+	case limit > MaxTicksLimit:
+		limit = MaxTicksLimit
+	}
+	where, args := tickRange(req.Start, req.End, req.Label)
+	if req.Host != "" {
+		where += " AND host = ?"
+		args = append(args, req.Host)
+	}
+	if req.Cursor != "" {
+		nanos, id, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		where += " AND (time > ? OR (time = ? AND id > ?))"
+		args = append(args, nanos, nanos, id)
+	}
+	// Read one extra tick, to find out whether there's another page
+	args = append(args, limit+1)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rows, err := s.db.Query(`SELECT id, time, labels, zone, host FROM ticks WHERE `+where+
+		` ORDER BY time, id LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	resp := &ListTicksResponse{Ticks: []StoredTick{}}
+	var lastNanos int64
+	for rows.Next() {
+		if len(resp.Ticks) == limit {
+			last := resp.Ticks[len(resp.Ticks)-1]
+			resp.NextCursor = encodeCursor(lastNanos, last.ID)
+			break
+		}
+		var t StoredTick
+		var escapedLabel string
+		if err := rows.Scan(&t.ID, &lastNanos, &escapedLabel, &t.Zone, &t.Host); err != nil {
+			return nil, err
+		}
+		t.Time, t.Nanos = lastNanos/int64(time.Second), lastNanos%int64(time.Second)
+		t.Label = UnescapeLabel(escapedLabel)
+		resp.Ticks = append(resp.Ticks, t)
+	}
+	return resp, rows.Err()
+}
@@ -0,0 +1,58 @@
+// token.go creates and checks the bearer tokens that authenticate requests
+// made over time-tracker's (optional) TCP listener. Tokens are random strings
+// given to the user once; only their SHA-256 hashes are stored in the DB
+
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+)
+
+// tokenBytes is the number of random bytes in each token
+const tokenBytes = 32
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateToken generates a new bearer token and stores its hash
+func (s *server) CreateToken(req *CreateTokenRequest) (*CreateTokenResponse, error) {
+	buf := make([]byte, tokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("could not generate token: %v", err)
+	}
+	token := hex.EncodeToString(buf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.db.Exec("INSERT INTO tokens (hash, name, user, created) VALUES (?, ?, ?, ?)",
+		hashToken(token), req.Name, req.User, s.clock.Now().Unix()); err != nil {
+		return nil, err
+	}
+	return &CreateTokenResponse{Token: token}, nil
+}
+
+// LookupToken returns the info of 'token' if it was created by CreateToken, or
+// nil otherwise
+func (s *server) LookupToken(token string) (*TokenInfo, error) {
+	if token == "" {
+		return nil, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var info TokenInfo
+	err := s.db.QueryRow("SELECT name, user FROM tokens WHERE hash = ?",
+		hashToken(token)).Scan(&info.Name, &info.User)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	return &info, nil
+}
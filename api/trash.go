@@ -0,0 +1,324 @@
+// trash.go implements soft deletes. Operations that remove or rewrite ticks
+// (Clear, DeleteTicks and Relabel) first copy the affected ticks into the
+// 'trash' table, grouped into a batch per operation, so that any one
+// operation can be undone with Undo. Batches are purged once they're older
+// than TrashRetention, by PurgeTrash (which the daemon calls on a schedule) or
+// the next time that the trash is read or written, whichever comes first
+
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// TrashRetention is how long trashed ticks are kept before they're purged
+const TrashRetention = 30 * 24 * time.Hour
+
+// tickRange returns a SQL condition (and its arguments) matching the ticks in
+// [start, end) (in seconds since epoch) with the label 'label' (or any label,
+// if 'label' is "")
+func tickRange(start, end int64, label string) (string, []interface{}) {
+	where := "time >= ? AND time < ?"
+	args := []interface{}{toNanos(start), toNanos(end)}
+	if label != "" {
+		where += " AND labels = ?"
+		args = append(args, EscapeLabel(label))
+	}
+	return where, args
+}
+
+// moveToTrash creates a trash batch described by 'description', and copies
+// the ticks matching 'where' into it. It doesn't remove them from 'ticks'.
+// The returned batch has ID 0 if no ticks matched (and then no batch is
+// created)
+func (s *server) moveToTrash(tx *sql.Tx, description, where string, args ...interface{}) (*TrashBatch, error) {
+	if err := s.purgeTrash(tx); err != nil {
+		return nil, err
+	}
+	now := s.clock.Now().Unix()
+	res, err := tx.Exec(`INSERT INTO trash_batches (created, description) VALUES (?, ?)`,
+		now, description)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	res, err = tx.Exec(`INSERT INTO trash (batch, tick_id, time, labels, zone, host) `+
+		`SELECT ?, id, time, labels, zone, host FROM ticks WHERE `+where,
+		append([]interface{}{id}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		_, err := tx.Exec(`DELETE FROM trash_batches WHERE id = ?`, id)
+		return &TrashBatch{}, err
+	}
+	return &TrashBatch{ID: id, Created: now, Description: description, Ticks: int(n)}, nil
+}
+
+// inTx runs 'f' in a transaction (committing it if 'f' succeeds) while
+// holding s.mu
+func (s *server) inTx(f func(tx *sql.Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Clear moves every tick to the trash
+func (s *server) Clear() error {
+	err := s.inTx(func(tx *sql.Tx) error {
+		if _, err := s.moveToTrash(tx, "clear", "1"); err != nil {
+			return err
+		}
+		// Delete rows rather than dropping the table, so that the table keeps
+		// the columns added by migrations
+		if _, err := tx.Exec(`DELETE FROM ticks`); err != nil {
+			return err
+		}
+		_, err := rebuildAllIntervals(tx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.historyChanged()
+	return nil
+}
+
+// DeleteTicks moves the ticks selected by 'req' to the trash
+func (s *server) DeleteTicks(req *DeleteTicksRequest) (*TrashBatch, error) {
+	if req.End <= req.Start {
+		return nil, fmt.Errorf("invalid range: end (%d) must be after start (%d)", req.End, req.Start)
+	}
+	var batch *TrashBatch
+	err := s.inTx(func(tx *sql.Tx) error {
+		where, args := tickRange(req.Start, req.End, req.Label)
+		var err error
+		description := fmt.Sprintf("delete %s", describeRange(req.Start, req.End, req.Label))
+		if batch, err = s.moveToTrash(tx, description, where, args...); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM ticks WHERE id IN `+
+			`(SELECT tick_id FROM trash WHERE batch = ?)`, batch.ID); err != nil {
+			return err
+		}
+		_, err = rebuildIntervals(tx, req.Start, req.End)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if batch.Ticks > 0 {
+		s.historyChanged()
+	}
+	return batch, nil
+}
+
+// Relabel changes the label of the ticks selected by 'req', after copying the
+// original ticks to the trash
+func (s *server) Relabel(req *RelabelRequest) (*TrashBatch, error) {
+	if req.End <= req.Start {
+		return nil, fmt.Errorf("invalid range: end (%d) must be after start (%d)", req.End, req.Start)
+	}
+	if req.To == "" {
+		return nil, fmt.Errorf("must give the new label")
+	}
+	var batch *TrashBatch
+	err := s.inTx(func(tx *sql.Tx) error {
+		where, args := tickRange(req.Start, req.End, req.From)
+		where += " AND labels != ?"
+		args = append(args, EscapeLabel(req.To))
+		var err error
+		description := fmt.Sprintf("relabel %s as %q",
+			describeRange(req.Start, req.End, req.From), req.To)
+		if batch, err = s.moveToTrash(tx, description, where, args...); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE OR IGNORE ticks SET labels = ? WHERE id IN `+
+			`(SELECT tick_id FROM trash WHERE batch = ?)`, EscapeLabel(req.To), batch.ID); err != nil {
+			return err
+		}
+		// Ticks that couldn't be relabelled are duplicates of ticks that already
+		// have the new label
+		if _, err := tx.Exec(`DELETE FROM ticks WHERE labels != ? AND id IN `+
+			`(SELECT tick_id FROM trash WHERE batch = ?)`, EscapeLabel(req.To), batch.ID); err != nil {
+			return err
+		}
+		_, err = rebuildIntervals(tx, req.Start, req.End)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if batch.Ticks > 0 {
+		s.historyChanged()
+	}
+	return batch, nil
+}
+
+// describeRange describes the ticks selected by DeleteTicks or Relabel, for
+// the description of their trash batch
+func describeRange(start, end int64, label string) string {
+	const format = "2006-01-02 15:04:05"
+	desc := fmt.Sprintf("%s to %s", time.Unix(start, 0).Format(format), time.Unix(end, 0).Format(format))
+	if label != "" {
+		desc = fmt.Sprintf("%q ticks from %s", label, desc)
+	}
+	return desc
+}
+
+// ListTrash returns the batches in the trash, oldest first
+func (s *server) ListTrash() (*ListTrashResponse, error) {
+	if err := s.PurgeTrash(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rows, err := s.db.Query(`SELECT b.id, b.created, b.description, COUNT(t.batch) ` +
+		`FROM trash_batches b LEFT JOIN trash t ON t.batch = b.id GROUP BY b.id ORDER BY b.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	resp := &ListTrashResponse{Batches: []TrashBatch{}}
+	for rows.Next() {
+		var b TrashBatch
+		if err := rows.Scan(&b.ID, &b.Created, &b.Description, &b.Ticks); err != nil {
+			return nil, err
+		}
+		resp.Batches = append(resp.Batches, b)
+	}
+	return resp, rows.Err()
+}
+
+// Undo restores the ticks in the trash batch req.Batch to the state they were
+// in before the batch's operation, and removes the batch from the trash. Ticks
+// that collide with a tick added since are left out, and listed in the
+// response
+func (s *server) Undo(req *UndoRequest) (*UndoResponse, error) {
+	resp := &UndoResponse{Skipped: []TickRecord{}}
+	err := s.inTx(func(tx *sql.Tx) error {
+		if err := s.purgeTrash(tx); err != nil {
+			return err
+		}
+		var exists int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM trash_batches WHERE id = ?`,
+			req.Batch).Scan(&exists); err != nil {
+			return err
+		}
+		if exists == 0 {
+			return fmt.Errorf("there is no batch %d in the trash", req.Batch)
+		}
+		type trashed struct {
+			id, time           int64
+			labels, zone, host string
+		}
+		var ticks []trashed
+		rows, err := tx.Query(`SELECT tick_id, time, labels, zone, host FROM trash `+
+			`WHERE batch = ?`, req.Batch)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var t trashed
+			if err := rows.Scan(&t.id, &t.time, &t.labels, &t.zone, &t.host); err != nil {
+				rows.Close()
+				return err
+			}
+			ticks = append(ticks, t)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, t := range ticks {
+			// Relabelled ticks are still in 'ticks', and just need their label
+			// back. Deleted ticks are re-inserted (ids of deleted ticks may have
+			// been reused, hence the check of 'time' and 'host')
+			res, err := tx.Exec(`UPDATE OR IGNORE ticks SET labels = ? `+
+				`WHERE id = ? AND time = ? AND host = ?`, t.labels, t.id, t.time, t.host)
+			if err != nil {
+				return err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				// The tick was deleted (or its UPDATE collided with a tick that has
+				// its old label, in which case so will this INSERT)
+				res, err = tx.Exec(`INSERT OR IGNORE INTO ticks (time, labels, zone, host) `+
+					`VALUES (?, ?, ?, ?)`, t.time, t.labels, t.zone, t.host)
+				if err != nil {
+					return err
+				}
+				if n, err = res.RowsAffected(); err != nil {
+					return err
+				}
+			}
+			if n > 0 {
+				resp.Restored++
+				continue
+			}
+			resp.Skipped = append(resp.Skipped, TickRecord{
+				Time:  t.time / int64(time.Second),
+				Nanos: t.time % int64(time.Second),
+				Label: UnescapeLabel(t.labels),
+				Zone:  t.zone,
+				Host:  t.host,
+			})
+		}
+		if _, err := tx.Exec(`DELETE FROM trash WHERE batch = ?`, req.Batch); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM trash_batches WHERE id = ?`, req.Batch); err != nil {
+			return err
+		}
+		if len(ticks) == 0 {
+			return nil
+		}
+		first, last := ticks[0].time, ticks[0].time
+		for _, t := range ticks {
+			first, last = min(first, t.time), max(last, t.time)
+		}
+		_, err = rebuildIntervals(tx, first/int64(time.Second), last/int64(time.Second))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.historyChanged()
+	return resp, nil
+}
+
+// PurgeTrash permanently deletes trash batches older than TrashRetention
+func (s *server) PurgeTrash() error {
+	return s.inTx(s.purgeTrash)
+}
+
+// purgeTrash implements PurgeTrash within 'tx'
+func (s *server) purgeTrash(tx *sql.Tx) error {
+	cutoff := s.clock.Now().Add(-TrashRetention).Unix()
+	if _, err := tx.Exec(`DELETE FROM trash WHERE batch IN `+
+		`(SELECT id FROM trash_batches WHERE created < ?)`, cutoff); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM trash_batches WHERE created < ?`, cutoff)
+	return err
+}
@@ -0,0 +1,95 @@
+// zone.go handles the time zones recorded with each tick, so that intervals
+// can be rendered either in the viewer's current time zone or in the time zone
+// in which the work actually happened (e.g. after travelling)
+
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// MaxZoneShift is the largest amount by which InRecordedZone can move an
+// interval (the difference between UTC-12:00 and UTC+14:00). Callers that want
+// to render a time range in recorded zones should request intervals this far
+// beyond either side of the range, so that shifted intervals aren't missed.
+const MaxZoneShift = 26 * time.Hour
+
+// offsetRe matches UTC offsets like "+05:30", "-0700" or "+02"
+var offsetRe = regexp.MustCompile(`^([+-])(\d{2}):?(\d{2})?$`)
+
+// LoadZone parses 'zone', which may be an IANA time zone name (e.g.
+// "America/New_York") or a UTC offset (e.g. "-07:00"), into a time.Location
+func LoadZone(zone string) (*time.Location, error) {
+	if m := offsetRe.FindStringSubmatch(zone); m != nil {
+		hours, _ := strconv.Atoi(m[2])
+		minutes := 0
+		if m[3] != "" {
+			minutes, _ = strconv.Atoi(m[3])
+		}
+		if hours > 14 || minutes > 59 {
+			return nil, fmt.Errorf("invalid UTC offset %q", zone)
+		}
+		offset := hours*60*60 + minutes*60
+		if m[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(zone, offset), nil
+	}
+	if zone == "" || zone == "Local" {
+		// time.LoadLocation accepts these, but they don't identify a zone
+		return nil, fmt.Errorf("%q is not an IANA time zone name or UTC offset", zone)
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not an IANA time zone name or UTC offset: %v", zone, err)
+	}
+	return loc, nil
+}
+
+// LocalZone returns the UTC offset of the local time zone at time 't', in the
+// form accepted by LoadZone (e.g. "-07:00")
+func LocalZone(t time.Time) string {
+	return t.Local().Format("-07:00")
+}
+
+// InRecordedZone shifts each interval in 'intervals' so that, when rendered in
+// 'loc', it appears at the wall-clock time at which it was recorded. For
+// example, an interval recorded at 9am in Tokyo is moved to 9am in 'loc'.
+// Intervals with no recorded zone (or an unrecognized one) are not moved. The
+// result is sorted by (shifted) start time.
+func InRecordedZone(intervals []Interval, loc *time.Location) []Interval {
+	result := make([]Interval, 0, len(intervals))
+	for _, i := range intervals {
+		if zoneLoc, err := LoadZone(i.Zone); err == nil {
+			start := time.Unix(i.Start, 0)
+			_, recordedOffset := start.In(zoneLoc).Zone()
+			_, viewerOffset := start.In(loc).Zone()
+			shift := int64(recordedOffset - viewerOffset)
+			i.Start += shift
+			i.End += shift
+		}
+		result = append(result, i)
+	}
+	sort.Slice(result, func(a, b int) bool {
+		return result[a].Start < result[b].Start
+	})
+	return result
+}
+
+// ClampIntervals truncates each interval in 'intervals' to [start, end],
+// dropping intervals that don't overlap it
+func ClampIntervals(intervals []Interval, start, end int64) []Interval {
+	result := make([]Interval, 0, len(intervals))
+	for _, i := range intervals {
+		i.Start, i.End = max(i.Start, start), min(i.End, end)
+		if i.End <= i.Start {
+			continue
+		}
+		result = append(result, i)
+	}
+	return result
+}
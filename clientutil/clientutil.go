@@ -32,6 +32,25 @@ func (c *Client) Post(dest string, body io.Reader) (*http.Response, error) {
 	return c.Client.Post(c.url(dest), "application/json", body)
 }
 
+func (c *Client) Put(dest string, body io.Reader) (*http.Response, error) {
+	return c.do("PUT", dest, body)
+}
+
+func (c *Client) Delete(dest string, body io.Reader) (*http.Response, error) {
+	return c.do("DELETE", dest, body)
+}
+
+// do sends a request with a JSON body, for methods that http.Client doesn't
+// have a helper for
+func (c *Client) do(method, dest string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.url(dest), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.Client.Do(req)
+}
+
 func GetClient(socketPath string) *Client {
 	// Create the HTTP client and return it
 	return &Client{
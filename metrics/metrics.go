@@ -0,0 +1,195 @@
+// Package metrics is a minimal implementation of Prometheus-style metrics:
+// counters and histograms that are written in the text exposition format
+// (https://prometheus.io/docs/instrumenting/exposition_formats/). It only has
+// what the time-tracker server needs, so that no client library needs to be
+// vendored
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// metric is a counter or histogram, which writes itself (including its HELP
+// and TYPE comments) in the text exposition format
+type metric interface {
+	write(w io.Writer)
+}
+
+// Registry is a set of metrics, which are written together by WriteText
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// Default is the registry to which NewCounterVec and NewHistogram add metrics
+var Default = &Registry{}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteText writes every metric in 'r' to 'w', in the order in which they were
+// created
+func (r *Registry) WriteText(w io.Writer) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// writeHeader writes the HELP and TYPE comments that precede a metric's samples
+func writeHeader(w io.Writer, name, help, typ string) {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// formatLabels formats label names and values as '{name="value",...}' (or ""
+// if there are no labels)
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	pairs := make([]string, len(names))
+	for i := range names {
+		pairs[i] = names[i] + `="` + escape.Replace(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatValue formats a sample's value, using the exposition format's
+// spellings of infinity
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// WriteGauge writes a single gauge sample (with its HELP and TYPE comments) to
+// 'w'. Gauges whose value is computed when metrics are scraped (e.g. the size
+// of a file) are written this way, rather than being stored in a Registry
+func WriteGauge(w io.Writer, name, help string, value float64) {
+	writeHeader(w, name, help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", name, formatValue(value))
+}
+
+// CounterVec is a set of counters with the same name, distinguished by the
+// values of their labels
+type CounterVec struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]float64  // by label values, joined with "\xff"
+	keys   map[string][]string // label values, by key in 'values'
+}
+
+// NewCounterVec returns a new CounterVec, and adds it to Default
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]float64),
+		keys:   make(map[string][]string),
+	}
+	Default.register(c)
+	return c
+}
+
+// Inc increments the counter with the given label values, which must be in
+// the same order as the CounterVec's labels
+func (c *CounterVec) Inc(values ...string) {
+	if len(values) != len(c.labels) {
+		panic(fmt.Sprintf("%s has %d labels, but got %d values", c.name, len(c.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.keys[key]; !ok {
+		c.keys[key] = append([]string(nil), values...)
+	}
+	c.values[key]++
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeHeader(w, c.name, c.help, "counter")
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, c.keys[k]),
+			formatValue(c.values[k]))
+	}
+}
+
+// Histogram counts observations (e.g. latencies) in buckets
+type Histogram struct {
+	name, help string
+	buckets    []float64 // upper bounds, ascending (+Inf is implied)
+
+	mu     sync.Mutex
+	counts []uint64 // observations in each bucket (not cumulative)
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram returns a new Histogram with the given bucket upper bounds
+// (which must be ascending), and adds it to Default
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := &Histogram{
+		name:    name,
+		help:    help,
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+	Default.register(h)
+	return h
+}
+
+// Observe adds 'v' to the histogram
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		h.counts[i]++
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	var cumulative uint64
+	for i, le := range h.buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.name, formatValue(le), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", h.name, formatValue(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", h.name, h.count)
+}
+
+// LatencyBuckets are histogram buckets (in seconds) suited to the latency of
+// DB queries and other local operations
+var LatencyBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5}
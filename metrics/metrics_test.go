@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := &Registry{}
+	c := &CounterVec{
+		name:   "ticks_total",
+		help:   "Ticks",
+		labels: []string{"label"},
+		values: make(map[string]float64),
+		keys:   make(map[string][]string),
+	}
+	r.register(c)
+	h := &Histogram{
+		name:    "latency_seconds",
+		help:    "Latency",
+		buckets: []float64{0.1, 1},
+		counts:  make([]uint64, 2),
+	}
+	r.register(h)
+
+	c.Inc("b")
+	c.Inc(`a "quoted" label`)
+	c.Inc("b")
+	h.Observe(0.1)
+	h.Observe(0.5)
+	h.Observe(2)
+
+	buf := &bytes.Buffer{}
+	r.WriteText(buf)
+	// (testutil can't be used here, as it imports api, which imports metrics)
+	expected := `# HELP ticks_total Ticks
+# TYPE ticks_total counter
+ticks_total{label="a \"quoted\" label"} 1
+ticks_total{label="b"} 2
+# HELP latency_seconds Latency
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 2.6
+latency_seconds_count 3
+`
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, buf.String())
+	}
+}
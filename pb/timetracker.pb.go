@@ -0,0 +1,837 @@
+// timetracker.proto describes the time-tracker API as a gRPC service, which
+// the daemon serves on a second unix socket (see server/grpc.go). Messages
+// mirror the request and response structs in api/api.go; keep the two in sync
+// and regenerate timetracker.pb.go and timetracker_grpc.pb.go with
+// 'make proto' after changing this file.
+//
+// Errors have status codes: INVALID_ARGUMENT for bad requests,
+// PERMISSION_DENIED if the caller can't be identified (in multi-user mode),
+// and INTERNAL for failures on the server's side.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: timetracker.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type TickRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The label (i.e. task) on which the caller is working. Required
+	Label string `protobuf:"bytes,1,opt,name=label,proto3" json:"label,omitempty"`
+	// IANA zone name (e.g. "America/New_York") or UTC offset (e.g. "-07:00")
+	Zone string `protobuf:"bytes,2,opt,name=zone,proto3" json:"zone,omitempty"`
+	// The client that sent the tick (e.g. an editor plugin's name)
+	Source        string `protobuf:"bytes,3,opt,name=source,proto3" json:"source,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TickRequest) Reset() {
+	*x = TickRequest{}
+	mi := &file_timetracker_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TickRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TickRequest) ProtoMessage() {}
+
+func (x *TickRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_timetracker_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TickRequest.ProtoReflect.Descriptor instead.
+func (*TickRequest) Descriptor() ([]byte, []int) {
+	return file_timetracker_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *TickRequest) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *TickRequest) GetZone() string {
+	if x != nil {
+		return x.Zone
+	}
+	return ""
+}
+
+func (x *TickRequest) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+type TickResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TickResponse) Reset() {
+	*x = TickResponse{}
+	mi := &file_timetracker_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TickResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TickResponse) ProtoMessage() {}
+
+func (x *TickResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_timetracker_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TickResponse.ProtoReflect.Descriptor instead.
+func (*TickResponse) Descriptor() ([]byte, []int) {
+	return file_timetracker_proto_rawDescGZIP(), []int{1}
+}
+
+type GetIntervalsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Seconds since epoch. Intervals overlapping 'start' or 'end' are truncated
+	Start int64 `protobuf:"varint,1,opt,name=start,proto3" json:"start,omitempty"`
+	End   int64 `protobuf:"varint,2,opt,name=end,proto3" json:"end,omitempty"`
+	// If set, only use ticks received by this host
+	Host string `protobuf:"bytes,3,opt,name=host,proto3" json:"host,omitempty"`
+	// If set, intervals are split wherever the label changes
+	ByLabel       bool `protobuf:"varint,4,opt,name=by_label,json=byLabel,proto3" json:"by_label,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetIntervalsRequest) Reset() {
+	*x = GetIntervalsRequest{}
+	mi := &file_timetracker_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetIntervalsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetIntervalsRequest) ProtoMessage() {}
+
+func (x *GetIntervalsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_timetracker_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetIntervalsRequest.ProtoReflect.Descriptor instead.
+func (*GetIntervalsRequest) Descriptor() ([]byte, []int) {
+	return file_timetracker_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetIntervalsRequest) GetStart() int64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *GetIntervalsRequest) GetEnd() int64 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+func (x *GetIntervalsRequest) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *GetIntervalsRequest) GetByLabel() bool {
+	if x != nil {
+		return x.ByLabel
+	}
+	return false
+}
+
+type Interval struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         int64                  `protobuf:"varint,1,opt,name=start,proto3" json:"start,omitempty"` // seconds since epoch
+	End           int64                  `protobuf:"varint,2,opt,name=end,proto3" json:"end,omitempty"`
+	Label         string                 `protobuf:"bytes,3,opt,name=label,proto3" json:"label,omitempty"`
+	Zone          string                 `protobuf:"bytes,4,opt,name=zone,proto3" json:"zone,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Interval) Reset() {
+	*x = Interval{}
+	mi := &file_timetracker_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Interval) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Interval) ProtoMessage() {}
+
+func (x *Interval) ProtoReflect() protoreflect.Message {
+	mi := &file_timetracker_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Interval.ProtoReflect.Descriptor instead.
+func (*Interval) Descriptor() ([]byte, []int) {
+	return file_timetracker_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Interval) GetStart() int64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *Interval) GetEnd() int64 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+func (x *Interval) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *Interval) GetZone() string {
+	if x != nil {
+		return x.Zone
+	}
+	return ""
+}
+
+type GetIntervalsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Intervals     []*Interval            `protobuf:"bytes,1,rep,name=intervals,proto3" json:"intervals,omitempty"`
+	EndGap        int64                  `protobuf:"varint,2,opt,name=end_gap,json=endGap,proto3" json:"end_gap,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetIntervalsResponse) Reset() {
+	*x = GetIntervalsResponse{}
+	mi := &file_timetracker_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetIntervalsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetIntervalsResponse) ProtoMessage() {}
+
+func (x *GetIntervalsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_timetracker_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetIntervalsResponse.ProtoReflect.Descriptor instead.
+func (*GetIntervalsResponse) Descriptor() ([]byte, []int) {
+	return file_timetracker_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetIntervalsResponse) GetIntervals() []*Interval {
+	if x != nil {
+		return x.Intervals
+	}
+	return nil
+}
+
+func (x *GetIntervalsResponse) GetEndGap() int64 {
+	if x != nil {
+		return x.EndGap
+	}
+	return 0
+}
+
+type SummaryRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Seconds since epoch. Every bucket that overlaps the range is returned
+	Start int64 `protobuf:"varint,1,opt,name=start,proto3" json:"start,omitempty"`
+	End   int64 `protobuf:"varint,2,opt,name=end,proto3" json:"end,omitempty"`
+	// "hour", "day" (the default) or "week"
+	Period string `protobuf:"bytes,3,opt,name=period,proto3" json:"period,omitempty"`
+	// If set, only time worked on this label is counted
+	Label string `protobuf:"bytes,4,opt,name=label,proto3" json:"label,omitempty"`
+	// If set, each bucket also has its Focus
+	Focus         bool `protobuf:"varint,5,opt,name=focus,proto3" json:"focus,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SummaryRequest) Reset() {
+	*x = SummaryRequest{}
+	mi := &file_timetracker_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SummaryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SummaryRequest) ProtoMessage() {}
+
+func (x *SummaryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_timetracker_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SummaryRequest.ProtoReflect.Descriptor instead.
+func (*SummaryRequest) Descriptor() ([]byte, []int) {
+	return file_timetracker_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SummaryRequest) GetStart() int64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *SummaryRequest) GetEnd() int64 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+func (x *SummaryRequest) GetPeriod() string {
+	if x != nil {
+		return x.Period
+	}
+	return ""
+}
+
+func (x *SummaryRequest) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *SummaryRequest) GetFocus() bool {
+	if x != nil {
+		return x.Focus
+	}
+	return false
+}
+
+// Focus describes how fragmented the work in a bucket was
+type Focus struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Switches int64                  `protobuf:"varint,1,opt,name=switches,proto3" json:"switches,omitempty"`
+	Longest  int64                  `protobuf:"varint,2,opt,name=longest,proto3" json:"longest,omitempty"` // seconds
+	Median   int64                  `protobuf:"varint,3,opt,name=median,proto3" json:"median,omitempty"`   // seconds
+	// Fraction of worked time in intervals longer than 50 minutes
+	DeepShare     float64 `protobuf:"fixed64,4,opt,name=deep_share,json=deepShare,proto3" json:"deep_share,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Focus) Reset() {
+	*x = Focus{}
+	mi := &file_timetracker_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Focus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Focus) ProtoMessage() {}
+
+func (x *Focus) ProtoReflect() protoreflect.Message {
+	mi := &file_timetracker_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Focus.ProtoReflect.Descriptor instead.
+func (*Focus) Descriptor() ([]byte, []int) {
+	return file_timetracker_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Focus) GetSwitches() int64 {
+	if x != nil {
+		return x.Switches
+	}
+	return 0
+}
+
+func (x *Focus) GetLongest() int64 {
+	if x != nil {
+		return x.Longest
+	}
+	return 0
+}
+
+func (x *Focus) GetMedian() int64 {
+	if x != nil {
+		return x.Median
+	}
+	return 0
+}
+
+func (x *Focus) GetDeepShare() float64 {
+	if x != nil {
+		return x.DeepShare
+	}
+	return 0
+}
+
+type SummaryBucket struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         int64                  `protobuf:"varint,1,opt,name=start,proto3" json:"start,omitempty"` // seconds since epoch
+	End           int64                  `protobuf:"varint,2,opt,name=end,proto3" json:"end,omitempty"`
+	Seconds       map[string]int64       `protobuf:"bytes,3,rep,name=seconds,proto3" json:"seconds,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"` // seconds worked, by label
+	Total         int64                  `protobuf:"varint,4,opt,name=total,proto3" json:"total,omitempty"`
+	Focus         *Focus                 `protobuf:"bytes,5,opt,name=focus,proto3" json:"focus,omitempty"` // only set if the request's 'focus' was set
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SummaryBucket) Reset() {
+	*x = SummaryBucket{}
+	mi := &file_timetracker_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SummaryBucket) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SummaryBucket) ProtoMessage() {}
+
+func (x *SummaryBucket) ProtoReflect() protoreflect.Message {
+	mi := &file_timetracker_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SummaryBucket.ProtoReflect.Descriptor instead.
+func (*SummaryBucket) Descriptor() ([]byte, []int) {
+	return file_timetracker_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SummaryBucket) GetStart() int64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *SummaryBucket) GetEnd() int64 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+func (x *SummaryBucket) GetSeconds() map[string]int64 {
+	if x != nil {
+		return x.Seconds
+	}
+	return nil
+}
+
+func (x *SummaryBucket) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *SummaryBucket) GetFocus() *Focus {
+	if x != nil {
+		return x.Focus
+	}
+	return nil
+}
+
+type SummaryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Buckets       []*SummaryBucket       `protobuf:"bytes,1,rep,name=buckets,proto3" json:"buckets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SummaryResponse) Reset() {
+	*x = SummaryResponse{}
+	mi := &file_timetracker_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SummaryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SummaryResponse) ProtoMessage() {}
+
+func (x *SummaryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_timetracker_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SummaryResponse.ProtoReflect.Descriptor instead.
+func (*SummaryResponse) Descriptor() ([]byte, []int) {
+	return file_timetracker_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SummaryResponse) GetBuckets() []*SummaryBucket {
+	if x != nil {
+		return x.Buckets
+	}
+	return nil
+}
+
+type EventsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EventsRequest) Reset() {
+	*x = EventsRequest{}
+	mi := &file_timetracker_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EventsRequest) ProtoMessage() {}
+
+func (x *EventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_timetracker_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EventsRequest.ProtoReflect.Descriptor instead.
+func (*EventsRequest) Descriptor() ([]byte, []int) {
+	return file_timetracker_proto_rawDescGZIP(), []int{9}
+}
+
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// "tick-received", "interval-started", "interval-ended" or
+	// "day-total-changed"
+	Type          string    `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Time          int64     `protobuf:"varint,2,opt,name=time,proto3" json:"time,omitempty"`                         // seconds since epoch
+	Label         string    `protobuf:"bytes,3,opt,name=label,proto3" json:"label,omitempty"`                        // tick-received only
+	Interval      *Interval `protobuf:"bytes,4,opt,name=interval,proto3" json:"interval,omitempty"`                  // interval-started and interval-ended only
+	DayTotal      int64     `protobuf:"varint,5,opt,name=day_total,json=dayTotal,proto3" json:"day_total,omitempty"` // day-total-changed only
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_timetracker_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_timetracker_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_timetracker_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Event) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Event) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *Event) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *Event) GetInterval() *Interval {
+	if x != nil {
+		return x.Interval
+	}
+	return nil
+}
+
+func (x *Event) GetDayTotal() int64 {
+	if x != nil {
+		return x.DayTotal
+	}
+	return 0
+}
+
+var File_timetracker_proto protoreflect.FileDescriptor
+
+const file_timetracker_proto_rawDesc = "" +
+	"\n" +
+	"\x11timetracker.proto\x12\vtimetracker\"O\n" +
+	"\vTickRequest\x12\x14\n" +
+	"\x05label\x18\x01 \x01(\tR\x05label\x12\x12\n" +
+	"\x04zone\x18\x02 \x01(\tR\x04zone\x12\x16\n" +
+	"\x06source\x18\x03 \x01(\tR\x06source\"\x0e\n" +
+	"\fTickResponse\"l\n" +
+	"\x13GetIntervalsRequest\x12\x14\n" +
+	"\x05start\x18\x01 \x01(\x03R\x05start\x12\x10\n" +
+	"\x03end\x18\x02 \x01(\x03R\x03end\x12\x12\n" +
+	"\x04host\x18\x03 \x01(\tR\x04host\x12\x19\n" +
+	"\bby_label\x18\x04 \x01(\bR\abyLabel\"\\\n" +
+	"\bInterval\x12\x14\n" +
+	"\x05start\x18\x01 \x01(\x03R\x05start\x12\x10\n" +
+	"\x03end\x18\x02 \x01(\x03R\x03end\x12\x14\n" +
+	"\x05label\x18\x03 \x01(\tR\x05label\x12\x12\n" +
+	"\x04zone\x18\x04 \x01(\tR\x04zone\"d\n" +
+	"\x14GetIntervalsResponse\x123\n" +
+	"\tintervals\x18\x01 \x03(\v2\x15.timetracker.IntervalR\tintervals\x12\x17\n" +
+	"\aend_gap\x18\x02 \x01(\x03R\x06endGap\"|\n" +
+	"\x0eSummaryRequest\x12\x14\n" +
+	"\x05start\x18\x01 \x01(\x03R\x05start\x12\x10\n" +
+	"\x03end\x18\x02 \x01(\x03R\x03end\x12\x16\n" +
+	"\x06period\x18\x03 \x01(\tR\x06period\x12\x14\n" +
+	"\x05label\x18\x04 \x01(\tR\x05label\x12\x14\n" +
+	"\x05focus\x18\x05 \x01(\bR\x05focus\"t\n" +
+	"\x05Focus\x12\x1a\n" +
+	"\bswitches\x18\x01 \x01(\x03R\bswitches\x12\x18\n" +
+	"\alongest\x18\x02 \x01(\x03R\alongest\x12\x16\n" +
+	"\x06median\x18\x03 \x01(\x03R\x06median\x12\x1d\n" +
+	"\n" +
+	"deep_share\x18\x04 \x01(\x01R\tdeepShare\"\xf6\x01\n" +
+	"\rSummaryBucket\x12\x14\n" +
+	"\x05start\x18\x01 \x01(\x03R\x05start\x12\x10\n" +
+	"\x03end\x18\x02 \x01(\x03R\x03end\x12A\n" +
+	"\aseconds\x18\x03 \x03(\v2'.timetracker.SummaryBucket.SecondsEntryR\aseconds\x12\x14\n" +
+	"\x05total\x18\x04 \x01(\x03R\x05total\x12(\n" +
+	"\x05focus\x18\x05 \x01(\v2\x12.timetracker.FocusR\x05focus\x1a:\n" +
+	"\fSecondsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"G\n" +
+	"\x0fSummaryResponse\x124\n" +
+	"\abuckets\x18\x01 \x03(\v2\x1a.timetracker.SummaryBucketR\abuckets\"\x0f\n" +
+	"\rEventsRequest\"\x95\x01\n" +
+	"\x05Event\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x12\n" +
+	"\x04time\x18\x02 \x01(\x03R\x04time\x12\x14\n" +
+	"\x05label\x18\x03 \x01(\tR\x05label\x121\n" +
+	"\binterval\x18\x04 \x01(\v2\x15.timetracker.IntervalR\binterval\x12\x1b\n" +
+	"\tday_total\x18\x05 \x01(\x03R\bdayTotal2\xa1\x02\n" +
+	"\vTimeTracker\x12;\n" +
+	"\x04Tick\x12\x18.timetracker.TickRequest\x1a\x19.timetracker.TickResponse\x12S\n" +
+	"\fGetIntervals\x12 .timetracker.GetIntervalsRequest\x1a!.timetracker.GetIntervalsResponse\x12D\n" +
+	"\aSummary\x12\x1b.timetracker.SummaryRequest\x1a\x1c.timetracker.SummaryResponse\x12:\n" +
+	"\x06Events\x12\x1a.timetracker.EventsRequest\x1a\x12.timetracker.Event0\x01B,Z*github.com/msteffen/golang-time-tracker/pbb\x06proto3"
+
+var (
+	file_timetracker_proto_rawDescOnce sync.Once
+	file_timetracker_proto_rawDescData []byte
+)
+
+func file_timetracker_proto_rawDescGZIP() []byte {
+	file_timetracker_proto_rawDescOnce.Do(func() {
+		file_timetracker_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_timetracker_proto_rawDesc), len(file_timetracker_proto_rawDesc)))
+	})
+	return file_timetracker_proto_rawDescData
+}
+
+var file_timetracker_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_timetracker_proto_goTypes = []any{
+	(*TickRequest)(nil),          // 0: timetracker.TickRequest
+	(*TickResponse)(nil),         // 1: timetracker.TickResponse
+	(*GetIntervalsRequest)(nil),  // 2: timetracker.GetIntervalsRequest
+	(*Interval)(nil),             // 3: timetracker.Interval
+	(*GetIntervalsResponse)(nil), // 4: timetracker.GetIntervalsResponse
+	(*SummaryRequest)(nil),       // 5: timetracker.SummaryRequest
+	(*Focus)(nil),                // 6: timetracker.Focus
+	(*SummaryBucket)(nil),        // 7: timetracker.SummaryBucket
+	(*SummaryResponse)(nil),      // 8: timetracker.SummaryResponse
+	(*EventsRequest)(nil),        // 9: timetracker.EventsRequest
+	(*Event)(nil),                // 10: timetracker.Event
+	nil,                          // 11: timetracker.SummaryBucket.SecondsEntry
+}
+var file_timetracker_proto_depIdxs = []int32{
+	3,  // 0: timetracker.GetIntervalsResponse.intervals:type_name -> timetracker.Interval
+	11, // 1: timetracker.SummaryBucket.seconds:type_name -> timetracker.SummaryBucket.SecondsEntry
+	6,  // 2: timetracker.SummaryBucket.focus:type_name -> timetracker.Focus
+	7,  // 3: timetracker.SummaryResponse.buckets:type_name -> timetracker.SummaryBucket
+	3,  // 4: timetracker.Event.interval:type_name -> timetracker.Interval
+	0,  // 5: timetracker.TimeTracker.Tick:input_type -> timetracker.TickRequest
+	2,  // 6: timetracker.TimeTracker.GetIntervals:input_type -> timetracker.GetIntervalsRequest
+	5,  // 7: timetracker.TimeTracker.Summary:input_type -> timetracker.SummaryRequest
+	9,  // 8: timetracker.TimeTracker.Events:input_type -> timetracker.EventsRequest
+	1,  // 9: timetracker.TimeTracker.Tick:output_type -> timetracker.TickResponse
+	4,  // 10: timetracker.TimeTracker.GetIntervals:output_type -> timetracker.GetIntervalsResponse
+	8,  // 11: timetracker.TimeTracker.Summary:output_type -> timetracker.SummaryResponse
+	10, // 12: timetracker.TimeTracker.Events:output_type -> timetracker.Event
+	9,  // [9:13] is the sub-list for method output_type
+	5,  // [5:9] is the sub-list for method input_type
+	5,  // [5:5] is the sub-list for extension type_name
+	5,  // [5:5] is the sub-list for extension extendee
+	0,  // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_timetracker_proto_init() }
+func file_timetracker_proto_init() {
+	if File_timetracker_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_timetracker_proto_rawDesc), len(file_timetracker_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_timetracker_proto_goTypes,
+		DependencyIndexes: file_timetracker_proto_depIdxs,
+		MessageInfos:      file_timetracker_proto_msgTypes,
+	}.Build()
+	File_timetracker_proto = out.File
+	file_timetracker_proto_goTypes = nil
+	file_timetracker_proto_depIdxs = nil
+}
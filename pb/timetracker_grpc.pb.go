@@ -0,0 +1,261 @@
+// timetracker.proto describes the time-tracker API as a gRPC service, which
+// the daemon serves on a second unix socket (see server/grpc.go). Messages
+// mirror the request and response structs in api/api.go; keep the two in sync
+// and regenerate timetracker.pb.go and timetracker_grpc.pb.go with
+// 'make proto' after changing this file.
+//
+// Errors have status codes: INVALID_ARGUMENT for bad requests,
+// PERMISSION_DENIED if the caller can't be identified (in multi-user mode),
+// and INTERNAL for failures on the server's side.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: timetracker.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	TimeTracker_Tick_FullMethodName         = "/timetracker.TimeTracker/Tick"
+	TimeTracker_GetIntervals_FullMethodName = "/timetracker.TimeTracker/GetIntervals"
+	TimeTracker_Summary_FullMethodName      = "/timetracker.TimeTracker/Summary"
+	TimeTracker_Events_FullMethodName       = "/timetracker.TimeTracker/Events"
+)
+
+// TimeTrackerClient is the client API for TimeTracker service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type TimeTrackerClient interface {
+	// Tick records that the caller is working on a label now
+	Tick(ctx context.Context, in *TickRequest, opts ...grpc.CallOption) (*TickResponse, error)
+	// GetIntervals returns the intervals worked in a range
+	GetIntervals(ctx context.Context, in *GetIntervalsRequest, opts ...grpc.CallOption) (*GetIntervalsResponse, error)
+	// Summary returns the time worked on each label in each hour, day or week
+	// of a range
+	Summary(ctx context.Context, in *SummaryRequest, opts ...grpc.CallOption) (*SummaryResponse, error)
+	// Events streams events as ticks arrive and intervals start and end, until
+	// the caller cancels it
+	Events(ctx context.Context, in *EventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+}
+
+type timeTrackerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTimeTrackerClient(cc grpc.ClientConnInterface) TimeTrackerClient {
+	return &timeTrackerClient{cc}
+}
+
+func (c *timeTrackerClient) Tick(ctx context.Context, in *TickRequest, opts ...grpc.CallOption) (*TickResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TickResponse)
+	err := c.cc.Invoke(ctx, TimeTracker_Tick_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *timeTrackerClient) GetIntervals(ctx context.Context, in *GetIntervalsRequest, opts ...grpc.CallOption) (*GetIntervalsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetIntervalsResponse)
+	err := c.cc.Invoke(ctx, TimeTracker_GetIntervals_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *timeTrackerClient) Summary(ctx context.Context, in *SummaryRequest, opts ...grpc.CallOption) (*SummaryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SummaryResponse)
+	err := c.cc.Invoke(ctx, TimeTracker_Summary_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *timeTrackerClient) Events(ctx context.Context, in *EventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TimeTracker_ServiceDesc.Streams[0], TimeTracker_Events_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[EventsRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TimeTracker_EventsClient = grpc.ServerStreamingClient[Event]
+
+// TimeTrackerServer is the server API for TimeTracker service.
+// All implementations must embed UnimplementedTimeTrackerServer
+// for forward compatibility.
+type TimeTrackerServer interface {
+	// Tick records that the caller is working on a label now
+	Tick(context.Context, *TickRequest) (*TickResponse, error)
+	// GetIntervals returns the intervals worked in a range
+	GetIntervals(context.Context, *GetIntervalsRequest) (*GetIntervalsResponse, error)
+	// Summary returns the time worked on each label in each hour, day or week
+	// of a range
+	Summary(context.Context, *SummaryRequest) (*SummaryResponse, error)
+	// Events streams events as ticks arrive and intervals start and end, until
+	// the caller cancels it
+	Events(*EventsRequest, grpc.ServerStreamingServer[Event]) error
+	mustEmbedUnimplementedTimeTrackerServer()
+}
+
+// UnimplementedTimeTrackerServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTimeTrackerServer struct{}
+
+func (UnimplementedTimeTrackerServer) Tick(context.Context, *TickRequest) (*TickResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Tick not implemented")
+}
+func (UnimplementedTimeTrackerServer) GetIntervals(context.Context, *GetIntervalsRequest) (*GetIntervalsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetIntervals not implemented")
+}
+func (UnimplementedTimeTrackerServer) Summary(context.Context, *SummaryRequest) (*SummaryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Summary not implemented")
+}
+func (UnimplementedTimeTrackerServer) Events(*EventsRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method Events not implemented")
+}
+func (UnimplementedTimeTrackerServer) mustEmbedUnimplementedTimeTrackerServer() {}
+func (UnimplementedTimeTrackerServer) testEmbeddedByValue()                     {}
+
+// UnsafeTimeTrackerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TimeTrackerServer will
+// result in compilation errors.
+type UnsafeTimeTrackerServer interface {
+	mustEmbedUnimplementedTimeTrackerServer()
+}
+
+func RegisterTimeTrackerServer(s grpc.ServiceRegistrar, srv TimeTrackerServer) {
+	// If the following call pancis, it indicates UnimplementedTimeTrackerServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&TimeTracker_ServiceDesc, srv)
+}
+
+func _TimeTracker_Tick_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TickRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TimeTrackerServer).Tick(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TimeTracker_Tick_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TimeTrackerServer).Tick(ctx, req.(*TickRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TimeTracker_GetIntervals_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetIntervalsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TimeTrackerServer).GetIntervals(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TimeTracker_GetIntervals_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TimeTrackerServer).GetIntervals(ctx, req.(*GetIntervalsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TimeTracker_Summary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SummaryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TimeTrackerServer).Summary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TimeTracker_Summary_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TimeTrackerServer).Summary(ctx, req.(*SummaryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TimeTracker_Events_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(EventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TimeTrackerServer).Events(m, &grpc.GenericServerStream[EventsRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TimeTracker_EventsServer = grpc.ServerStreamingServer[Event]
+
+// TimeTracker_ServiceDesc is the grpc.ServiceDesc for TimeTracker service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TimeTracker_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "timetracker.TimeTracker",
+	HandlerType: (*TimeTrackerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Tick",
+			Handler:    _TimeTracker_Tick_Handler,
+		},
+		{
+			MethodName: "GetIntervals",
+			Handler:    _TimeTracker_GetIntervals_Handler,
+		},
+		{
+			MethodName: "Summary",
+			Handler:    _TimeTracker_Summary_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Events",
+			Handler:       _TimeTracker_Events_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "timetracker.proto",
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/msteffen/golang-time-tracker/api"
+)
+
+// tokenCookie is the name of the cookie in which browsers store their bearer
+// token, after logging in at /login
+const tokenCookie = "time-tracker-token"
+
+// loginPage is served at /login. Browsers can't set an Authorization header,
+// so the user pastes their token here once, and it's stored in a cookie
+const loginPage = `<!DOCTYPE html>
+<html><head><title>time-tracker login</title></head>
+<body>
+<form method="POST" action="/login">
+  <label>Token (from 't token create'): <input type="password" name="token"></label>
+  <input type="submit" value="Log in">
+</form>
+</body></html>
+`
+
+// tokenAuthHandler wraps the handler for requests that arrive over TCP (rather
+// than the unix socket). It rejects any request that doesn't carry a bearer
+// token created by 't token create'.
+//
+// Tokens may be sent in an 'Authorization: Bearer <token>' header or in the
+// 'time-tracker-token' cookie. Browsers, which can't set headers, get the
+// cookie by submitting their token at /login. Tokens are never accepted in
+// the URL, where they'd end up in logs and browser history
+type tokenAuthHandler struct {
+	server  api.APIServer
+	handler http.Handler
+}
+
+func (h tokenAuthHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/login" {
+		h.login(w, req)
+		return
+	}
+	info, err := h.server.LookupToken(requestToken(req))
+	if err != nil {
+		http.Error(w, "could not check token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if info == nil {
+		glog.Warningf("rejecting unauthenticated request from %s for %s", req.RemoteAddr, req.URL.Path)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="time-tracker"`)
+		http.Error(w, "missing or invalid bearer token", http.StatusUnauthorized)
+		return
+	}
+	// Record the token's user, for multi-user mode
+	req = req.WithContext(context.WithValue(req.Context(), tokenUserKey, info.User))
+	h.handler.ServeHTTP(w, req)
+}
+
+// login serves the login form, and sets the token cookie when the form is
+// submitted with a valid token
+func (h tokenAuthHandler) login(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(loginPage))
+	case http.MethodPost:
+		token := req.PostFormValue("token")
+		info, err := h.server.LookupToken(token)
+		if err != nil {
+			http.Error(w, "could not check token: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if info == nil {
+			glog.Warningf("rejecting login with an invalid token from %s", req.RemoteAddr)
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     tokenCookie,
+			Value:    token,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   req.TLS != nil,
+			SameSite: http.SameSiteStrictMode,
+		})
+		http.Redirect(w, req, "/today", http.StatusSeeOther)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// requestToken extracts the bearer token from 'req's Authorization header or
+// token cookie, if it has one
+func requestToken(req *http.Request) string {
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	if c, err := req.Cookie(tokenCookie); err == nil {
+		return c.Value
+	}
+	return ""
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/golang/glog"
+	"github.com/msteffen/golang-time-tracker/api"
+)
+
+// backup copies the server's DB to a file, while the server keeps serving
+func (s httpAPIServer) backup(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /backups")
+	// Unmarshal and validate request
+	var req api.BackupRequest
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		msg := fmt.Sprintf("request did not match expected type: %v", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if !s.requireOwner(w, r, "/backups") {
+		return
+	}
+
+	// Process request
+	result, err := s.Backup(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, "could not serialize result: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(resultJSON)
+}
+
+// restore replaces the server's DB with a backup
+func (s httpAPIServer) restore(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /restore")
+	// Unmarshal and validate request
+	var req api.RestoreRequest
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		msg := fmt.Sprintf("request did not match expected type: %v", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if !s.requireOwner(w, r, "/restore") {
+		return
+	}
+
+	// Process request
+	result, err := s.Restore(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, "could not serialize result: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(resultJSON)
+}
+
+// requireOwner returns true if 'r' was sent by the server's owner (which is
+// always the case unless the server is in multi-user mode). Otherwise, it
+// writes an error to 'w' and returns false
+func (s httpAPIServer) requireOwner(w http.ResponseWriter, r *http.Request, page string) bool {
+	if s.users == nil {
+		return true
+	}
+	if name, err := s.users.requestUser(r); err != nil || name != "" {
+		http.Error(w, "only the server's owner may access "+page, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// backupScheduler makes daily and weekly backups of the server's DB, keeping
+// only the most recent ones. Backups are named by the day (or ISO week) in
+// which they were made, so at most one of each is made per day (or week), no
+// matter how often the daemon restarts
+type backupScheduler struct {
+	//// Not Owned
+	clock  api.Clock
+	server api.APIServer
+
+	//// Owned
+	dir string
+	// The number of daily and weekly backups to keep (0 disables either)
+	daily, weekly int
+}
+
+// run makes any backups that are due. It's a scheduled job (see runScheduled),
+// so errors are logged rather than returned
+func (b backupScheduler) run() {
+	if err := b.backupIfDue(); err != nil {
+		glog.Errorf("scheduled backup failed: %v", err)
+	}
+}
+
+// backupIfDue makes today's daily backup and this week's weekly backup (if
+// they don't exist yet), and deletes old backups
+func (b backupScheduler) backupIfDue() error {
+	if err := os.MkdirAll(b.dir, 0700); err != nil {
+		return err
+	}
+	now := b.clock.Now()
+	if b.daily > 0 {
+		if err := b.ensure("daily-", now.Format("2006-01-02"), b.daily); err != nil {
+			return err
+		}
+	}
+	if b.weekly > 0 {
+		year, week := now.ISOWeek()
+		if err := b.ensure("weekly-", fmt.Sprintf("%04d-W%02d", year, week), b.weekly); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensure makes the backup named 'prefix'+'period' (if it doesn't exist), and
+// then deletes all but the 'keep' most recent backups starting with 'prefix'
+func (b backupScheduler) ensure(prefix, period string, keep int) error {
+	path := filepath.Join(b.dir, prefix+period+".db")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		glog.Infof("making scheduled backup %s", path)
+		if _, err := b.server.Backup(&api.BackupRequest{Path: path}); err != nil {
+			return err
+		}
+	}
+	// Periods are formatted so that names sort chronologically
+	backups, err := filepath.Glob(filepath.Join(b.dir, prefix+"*.db"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(backups)
+	for len(backups) > keep {
+		glog.Infof("removing old backup %s", backups[0])
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
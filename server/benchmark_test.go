@@ -0,0 +1,112 @@
+package server
+
+import (
+	"fmt"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/msteffen/golang-time-tracker/api"
+)
+
+// historyYears is the length of the synthetic history that the benchmarks
+// query. A one-day GetIntervals call over it should take at most a few
+// milliseconds
+const historyYears = 5
+
+var history struct {
+	once   sync.Once
+	server api.APIServer
+	clock  *api.TestingClock
+	start  time.Time // the first day of the history
+}
+
+// historyServer returns an API server whose DB holds historyYears of
+// synthetic ticks (about a million), merged with Sync. The server is created
+// once and shared by all benchmarks
+func historyServer(b *testing.B) (api.APIServer, *api.TestingClock, time.Time) {
+	history.once.Do(func() {
+		history.start = time.Date(2012, 1, 1, 0, 0, 0, 0, time.Local)
+		end := history.start.AddDate(historyYears, 0, 0)
+		history.clock = &api.TestingClock{}
+		history.clock.Set(end)
+		var err error
+		history.server, err = api.NewServer(history.clock, path.Join(testDir, "history.db"))
+		if err != nil {
+			b.Fatalf("could not create server: %v", err)
+		}
+		ticks := SyntheticHistory(HistoryOptions{
+			Start:        history.start,
+			Days:         int(end.Sub(history.start).Hours() / 24),
+			Labels:       []string{"code", "review", "docs", "email", "meetings", "design", "ops", "hiring"},
+			TickInterval: 30 * time.Second,
+			Seed:         1,
+		})
+		if _, err := history.server.Sync(ticks); err != nil {
+			b.Fatalf("could not sync synthetic history: %v", err)
+		}
+	})
+	if history.server == nil {
+		b.Fatal("synthetic history wasn't created")
+	}
+	return history.server, history.clock, history.start
+}
+
+// BenchmarkTick measures adding a tick to the end of a long history
+func BenchmarkTick(b *testing.B) {
+	s, clock, _ := historyServer(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		clock.Add(30 * time.Second)
+		if err := s.Tick(&api.TickRequest{Label: "code"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkGetIntervals(b *testing.B, days int, req api.GetIntervalsRequest) {
+	s, _, start := historyServer(b)
+	// Query a period in the middle of the history
+	day := start.AddDate(historyYears/2, 0, 0)
+	req.Start, req.End = day.Unix(), day.AddDate(0, 0, days).Unix()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.GetIntervals(&req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetIntervalsDay(b *testing.B) {
+	benchmarkGetIntervals(b, 1, api.GetIntervalsRequest{})
+}
+
+func BenchmarkGetIntervalsDayByLabel(b *testing.B) {
+	benchmarkGetIntervals(b, 1, api.GetIntervalsRequest{ByLabel: true})
+}
+
+// BenchmarkGetIntervalsDayFromTicks measures computing intervals from ticks,
+// which is how requests for one host's intervals are answered
+func BenchmarkGetIntervalsDayFromTicks(b *testing.B) {
+	benchmarkGetIntervals(b, 1, api.GetIntervalsRequest{Host: "synthetic", ByLabel: true})
+}
+
+func BenchmarkGetIntervalsYearByLabel(b *testing.B) {
+	benchmarkGetIntervals(b, 365, api.GetIntervalsRequest{ByLabel: true})
+}
+
+// BenchmarkSummary measures summarizing the whole history, by period
+func BenchmarkSummary(b *testing.B) {
+	s, clock, start := historyServer(b)
+	for _, period := range []string{api.Day, api.Week} {
+		b.Run(fmt.Sprintf("period=%s", period), func(b *testing.B) {
+			req := &api.SummaryRequest{Start: start.Unix(), End: clock.Now().Unix(), Period: period}
+			for i := 0; i < b.N; i++ {
+				if _, err := s.Summary(req); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
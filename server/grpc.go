@@ -0,0 +1,274 @@
+// grpc.go serves the API over gRPC (see pb/timetracker.proto), for clients in
+// languages that can generate a client from the proto more easily than they
+// can speak the HTTP API. It's served on its own unix socket (Options.
+// GRPCSocket), with the same access checks as the HTTP socket
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"os"
+
+	"github.com/golang/glog"
+	"github.com/msteffen/golang-time-tracker/api"
+	"github.com/msteffen/golang-time-tracker/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// peerUIDInfo is the AuthInfo of a gRPC connection over the unix socket. It
+// holds the uid of the process on the other end, if peerCredListener knew it
+type peerUIDInfo struct {
+	credentials.CommonAuthInfo
+	uid   uint32
+	known bool
+}
+
+func (peerUIDInfo) AuthType() string { return "peer-uid" }
+
+// peerUIDCreds are the gRPC server's transport credentials: the connection
+// isn't secured (it's a unix socket), but its peer's uid is attached to it, as
+// connContext does for the HTTP socket
+type peerUIDCreds struct {
+	credentials.TransportCredentials
+}
+
+func (c peerUIDCreds) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	info := peerUIDInfo{
+		CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.NoSecurity},
+	}
+	if pc, ok := conn.(*peerConn); ok {
+		info.uid, info.known = pc.uid, true
+	}
+	return conn, info, nil
+}
+
+func (c peerUIDCreds) Clone() credentials.TransportCredentials {
+	return peerUIDCreds{c.TransportCredentials.Clone()}
+}
+
+// grpcAPIServer implements pb.TimeTrackerServer on top of an api.APIServer
+// (or, in multi-user mode, the APIServer of the user who sent each request)
+type grpcAPIServer struct {
+	pb.UnimplementedTimeTrackerServer
+
+	server api.APIServer
+	users  *userServers // nil unless in multi-user mode
+}
+
+// newGRPCServer returns a gRPC server that serves 's'. 'users' may be nil
+func newGRPCServer(s api.APIServer, users *userServers) *grpc.Server {
+	g := grpc.NewServer(grpc.Creds(peerUIDCreds{insecure.NewCredentials()}))
+	pb.RegisterTimeTrackerServer(g, &grpcAPIServer{server: s, users: users})
+	return g
+}
+
+// userServer returns the APIServer holding the data of the user who sent the
+// request carrying 'ctx' (see httpAPIServer.userServer)
+func (s *grpcAPIServer) userServer(ctx context.Context) (api.APIServer, error) {
+	if s.users == nil {
+		return s.server, nil
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(peerUIDInfo); ok && info.known {
+			ctx = context.WithValue(ctx, peerUIDKey, info.uid)
+		}
+	}
+	name, err := s.users.contextUser(ctx)
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	server, err := s.users.get(name)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return server, nil
+}
+
+func (s *grpcAPIServer) Tick(ctx context.Context, req *pb.TickRequest) (*pb.TickResponse, error) {
+	glog.Infof("handling gRPC Tick")
+	// Validate request
+	if req.Label == "" {
+		return nil, status.Error(codes.InvalidArgument, "tick request must have a label")
+	}
+	if req.Zone != "" {
+		if _, err := api.LoadZone(req.Zone); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid tick zone: "+err.Error())
+		}
+	}
+
+	// Process request
+	server, err := s.userServer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := server.Tick(&api.TickRequest{
+		Label:  req.Label,
+		Zone:   req.Zone,
+		Source: req.Source,
+	}); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.TickResponse{}, nil
+}
+
+func (s *grpcAPIServer) GetIntervals(ctx context.Context, req *pb.GetIntervalsRequest) (*pb.GetIntervalsResponse, error) {
+	glog.Infof("handling gRPC GetIntervals")
+	// Validate request. As in the HTTP API, a missing end means "no end"
+	end := req.End
+	if end == 0 {
+		end = math.MaxInt64
+	}
+	if end < req.Start {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid range: end (%d) is before start (%d)", end, req.Start)
+	}
+
+	// Process request
+	server, err := s.userServer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, err := server.GetIntervals(&api.GetIntervalsRequest{
+		Start:   req.Start,
+		End:     end,
+		Host:    req.Host,
+		ByLabel: req.ByLabel,
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &pb.GetIntervalsResponse{EndGap: result.EndGap}
+	for i := range result.Intervals {
+		resp.Intervals = append(resp.Intervals, toPBInterval(&result.Intervals[i]))
+	}
+	return resp, nil
+}
+
+func (s *grpcAPIServer) Summary(ctx context.Context, req *pb.SummaryRequest) (*pb.SummaryResponse, error) {
+	glog.Infof("handling gRPC Summary")
+	// Validate request
+	period := req.Period
+	switch period {
+	case "":
+		period = api.Day
+	case api.Hour, api.Day, api.Week:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "invalid period %q (must be %q, %q or %q)", period, api.Hour, api.Day, api.Week)
+	}
+	if req.End <= req.Start {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid range: end (%d) must be after start (%d)", req.End, req.Start)
+	}
+
+	// Process request
+	server, err := s.userServer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, err := server.Summary(&api.SummaryRequest{
+		Start:  req.Start,
+		End:    req.End,
+		Period: period,
+		Label:  req.Label,
+		Focus:  req.Focus,
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &pb.SummaryResponse{}
+	for _, b := range result.Buckets {
+		bucket := &pb.SummaryBucket{
+			Start:   b.Start,
+			End:     b.End,
+			Seconds: b.Seconds,
+			Total:   b.Total,
+		}
+		if b.Focus != nil {
+			bucket.Focus = &pb.Focus{
+				Switches:  int64(b.Focus.Switches),
+				Longest:   b.Focus.Longest,
+				Median:    b.Focus.Median,
+				DeepShare: b.Focus.DeepShare,
+			}
+		}
+		resp.Buckets = append(resp.Buckets, bucket)
+	}
+	return resp, nil
+}
+
+func (s *grpcAPIServer) Events(req *pb.EventsRequest, stream pb.TimeTracker_EventsServer) error {
+	glog.Infof("handling gRPC Events")
+	server, err := s.userServer(stream.Context())
+	if err != nil {
+		return err
+	}
+	events, cancel := server.Subscribe()
+	defer cancel()
+	// Send headers now, so that a client that waits for them knows it won't
+	// miss any events
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return nil // server is shutting down
+			}
+			event := &pb.Event{
+				Type:     string(e.Type),
+				Time:     e.Time,
+				Label:    e.Label,
+				DayTotal: e.DayTotal,
+			}
+			if e.Interval != nil {
+				event.Interval = toPBInterval(e.Interval)
+			}
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func toPBInterval(i *api.Interval) *pb.Interval {
+	return &pb.Interval{
+		Start: i.Start,
+		End:   i.End,
+		Label: i.Label,
+		Zone:  i.Zone,
+	}
+}
+
+// listenGRPC listens on the unix socket at 'socketPath' for gRPC requests,
+// with the same permissions and peer checks as the HTTP socket. The caller
+// has already established that no other daemon is running, so a socket left
+// at 'socketPath' is stale and is removed
+func listenGRPC(socketPath string, allowedUIDs []uint) (net.Listener, error) {
+	if info, err := os.Stat(socketPath); err == nil {
+		if info.Mode()&os.ModeType != os.ModeSocket {
+			return nil, fmt.Errorf("gRPC socket file had unexpected file type: %s (maybe it's owned by another application?)", info.Mode())
+		}
+		if err := os.Remove(socketPath); err != nil {
+			return nil, fmt.Errorf("could not remove stale gRPC socket at %s: %v", socketPath, err)
+		}
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not listen on unix socket at %s: %v", socketPath, err)
+	}
+	if err := os.Chmod(socketPath, socketMode(allowedUIDs)); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("could not set permissions on unix socket at %s: %v", socketPath, err)
+	}
+	return newPeerCredListener(listener, allowedUIDs), nil
+}
@@ -8,7 +8,9 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang/glog"
@@ -24,16 +26,38 @@ type httpAPIServer struct {
 	// Owned
 	api.APIServer // Unclear if this is owned or not
 	startTime     time.Time
+
+	// In multi-user mode, the APIServers of users other than the owner (nil
+	// otherwise)
+	users *userServers
+
+	// The web UI's templates
+	templates *webui.Templates
+}
+
+// userServer returns the APIServer holding the data of the user who sent 'r'.
+// This is always s.APIServer unless the server is in multi-user mode. If the
+// user can't be identified, userServer writes an error to 'w' and returns nil
+func (s httpAPIServer) userServer(w http.ResponseWriter, r *http.Request) api.APIServer {
+	if s.users == nil {
+		return s.APIServer
+	}
+	name, err := s.users.requestUser(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	server, err := s.users.get(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil
+	}
+	return server
 }
 
 func (s httpAPIServer) tick(w http.ResponseWriter, r *http.Request) {
 	glog.Infof("handling /tick")
 	// Unmarshal and validate request
-	if r.Method != "POST" {
-		http.Error(w, "must use POST to access /tick", http.StatusMethodNotAllowed)
-		return
-	}
-
 	var req api.TickRequest
 	d := json.NewDecoder(r.Body)
 	if err := d.Decode(&req); err != nil {
@@ -41,9 +65,19 @@ func (s httpAPIServer) tick(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, msg, http.StatusBadRequest)
 		return
 	}
+	if req.Zone != "" {
+		if _, err := api.LoadZone(req.Zone); err != nil {
+			http.Error(w, "invalid tick zone: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
 	// Process request
-	err := s.Tick(&req)
+	server := s.userServer(w, r)
+	if server == nil {
+		return
+	}
+	err := server.Tick(&req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -54,11 +88,6 @@ func (s httpAPIServer) tick(w http.ResponseWriter, r *http.Request) {
 func (s httpAPIServer) getIntervals(w http.ResponseWriter, r *http.Request) {
 	glog.Infof("handling /intervals")
 	// Unmarshal and validate request
-	if r.Method != "GET" {
-		http.Error(w, "must use GET to access /intervals", http.StatusMethodNotAllowed)
-		return
-	}
-
 	// Trasform GET params into request struct
 	boundary := []int64{0, math.MaxInt64} // start and end
 	var err error
@@ -75,10 +104,23 @@ func (s httpAPIServer) getIntervals(w http.ResponseWriter, r *http.Request) {
 	req := api.GetIntervalsRequest{
 		Start: boundary[0],
 		End:   boundary[1],
+		Host:  r.URL.Query().Get("host"),
+	}
+	if b := r.URL.Query().Get("by_label"); b != "" {
+		req.ByLabel, err = strconv.ParseBool(b)
+		if err != nil {
+			msg := fmt.Sprintf("invalid \"by_label\" value: %s", err.Error())
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
 	}
 
 	// Process request
-	result, err := s.GetIntervals(&req)
+	server := s.userServer(w, r)
+	if server == nil {
+		return
+	}
+	result, err := server.GetIntervals(&req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -91,14 +133,379 @@ func (s httpAPIServer) getIntervals(w http.ResponseWriter, r *http.Request) {
 	w.Write(resultJSON)
 }
 
-func (s httpAPIServer) clear(w http.ResponseWriter, r *http.Request) {
-	glog.Infof("handling /clear")
+// batchIntervals gets the intervals in several ranges at once
+func (s httpAPIServer) batchIntervals(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /intervals/batch")
 	// Unmarshal and validate request
-	if r.Method != "POST" {
-		http.Error(w, "must use POST to access /clear", http.StatusMethodNotAllowed)
+	var req api.BatchIntervalsRequest
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		msg := fmt.Sprintf("request did not match expected type: %v", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	// Process request
+	server := s.userServer(w, r)
+	if server == nil {
+		return
+	}
+	result, err := server.BatchIntervals(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, "could not serialize result: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(resultJSON)
+}
+
+// rebuildIntervals recomputes the server's stored intervals from its ticks
+func (s httpAPIServer) rebuildIntervals(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /intervals/rebuild")
+	// Unmarshal and validate request
+	var req api.RebuildIntervalsRequest
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		msg := fmt.Sprintf("request did not match expected type: %v", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	// Process request
+	server := s.userServer(w, r)
+	if server == nil {
+		return
+	}
+	result, err := server.RebuildIntervals(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, "could not serialize result: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(resultJSON)
+}
+
+// summary reports the time worked in each hour, day or week of a range
+func (s httpAPIServer) summary(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /summary")
+	// Unmarshal and validate request
+	// Trasform GET params into request struct
+	req := api.SummaryRequest{
+		Period: r.URL.Query().Get("period"),
+		Label:  r.URL.Query().Get("label"),
+	}
+	if req.Period == "" {
+		req.Period = api.Day
+	}
+	if f := r.URL.Query().Get("focus"); f != "" {
+		var err error
+		if req.Focus, err = strconv.ParseBool(f); err != nil {
+			msg := fmt.Sprintf("invalid \"focus\" value: %s", err.Error())
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+	}
+	for _, param := range []struct {
+		name string
+		dest *int64
+	}{{"start", &req.Start}, {"end", &req.End}} {
+		s := r.URL.Query().Get(param.name)
+		if s == "" {
+			msg := fmt.Sprintf("must provide \"%s\"", param.name)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		var err error
+		if *param.dest, err = strconv.ParseInt(s, 10, 64); err != nil {
+			msg := fmt.Sprintf("invalid \"%s\" value: %s", param.name, err.Error())
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Process request
+	server := s.userServer(w, r)
+	if server == nil {
+		return
+	}
+	result, err := server.Summary(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, "could not serialize result: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(resultJSON)
+}
+
+// histogram reports the time worked in each hour of the day or day of the week
+func (s httpAPIServer) histogram(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /stats/histogram")
+	// Unmarshal and validate request
+	// Trasform GET params into request struct
+	req := api.HistogramRequest{
+		Start: 0,
+		End:   s.clock.Now().Unix(),
+		By:    r.URL.Query().Get("by"),
+		Label: r.URL.Query().Get("label"),
+	}
+	if req.By == "" {
+		req.By = api.ByHour
+	}
+	for _, param := range []struct {
+		name string
+		dest *int64
+	}{{"from", &req.Start}, {"to", &req.End}} {
+		s := r.URL.Query().Get(param.name)
+		if s == "" {
+			continue
+		}
+		var err error
+		if *param.dest, err = strconv.ParseInt(s, 10, 64); err != nil {
+			msg := fmt.Sprintf("invalid \"%s\" value: %s", param.name, err.Error())
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Process request
+	server := s.userServer(w, r)
+	if server == nil {
+		return
+	}
+	result, err := server.Histogram(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, "could not serialize result: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(resultJSON)
+}
+
+// ndjsonType is the content type of newline-delimited JSON
+// (https://github.com/ndjson/ndjson-spec), in which /ticks can stream ticks
+const ndjsonType = "application/x-ndjson"
+
+// listTicks lists raw ticks, either a page at a time (as JSON) or all at once
+// (as NDJSON, if the request has "format=ndjson" or accepts ndjsonType)
+func (s httpAPIServer) listTicks(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /ticks")
+	// Unmarshal and validate request
+	// Trasform GET params into request struct
+	q := r.URL.Query()
+	req := api.ListTicksRequest{
+		Start:  0,
+		End:    math.MaxInt64,
+		Label:  q.Get("label"),
+		Host:   q.Get("host"),
+		Cursor: q.Get("cursor"),
+	}
+	for _, param := range []struct {
+		name string
+		dest *int64
+	}{{"start", &req.Start}, {"end", &req.End}} {
+		if v := q.Get(param.name); v != "" {
+			var err error
+			if *param.dest, err = strconv.ParseInt(v, 10, 64); err != nil {
+				msg := fmt.Sprintf("invalid \"%s\" value: %s", param.name, err.Error())
+				http.Error(w, msg, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		var err error
+		if req.Limit, err = strconv.Atoi(v); err != nil {
+			msg := fmt.Sprintf("invalid \"limit\" value: %s", err.Error())
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+	}
+	stream := q.Get("format") == "ndjson" || strings.Contains(r.Header.Get("Accept"), ndjsonType)
+
+	// Process request
+	server := s.userServer(w, r)
+	if server == nil {
+		return
+	}
+	if !stream {
+		result, err := server.ListTicks(&req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resultJSON, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, "could not serialize result: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(resultJSON)
+		return
+	}
+
+	// Stream every tick (or the first 'limit'), reading a page at a time so
+	// that the DB isn't locked while slow clients read
+	remaining := req.Limit
+	page := req
+	page.Limit = api.MaxTicksLimit
+	enc := json.NewEncoder(w)
+	for wroteHeader := false; ; {
+		if remaining > 0 && remaining < page.Limit {
+			page.Limit = remaining
+		}
+		result, err := server.ListTicks(&page)
+		if err != nil {
+			if !wroteHeader {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			} else {
+				glog.Errorf("could not list ticks: %v", err)
+			}
+			return
+		}
+		if !wroteHeader {
+			w.Header().Set("Content-Type", ndjsonType)
+			wroteHeader = true
+		}
+		for _, t := range result.Ticks {
+			if err := enc.Encode(t); err != nil {
+				return // client went away
+			}
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		if req.Limit > 0 {
+			if remaining -= len(result.Ticks); remaining <= 0 {
+				return
+			}
+		}
+		if result.NextCursor == "" {
+			return
+		}
+		page.Cursor = result.NextCursor
+	}
+}
+
+func (s httpAPIServer) export(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /export")
+	// Unmarshal and validate request
+	req := api.ExportRequest{
+		Host: r.URL.Query().Get("host"),
+	}
+
+	// Process request
+	server := s.userServer(w, r)
+	if server == nil {
+		return
+	}
+	result, err := server.Export(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, "could not serialize result: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(resultJSON)
+}
+
+func (s httpAPIServer) sync(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /sync")
+	// Unmarshal and validate request
+	var req api.TickSet
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		msg := fmt.Sprintf("request did not match expected type: %v", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	// Process request
+	server := s.userServer(w, r)
+	if server == nil {
+		return
+	}
+	result, err := server.Sync(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, "could not serialize result: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(resultJSON)
+}
+
+// events streams api.Events to the client as Server-Sent Events
+// (https://html.spec.whatwg.org/multipage/server-sent-events.html), until the
+// client disconnects
+func (s httpAPIServer) events(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /events")
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported by this connection", http.StatusInternalServerError)
+		return
+	}
+
+	// Process request
+	server := s.userServer(w, r)
+	if server == nil {
+		return
+	}
+	events, cancel := server.Subscribe()
+	defer cancel()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	// Send a comment periodically so that idle connections aren't dropped
+	keepalive := time.NewTicker(30 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			eventJSON, err := json.Marshal(e)
+			if err != nil {
+				glog.Errorf("could not serialize event: %v", err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, eventJSON)
+		}
+		flusher.Flush()
+	}
+}
 
+func (s httpAPIServer) clear(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /clear")
+	// Unmarshal and validate request
 	// Require a body to ensure that I can't accidentally clear from my browser
 	req := make(map[string]interface{})
 	d := json.NewDecoder(r.Body)
@@ -111,38 +518,402 @@ func (s httpAPIServer) clear(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Must send confirmation message to delete all server data", http.StatusBadRequest)
 		return
 	}
-	if err := s.Clear(); err != nil {
+	server := s.userServer(w, r)
+	if server == nil {
+		return
+	}
+	if err := server.Clear(); err != nil {
 		http.Error(w, fmt.Sprintf("Could not clear DB: %v", err), http.StatusInternalServerError)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
 }
 
-// GetToday writes the http response for the /today page to 'w'.
+func (s httpAPIServer) createToken(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /tokens")
+	// Unmarshal and validate request
+	var req api.CreateTokenRequest
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		msg := fmt.Sprintf("request did not match expected type: %v", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	// In multi-user mode, users other than the owner may only create tokens
+	// for themselves
+	if s.users != nil {
+		requester, err := s.users.requestUser(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if requester != "" {
+			if req.User != "" && req.User != requester {
+				http.Error(w, "only the server's owner may create tokens for other users",
+					http.StatusForbidden)
+				return
+			}
+			req.User = requester
+		}
+	}
+	if req.User != "" && !userNameRe.MatchString(req.User) {
+		http.Error(w, fmt.Sprintf("invalid user name %q", req.User), http.StatusBadRequest)
+		return
+	}
+
+	// Process request. Tokens are always stored in the owner's DB, so that
+	// they can be checked before the request's user is known
+	result, err := s.CreateToken(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, "could not serialize result: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(resultJSON)
+}
+
+// listLabels returns the metadata of every label that has any
+func (s httpAPIServer) listLabels(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /labels")
+	// Process request
+	server := s.userServer(w, r)
+	if server == nil {
+		return
+	}
+	result, err := server.GetLabels()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, "could not serialize result: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(resultJSON)
+}
+
+// setLabel sets a label's metadata, such as its colour. The label is named by
+// the request's path (/labels/<name>) or, at the legacy path, by its body
+func (s httpAPIServer) setLabel(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /labels")
+	// Unmarshal and validate request
+	var req api.LabelInfo
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		msg := fmt.Sprintf("request did not match expected type: %v", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if name := pathParam(r, "/labels/"); name != "" {
+		if req.Name != "" && req.Name != name {
+			msg := fmt.Sprintf("label name in body (%q) does not match path (%q)", req.Name, name)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		req.Name = name
+	}
+	if req.Name == "" {
+		http.Error(w, "must name the label to set", http.StatusBadRequest)
+		return
+	}
+	if req.Color != "" {
+		if _, _, _, err := api.ParseColor(req.Color); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Process request
+	server := s.userServer(w, r)
+	if server == nil {
+		return
+	}
+	if err := server.SetLabel(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// today writes the http response for the /today page to 'w'.
 func (s httpAPIServer) today(w http.ResponseWriter, r *http.Request) {
 	glog.Infof("handling /today")
+	s.day(w, r, "/today", time.Time{})
+}
+
+// pastDay writes the http response for the /day/<YYYY-MM-DD> page to 'w'.
+func (s httpAPIServer) pastDay(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /day")
+	var date time.Time
+	if d := strings.TrimPrefix(r.URL.Path, "/day/"); d != "" {
+		var err error
+		if date, err = time.Parse(webui.DateFormat, d); err != nil {
+			msg := fmt.Sprintf("invalid date %q (must be YYYY-MM-DD)", d)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+	}
+	s.day(w, r, "/day", date)
+}
+
+// day renders the page for a single day (today, if 'date' is zero). 'page' is
+// the name of the endpoint, for error messages
+func (s httpAPIServer) day(w http.ResponseWriter, r *http.Request, page string, date time.Time) {
 	// Unmarshal and validate request
 	if r.Method != "GET" {
-		http.Error(w, "must use GET to access /today", http.StatusMethodNotAllowed)
+		http.Error(w, "must use GET to access "+page, http.StatusMethodNotAllowed)
+		return
+	}
+	inRecordedZone, ok := zoneParam(w, r)
+	if !ok {
+		return
+	}
+	var asJSON bool
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "html":
+	case "json":
+		asJSON = true
+	default:
+		msg := fmt.Sprintf("invalid \"format\" value %q (must be \"html\" or \"json\")", format)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	bgWidth := float64(500)
+	if width := r.URL.Query().Get("width"); width != "" {
+		var err error
+		bgWidth, err = strconv.ParseFloat(width, 64)
+		if err != nil || bgWidth < minBgWidth || bgWidth > maxBgWidth {
+			msg := fmt.Sprintf("invalid \"width\" value %q (must be a number of points "+
+				"between %d and %d)", width, minBgWidth, maxBgWidth)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+	}
+	loc := s.clock.Now().Location()
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		var err error
+		if loc, err = api.LoadZone(tz); err != nil {
+			http.Error(w, fmt.Sprintf("invalid \"tz\" value: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if !date.IsZero() {
+		date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+	}
+	// Carry the page's parameters (except 'format') over to links to other days
+	query := r.URL.Query()
+	query.Del("format")
+	root := ""
+	if page == "/day" {
+		root = "../"
+	}
+
+	server := s.userServer(w, r)
+	if server == nil {
 		return
 	}
 	t := webui.TodayOp{
-		Server:  s.APIServer,
-		Clock:   s.clock,
-		Writer:  w,
-		BgWidth: float64(500),
+		Server:         server,
+		Clock:          s.clock,
+		Writer:         w,
+		Templates:      s.templates,
+		BgWidth:        bgWidth,
+		InRecordedZone: inRecordedZone,
+		AsJSON:         asJSON,
+		Date:           date,
+		Location:       loc,
+		Root:           root,
+		Query:          query.Encode(),
 	}
 	t.Start()
-	return
 }
 
-func (s httpAPIServer) status(w http.ResponseWriter, r *http.Request) {
-	glog.Infof("handling /status")
+// barImage returns a handler that writes an image of a single day's bar
+// (/bar.svg or /bar.png) to 'w'
+func (s httpAPIServer) barImage(format webui.BarFormat, page string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		glog.Infof("handling %s", page)
+		// Unmarshal and validate request
+		if r.Method != "GET" {
+			http.Error(w, "must use GET to access "+page, http.StatusMethodNotAllowed)
+			return
+		}
+		inRecordedZone, ok := zoneParam(w, r)
+		if !ok {
+			return
+		}
+		q := r.URL.Query()
+		loc := s.clock.Now().Location()
+		if tz := q.Get("tz"); tz != "" {
+			var err error
+			if loc, err = api.LoadZone(tz); err != nil {
+				http.Error(w, fmt.Sprintf("invalid \"tz\" value: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+		var date time.Time
+		if d := q.Get("day"); d != "" {
+			var err error
+			if date, err = time.ParseInLocation(webui.DateFormat, d, loc); err != nil {
+				msg := fmt.Sprintf("invalid \"day\" value %q (must be YYYY-MM-DD)", d)
+				http.Error(w, msg, http.StatusBadRequest)
+				return
+			}
+		}
+		size := map[string]int{"width": 600, "height": 40}
+		for _, param := range []string{"width", "height"} {
+			v := q.Get(param)
+			if v == "" {
+				continue
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxBarImageSize {
+				msg := fmt.Sprintf("invalid %q value %q (must be a number of pixels "+
+					"between 1 and %d)", param, v, maxBarImageSize)
+				http.Error(w, msg, http.StatusBadRequest)
+				return
+			}
+			size[param] = n
+		}
+		flags := map[string]bool{"labels": true, "axis": true}
+		for _, param := range []string{"labels", "axis"} {
+			v := q.Get(param)
+			if v == "" {
+				continue
+			}
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				msg := fmt.Sprintf("invalid %q value: %s", param, err.Error())
+				http.Error(w, msg, http.StatusBadRequest)
+				return
+			}
+			flags[param] = b
+		}
+
+		server := s.userServer(w, r)
+		if server == nil {
+			return
+		}
+		b := webui.BarOp{
+			Server:         server,
+			Clock:          s.clock,
+			Writer:         w,
+			Format:         format,
+			Date:           date,
+			Location:       loc,
+			Width:          size["width"],
+			Height:         size["height"],
+			ByLabel:        flags["labels"],
+			Axis:           flags["axis"],
+			InRecordedZone: inRecordedZone,
+		}
+		b.Start()
+	}
+}
+
+// maxBarImageSize is the largest width or height accepted by /bar.svg and
+// /bar.png, in pixels
+const maxBarImageSize = 4096
+
+// The range of widths accepted by the 'width' parameter of /today and /day
+const minBgWidth, maxBgWidth = 100, 10000
+
+// calendar returns a handler that writes the http response for the page
+// rendering 'period' (/week, /month or /year) to 'w'
+func (s httpAPIServer) calendar(period webui.Period) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		glog.Infof("handling /%s", period)
+		// Unmarshal and validate request
+		if r.Method != "GET" {
+			http.Error(w, fmt.Sprintf("must use GET to access /%s", period), http.StatusMethodNotAllowed)
+			return
+		}
+		inRecordedZone, ok := zoneParam(w, r)
+		if !ok {
+			return
+		}
+		var date time.Time
+		if d := r.URL.Query().Get("date"); d != "" {
+			var err error
+			date, err = time.ParseInLocation(webui.DateFormat, d, s.clock.Now().Location())
+			if err != nil {
+				msg := fmt.Sprintf("invalid \"date\" value %q (must be YYYY-MM-DD)", d)
+				http.Error(w, msg, http.StatusBadRequest)
+				return
+			}
+		}
+		server := s.userServer(w, r)
+		if server == nil {
+			return
+		}
+		c := webui.CalendarOp{
+			Server:         server,
+			Clock:          s.clock,
+			Writer:         w,
+			Templates:      s.templates,
+			Period:         period,
+			Date:           date,
+			BgWidth:        float64(500),
+			InRecordedZone: inRecordedZone,
+		}
+		if period == webui.Month {
+			c.BgWidth = float64(100)
+		}
+		c.Start()
+	}
+}
+
+// zoneParam parses the 'zone' query parameter accepted by the web UI's pages,
+// and returns true if intervals should be rendered in their recorded zones. If
+// the parameter is invalid, zoneParam writes an error to 'w' and returns
+// ok=false
+func zoneParam(w http.ResponseWriter, r *http.Request) (inRecordedZone bool, ok bool) {
+	switch zone := r.URL.Query().Get("zone"); zone {
+	case "", "local":
+		return false, true
+	case "recorded":
+		return true, true
+	default:
+		msg := fmt.Sprintf("invalid \"zone\" value %q (must be \"local\" or \"recorded\")", zone)
+		http.Error(w, msg, http.StatusBadRequest)
+		return false, false
+	}
+}
+
+func (s httpAPIServer) listUsers(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /admin/users")
 	// Unmarshal and validate request
-	if r.Method != "GET" {
-		http.Error(w, "must use GET to access /status", http.StatusMethodNotAllowed)
+	if s.users == nil {
+		http.Error(w, "/admin/users is only available in multi-user mode", http.StatusNotFound)
+		return
+	}
+	if name, err := s.users.requestUser(r); err != nil || name != "" {
+		http.Error(w, "only the server's owner may access /admin/users", http.StatusForbidden)
+		return
+	}
+
+	// Process request
+	users, err := s.users.list()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	resultJSON, err := json.Marshal(api.ListUsersResponse{Users: users})
+	if err != nil {
+		http.Error(w, "could not serialize result: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(resultJSON)
+}
+
+func (s httpAPIServer) status(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /status")
 	w.Write([]byte(time.Now().Sub(s.startTime).String()))
 }
 
@@ -151,13 +922,102 @@ type loggingHandler struct {
 }
 
 func (h loggingHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	glog.Infof("HTTP request: %#v", req)
+	// Only log the method and path: headers, query parameters and cookies may
+	// hold bearer tokens
+	glog.Infof("HTTP request: %s %s", req.Method, req.URL.Path)
 	h.mux.ServeHTTP(w, req)
 }
 
+// Options contains optional settings for ServeOverHTTP
+type Options struct {
+	// If set, also serve the API over TCP at this address (e.g.
+	// "127.0.0.1:10101"). Requests that arrive over TCP must carry a bearer
+	// token created by 't token create'
+	ListenAddr string
+
+	// If both are set, serve TLS on ListenAddr using this certificate and key
+	TLSCertFile, TLSKeyFile string
+
+	// If set, also serve the API over gRPC (see grpc.go) on a unix socket at
+	// this path
+	GRPCSocket string
+
+	// Uids (besides the server's own) whose processes may connect to the unix
+	// socket. Connections from any other uid are rejected
+	AllowedUIDs []uint
+
+	// If true, serve each user (identified by peer uid or bearer token) from
+	// their own DB under DataDir/users. See users.go. Local users other than
+	// the owner must also be in AllowedUIDs to connect to the unix socket
+	MultiUser bool
+	DataDir   string
+
+	// If set, the web UI's templates are read from this directory (if it
+	// exists), falling back to the built-in templates for pages that it
+	// doesn't have. Files in its 'static' subdirectory are served at /static/,
+	// and static/theme.css is added to every page
+	TemplateDir string
+
+	// If true, re-read templates for every page (for developing templates)
+	DevTemplates bool
+
+	// The number of daily and weekly backups of the DB to keep in BackupDir.
+	// If either is nonzero, the daemon makes a backup once per day (or week)
+	DailyBackups, WeeklyBackups int
+	BackupDir                   string
+
+	// For tests: if set, scheduled jobs (see schedule.go) run whenever this
+	// receives, rather than every scheduleInterval
+	scheduleTicks <-chan time.Time
+}
+
+// newMux returns a ServeMux routing requests to the API endpoints in 'h' (see
+// routes.go) and to the web UI's pages. If 'socket' is false, endpoints that
+// may only be used over the unix socket (e.g. creating tokens) are left out
+func newMux(h httpAPIServer, socket bool) *http.ServeMux {
+	mux := http.NewServeMux()
+	h.handleRoutes(mux, socket)
+	mux.HandleFunc("/today", h.today)
+	mux.HandleFunc("/day/", h.pastDay)
+	mux.HandleFunc("/bar.svg", h.barImage(webui.SVG, "/bar.svg"))
+	mux.HandleFunc("/bar.png", h.barImage(webui.PNG, "/bar.png"))
+	mux.HandleFunc("/week", h.calendar(webui.Week))
+	mux.HandleFunc("/month", h.calendar(webui.Month))
+	mux.HandleFunc("/year", h.calendar(webui.Year))
+	mux.HandleFunc("/metrics", h.metrics)
+	mux.Handle("/static/", http.StripPrefix("/static/", h.templates.StaticHandler()))
+	mux.Handle("/", http.NotFoundHandler()) // Return to non-endpoint calls with 404
+	return mux
+}
+
 // serveOverHTTP serves the Server API over HTTP, managing HTTP
-// reqests/responses
-func ServeOverHTTP(socketPath string, clock api.Clock, server api.APIServer) error {
+// reqests/responses. 'opts' may be nil, in which case the API is only served
+// over the unix socket at 'socketPath'
+func ServeOverHTTP(socketPath string, clock api.Clock, server api.APIServer, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if (opts.TLSCertFile == "") != (opts.TLSKeyFile == "") {
+		return errors.New("must set both or neither of the TLS certificate and key")
+	}
+	if opts.TLSCertFile != "" && opts.ListenAddr == "" {
+		return errors.New("TLS requires a TCP listen address")
+	}
+	if opts.MultiUser && opts.DataDir == "" {
+		return errors.New("multi-user mode requires a data directory")
+	}
+	if opts.MultiUser && !peerCredSupported {
+		return fmt.Errorf("multi-user mode identifies local users with "+
+			"SO_PEERCRED, which isn't supported on %s", runtime.GOOS)
+	}
+	if (opts.DailyBackups > 0 || opts.WeeklyBackups > 0) && opts.BackupDir == "" {
+		return errors.New("scheduled backups require a backup directory")
+	}
+	templates, err := webui.LoadTemplates(opts.TemplateDir, opts.DevTemplates)
+	if err != nil {
+		return err
+	}
+
 	for t := 0; t < 2; t++ {
 		// Stat socket file
 		info, err := os.Stat(socketPath)
@@ -178,7 +1038,7 @@ func ServeOverHTTP(socketPath string, clock api.Clock, server api.APIServer) err
 		}
 
 		// See if server is running by sending request
-		_, err = cu.GetClient(socketPath).Get("/status")
+		_, err = cu.GetClient(socketPath).Get(APIPrefix + "/status")
 		if err == nil {
 			return errors.New("time-tracker is already running")
 		}
@@ -196,18 +1056,73 @@ func ServeOverHTTP(socketPath string, clock api.Clock, server api.APIServer) err
 		clock:     clock,
 		APIServer: server,
 		startTime: time.Now(),
+		templates: templates,
+	}
+	if opts.MultiUser {
+		users, err := newUserServers(clock, server, opts.DataDir)
+		if err != nil {
+			return err
+		}
+		defer users.close()
+		h.users = users
 	}
-	mux := http.NewServeMux()
-	mux.HandleFunc(socketPath+"/status", h.status)
-	mux.HandleFunc(socketPath+"/tick", h.tick)
-	mux.HandleFunc(socketPath+"/intervals", h.getIntervals)
-	mux.HandleFunc(socketPath+"/today", h.today)
-	mux.HandleFunc(socketPath+"/clear", h.clear)
-	mux.Handle(socketPath, http.NotFoundHandler()) // Return to non-endpoint calls with 404
 
-	// Start serving requests
+	// Start scheduled jobs (see schedule.go), and stop them when the server
+	// stops
+	jobs := []func(){h.purgeTrash}
+	if opts.DailyBackups > 0 || opts.WeeklyBackups > 0 {
+		jobs = append(jobs, backupScheduler{
+			clock:  clock,
+			server: server,
+			dir:    opts.BackupDir,
+			daily:  opts.DailyBackups,
+			weekly: opts.WeeklyBackups,
+		}.run)
+	}
+	ticks := opts.scheduleTicks
+	if ticks == nil {
+		ticker := time.NewTicker(scheduleInterval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		runScheduled(ticks, stop, jobs...)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+	// Requests over the unix socket are addressed to socketPath+"/<endpoint>"
+	// (see clientutil)
 	s := http.Server{
-		Handler: loggingHandler{mux: mux},
+		Handler:     http.StripPrefix(socketPath, loggingHandler{mux: newMux(h, true)}),
+		ConnContext: connContext,
+	}
+
+	// Start serving requests
+	errCh := make(chan error, 3)
+	if opts.ListenAddr != "" {
+		tcpListener, err := net.Listen("tcp", opts.ListenAddr)
+		if err != nil {
+			return fmt.Errorf("could not listen on %s: %v", opts.ListenAddr, err)
+		}
+		tcpServer := http.Server{
+			Handler: tokenAuthHandler{
+				server:  server,
+				handler: loggingHandler{mux: newMux(h, false)},
+			},
+		}
+		go func() {
+			if opts.TLSCertFile != "" {
+				glog.Infof("https server about to listen on %s", opts.ListenAddr)
+				errCh <- tcpServer.ServeTLS(tcpListener, opts.TLSCertFile, opts.TLSKeyFile)
+			} else {
+				glog.Infof("http server about to listen on %s", opts.ListenAddr)
+				errCh <- tcpServer.Serve(tcpListener)
+			}
+		}()
 	}
 
 	glog.Infof("http server about to listen on %s", socketPath)
@@ -215,5 +1130,29 @@ func ServeOverHTTP(socketPath string, clock api.Clock, server api.APIServer) err
 	if err != nil {
 		return fmt.Errorf("could not listen on unix socket at %s: %v", socketPath, err)
 	}
-	return s.Serve(listener)
+	// Only the owner (and AllowedUIDs) may connect to the socket. Peers that
+	// connect before the chmod, or that the socket's mode doesn't exclude, are
+	// checked by peerCredListener
+	if err := os.Chmod(socketPath, socketMode(opts.AllowedUIDs)); err != nil {
+		listener.Close()
+		return fmt.Errorf("could not set permissions on unix socket at %s: %v", socketPath, err)
+	}
+	listener = newPeerCredListener(listener, opts.AllowedUIDs)
+	go func() {
+		errCh <- s.Serve(listener)
+	}()
+
+	if opts.GRPCSocket != "" {
+		glog.Infof("gRPC server about to listen on %s", opts.GRPCSocket)
+		grpcListener, err := listenGRPC(opts.GRPCSocket, opts.AllowedUIDs)
+		if err != nil {
+			return err
+		}
+		g := newGRPCServer(server, h.users)
+		defer g.Stop()
+		go func() {
+			errCh <- g.Serve(grpcListener)
+		}()
+	}
+	return <-errCh
 }
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/msteffen/golang-time-tracker/api"
+	"github.com/msteffen/golang-time-tracker/metrics"
+)
+
+// metrics serves the server's metrics in the Prometheus text exposition
+// format. Counters and histograms are recorded by the api package as requests
+// arrive; gauges (e.g. time worked today) are computed here, for the server's
+// owner
+func (s httpAPIServer) metrics(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /metrics")
+	// Metrics include every user's labels, so only the owner may read them
+	if !s.requireOwner(w, r, "/metrics") {
+		return
+	}
+
+	// Process request
+	buf := &bytes.Buffer{}
+	metrics.Default.WriteText(buf)
+	if err := s.writeGauges(buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(buf.Bytes())
+}
+
+// writeGauges computes the gauges in /metrics and writes them to 'buf'
+func (s httpAPIServer) writeGauges(buf *bytes.Buffer) error {
+	now := s.clock.Now()
+	morning := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	today, err := s.GetIntervals(&api.GetIntervalsRequest{
+		Start: morning.Unix(),
+		End:   now.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	var worked int64
+	for _, i := range today.Intervals {
+		worked += i.End - i.Start
+	}
+	metrics.WriteGauge(buf, "timetracker_today_worked_seconds",
+		"Time worked since midnight, in the server's time zone", float64(worked))
+
+	// The current interval may have started before midnight
+	recent, err := s.GetIntervals(&api.GetIntervalsRequest{
+		Start: now.Add(-24 * time.Hour).Unix(),
+		End:   now.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	var current int64
+	if n := len(recent.Intervals); n > 0 && recent.Intervals[n-1].End == now.Unix() {
+		current = recent.Intervals[n-1].End - recent.Intervals[n-1].Start
+	}
+	metrics.WriteGauge(buf, "timetracker_current_interval_seconds",
+		"Length of the interval in progress (0 if no work is being done)", float64(current))
+
+	size, err := s.DBSize()
+	if err != nil {
+		return err
+	}
+	metrics.WriteGauge(buf, "timetracker_db_size_bytes", "Size of the server's DB", float64(size))
+	return nil
+}
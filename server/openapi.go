@@ -0,0 +1,174 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// openAPIVersion is the version of the API described by openAPISpec. It
+// changes whenever an endpoint is added or changed (but the path prefix only
+// changes for incompatible changes)
+const openAPIVersion = "1.0.0"
+
+// openAPISpec returns an OpenAPI 3 document (https://spec.openapis.org/oas/v3.0.3)
+// describing every route in the versioned API. Request and response schemas are
+// derived from the Go types that the handlers encode and decode, so that the
+// document can't drift from the code
+func (s httpAPIServer) openAPISpec() map[string]interface{} {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]interface{})
+	for _, rt := range s.routes() {
+		op := map[string]interface{}{
+			"summary":   rt.summary,
+			"responses": map[string]interface{}{"200": response(rt, schemas)},
+		}
+		var params []interface{}
+		if strings.HasSuffix(rt.path, "{name}") {
+			params = append(params, map[string]interface{}{
+				"name":     "name",
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, q := range rt.query {
+			params = append(params, map[string]interface{}{
+				"name":        q.name,
+				"in":          "query",
+				"description": q.description,
+				"schema":      map[string]interface{}{"type": q.typ},
+			})
+		}
+		if params != nil {
+			op["parameters"] = params
+		}
+		if rt.request != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": schemaOf(reflect.TypeOf(rt.request), schemas),
+					},
+				},
+			}
+		}
+		if rt.legacyPath != "" {
+			op["x-deprecated-alias"] = rt.legacyPath
+		}
+		if _, ok := paths[rt.path]; !ok {
+			paths[rt.path] = make(map[string]interface{})
+		}
+		paths[rt.path].(map[string]interface{})[strings.ToLower(rt.method)] = op
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "time-tracker",
+			"version": openAPIVersion,
+			"description": "Records ticks (signs of work, such as file saves) and " +
+				"reports the intervals in which work was done. Over TCP, every " +
+				"request must carry a bearer token created by 't token create'",
+		},
+		"servers": []interface{}{map[string]interface{}{"url": APIPrefix}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+		"security": []interface{}{map[string]interface{}{"bearer": []string{}}},
+	}
+}
+
+// response returns the OpenAPI response object for a successful call to 'rt'
+func response(rt route, schemas map[string]interface{}) map[string]interface{} {
+	resp := map[string]interface{}{"description": "OK"}
+	switch {
+	case rt.response != nil:
+		resp["content"] = map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": schemaOf(reflect.TypeOf(rt.response), schemas),
+			},
+		}
+	case rt.responseType != "":
+		resp["content"] = map[string]interface{}{
+			rt.responseType: map[string]interface{}{
+				"schema": map[string]interface{}{"type": "string"},
+			},
+		}
+	}
+	return resp
+}
+
+// schemaOf returns the JSON schema of 't', as encoded by encoding/json. Named
+// struct types are added to 'schemas' and referenced, rather than inlined
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem(), schemas)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": schemaOf(t.Elem(), schemas),
+		}
+	case reflect.Struct:
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := schemas[t.Name()]; ok {
+			return ref
+		}
+		schemas[t.Name()] = nil // placeholder, in case 't' refers to itself
+		properties := make(map[string]interface{})
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue // unexported
+			}
+			name := f.Name
+			if tag := f.Tag.Get("json"); tag != "" {
+				if tag == "-" {
+					continue
+				}
+				if n := strings.Split(tag, ",")[0]; n != "" {
+					name = n
+				}
+			}
+			properties[name] = schemaOf(f.Type, schemas)
+		}
+		schemas[t.Name()] = map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+		}
+		return ref
+	}
+	return map[string]interface{}{}
+}
+
+// openAPI serves the OpenAPI document describing the versioned API
+func (s httpAPIServer) openAPI(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /openapi.json")
+	resultJSON, err := json.MarshalIndent(s.openAPISpec(), "", "  ")
+	if err != nil {
+		http.Error(w, "could not serialize result: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resultJSON)
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/golang/glog"
+	"golang.org/x/sys/unix"
+)
+
+// peerCredSupported is true because peers of the unix socket are identified
+// with SO_PEERCRED (see peercred_other.go)
+const peerCredSupported = true
+
+// peerCredListener wraps the unix socket listener and closes any connection
+// whose peer process isn't running as an allowed uid (checked with
+// SO_PEERCRED), so that other local users can't tick or /clear the DB
+type peerCredListener struct {
+	net.Listener
+	allowed map[uint32]bool
+}
+
+// newPeerCredListener wraps 'l' so that it only accepts connections from
+// processes running as the current user or one of 'allowedUIDs'
+func newPeerCredListener(l net.Listener, allowedUIDs []uint) net.Listener {
+	allowed := map[uint32]bool{uint32(os.Getuid()): true}
+	for _, uid := range allowedUIDs {
+		allowed[uint32(uid)] = true
+	}
+	return &peerCredListener{Listener: l, allowed: allowed}
+}
+
+// socketMode returns the permissions of the unix socket. connect() needs write
+// permission on the socket, so if other uids are allowed then anyone may
+// connect, and peerCredListener turns away those who aren't allowed
+func socketMode(allowedUIDs []uint) os.FileMode {
+	if len(allowedUIDs) > 0 {
+		return 0666
+	}
+	return 0600
+}
+
+// Accept returns the next connection from an allowed peer
+func (l *peerCredListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		cred, err := peerCred(conn)
+		if err != nil {
+			glog.Warningf("rejecting connection on unix socket: %v", err)
+			conn.Close()
+			continue
+		}
+		if !l.allowed[cred.Uid] {
+			glog.Warningf("rejecting connection on unix socket from uid %d (pid %d)",
+				cred.Uid, cred.Pid)
+			conn.Close()
+			continue
+		}
+		return &peerConn{Conn: conn, uid: cred.Uid}, nil
+	}
+}
+
+// peerCred returns the credentials of the process on the other end of 'conn'
+func peerCred(conn net.Conn) (*unix.Ucred, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, fmt.Errorf("expected unix connection but got %T", conn)
+	}
+	rawConn, err := unixConn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var cred *unix.Ucred
+	var credErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return nil, err
+	}
+	if credErr != nil {
+		return nil, fmt.Errorf("could not read peer credentials: %v", credErr)
+	}
+	return cred, nil
+}
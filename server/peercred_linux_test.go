@@ -0,0 +1,154 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/user"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/msteffen/golang-time-tracker/api"
+	cu "github.com/msteffen/golang-time-tracker/clientutil"
+	tu "github.com/msteffen/golang-time-tracker/testutil"
+)
+
+// peerSocketEnv holds the socket that TestPeerTick ticks over, when it's run
+// by tickAsUID
+const peerSocketEnv = "TIME_TRACKER_TEST_PEER_SOCKET"
+
+// nobody is the uid that tests connect to the socket as, other than the owner
+const nobody = 65534
+
+// TestPeerTick isn't a test on its own: tickAsUID runs it, as another uid, to
+// send a tick over the socket in $TIME_TRACKER_TEST_PEER_SOCKET
+func TestPeerTick(t *testing.T) {
+	socketPath := os.Getenv(peerSocketEnv)
+	if socketPath == "" {
+		t.Skip("only run by tickAsUID")
+	}
+	resp, err := cu.GetClient(socketPath).Post(APIPrefix+"/ticks", strings.NewReader(`{"label":"peer"}`))
+	if err != nil {
+		t.Fatalf("could not tick: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("could not tick: %s", ReadBody(t, resp))
+	}
+}
+
+// peerDir returns a new directory for a test server's socket that other uids
+// can reach (unlike testDir), and a copy of the test binary that they can run
+func peerDir(t *testing.T) (dir, binary string) {
+	t.Helper()
+	if os.Getuid() != 0 {
+		t.Skip("must run as root to connect to the socket as another uid")
+	}
+	dir, err := ioutil.TempDir("", "time-tracker-peer-test-")
+	tu.Check(t, tu.Nil(err))
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	tu.Check(t, tu.Nil(os.Chmod(dir, 0755)))
+
+	binary = path.Join(dir, "server.test")
+	src, err := os.Open(os.Args[0])
+	tu.Check(t, tu.Nil(err))
+	defer src.Close()
+	dst, err := os.OpenFile(binary, os.O_CREATE|os.O_WRONLY, 0755)
+	tu.Check(t, tu.Nil(err))
+	_, err = io.Copy(dst, src)
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Nil(dst.Close()),
+	)
+	return dir, binary
+}
+
+// tickAsUID sends a tick over the socket at 'socketPath' from a process running
+// as 'uid'
+func tickAsUID(binary, socketPath string, uid uint32) error {
+	cmd := exec.Command(binary, "-test.run=^TestPeerTick$")
+	cmd.Env = append(os.Environ(), peerSocketEnv+"="+socketPath)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: uid, Gid: uid},
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, out)
+	}
+	return nil
+}
+
+// TestAllowedUIDs checks that processes running as a uid in AllowedUIDs can
+// connect to the socket, and that others still can't
+func TestAllowedUIDs(t *testing.T) {
+	dir, binary := peerDir(t)
+	s := StartTestServerWithOptions(t, dir, &Options{AllowedUIDs: []uint{nobody}})
+	socketPath := path.Join(dir, "server.TestAllowedUIDs.sock")
+	info, err := os.Stat(socketPath)
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(info.Mode().Perm(), os.FileMode(0666)),
+	)
+
+	s.Set(time.Date(2017, 7, 1, 9, 0, 0, 0, time.UTC))
+	tu.Check(t, tu.Nil(tickAsUID(binary, socketPath, nobody)))
+	tu.Check(t, tu.Eq(tickAsUID(binary, socketPath, nobody-1) != nil, true))
+
+	// Only the allowed uid's tick arrived
+	resp, err := s.Get(APIPrefix + "/ticks")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	var ticks api.ListTicksResponse
+	tu.Check(t, tu.Nil(json.NewDecoder(resp.Body).Decode(&ticks)))
+	tu.Check(t, tu.Eq(len(ticks.Ticks), 1))
+	tu.Check(t, tu.Eq(ticks.Ticks[0].Label, "peer"))
+}
+
+// TestMultiUserPeerUID checks that in multi-user mode, ticks from another uid
+// over the unix socket go to that uid's user's DB
+func TestMultiUserPeerUID(t *testing.T) {
+	dir, binary := peerDir(t)
+	peer, err := user.LookupId(strconv.Itoa(nobody))
+	if err != nil {
+		t.Skipf("could not look up uid %d: %v", nobody, err)
+	}
+	dataDir := path.Join(dir, "data")
+	tu.Check(t, tu.Nil(os.Mkdir(dataDir, 0700)))
+	s := StartTestServerWithOptions(t, dir, &Options{
+		AllowedUIDs: []uint{nobody},
+		MultiUser:   true,
+		DataDir:     dataDir,
+	})
+	s.Set(time.Date(2017, 7, 1, 9, 0, 0, 0, time.UTC))
+	tu.Check(t, tu.Nil(tickAsUID(binary, path.Join(dir, "server.TestMultiUserPeerUID.sock"), nobody)))
+
+	// The tick isn't in the owner's DB, but the peer has a DB of their own
+	resp, err := s.Get(APIPrefix + "/ticks")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	var ticks api.ListTicksResponse
+	tu.Check(t, tu.Nil(json.NewDecoder(resp.Body).Decode(&ticks)))
+	tu.Check(t, tu.Eq(len(ticks.Ticks), 0))
+	resp, err = s.Get("/admin/users")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	var users api.ListUsersResponse
+	tu.Check(t, tu.Nil(json.NewDecoder(resp.Body).Decode(&users)))
+	tu.Check(t, tu.Eq(len(users.Users), 2))
+	tu.Check(t,
+		tu.Eq(users.Users[1].Name, peer.Username),
+		tu.Eq(users.Users[1].DBBytes > 0, true),
+	)
+}
@@ -0,0 +1,31 @@
+//go:build !linux
+// +build !linux
+
+package server
+
+import (
+	"net"
+	"os"
+
+	"github.com/golang/glog"
+)
+
+// peerCredSupported is false because SO_PEERCRED is Linux-only. Without it,
+// the unix socket's peers can't be identified, so multi-user mode is refused
+const peerCredSupported = false
+
+// newPeerCredListener returns 'l' unchanged: SO_PEERCRED is Linux-only, so on
+// other platforms the unix socket is protected only by its file permissions
+func newPeerCredListener(l net.Listener, allowedUIDs []uint) net.Listener {
+	if len(allowedUIDs) > 0 {
+		glog.Warningf("peer credential checks are only supported on linux; " +
+			"ignoring allowed uids")
+	}
+	return l
+}
+
+// socketMode returns the permissions of the unix socket. Without peer
+// credential checks, only the owner may connect
+func socketMode(allowedUIDs []uint) os.FileMode {
+	return 0600
+}
@@ -0,0 +1,362 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/msteffen/golang-time-tracker/api"
+)
+
+// APIPrefix is the path under which version 1 of the API is served. The web
+// UI's pages (/today, /week, etc) are not part of the API, and aren't
+// versioned
+const APIPrefix = "/api/v1"
+
+// queryParam documents one query parameter accepted by an API route
+type queryParam struct {
+	name, typ, description string
+}
+
+// route is one endpoint of the versioned API. newMux serves every route at
+// APIPrefix+path (and at its legacy path, if it has one), and openAPISpec
+// documents every route, so new endpoints only need to be added here
+type route struct {
+	method string
+	// The route's path, relative to APIPrefix. A final "{name}" segment matches
+	// any name (see pathParam)
+	path    string
+	handler http.HandlerFunc
+
+	// The unversioned path (and method, if different) at which this endpoint
+	// was served before the versioned API existed. Requests to it still work,
+	// but responses are marked as deprecated
+	legacyPath, legacyMethod string
+
+	// If true, the route is only served over the unix socket
+	socketOnly bool
+
+	// Documentation, for the OpenAPI spec. 'request' and 'response' are values
+	// of the request and response body types (nil if there's no JSON body).
+	// Responses that aren't JSON have their content type in 'responseType'
+	summary      string
+	query        []queryParam
+	request      interface{}
+	response     interface{}
+	responseType string
+}
+
+// routes returns every route in the versioned API
+func (s httpAPIServer) routes() []route {
+	return []route{
+		{
+			method:       "GET",
+			path:         "/status",
+			handler:      s.status,
+			legacyPath:   "/status",
+			summary:      "Report how long the server has been running",
+			responseType: "text/plain",
+		},
+		{
+			method:     "POST",
+			path:       "/ticks",
+			handler:    s.tick,
+			legacyPath: "/tick",
+			summary:    "Record a tick (a file save or other sign of work)",
+			request:    api.TickRequest{},
+		},
+		{
+			method:       "DELETE",
+			path:         "/ticks",
+			handler:      s.clear,
+			legacyPath:   "/clear",
+			legacyMethod: "POST",
+			summary: `Move every tick to the trash. The body must be {"confirm": ` +
+				`"yes"}, so that data can't be deleted by accident`,
+			request: map[string]string{},
+		},
+		{
+			method:  "GET",
+			path:    "/ticks",
+			handler: s.listTicks,
+			summary: "List raw ticks, a page at a time. With format=ndjson (or an " +
+				"Accept header of application/x-ndjson), every tick is streamed as " +
+				"newline-delimited JSON instead",
+			query: []queryParam{
+				{"start", "integer", "Start of the time range, in seconds since epoch"},
+				{"end", "integer", "End of the time range (exclusive), in seconds since epoch"},
+				{"label", "string", "Only list ticks with this label"},
+				{"host", "string", "Only list ticks received by this host"},
+				{"cursor", "string", "The NextCursor of the previous page"},
+				{"limit", "integer", "The most ticks to return (default 1000, at most 10000)"},
+				{"format", "string", `"ndjson" to stream every tick`},
+			},
+			response: api.ListTicksResponse{},
+		},
+		{
+			method:   "POST",
+			path:     "/ticks/delete",
+			handler:  s.deleteTicks,
+			summary:  "Move a range of ticks to the trash",
+			request:  api.DeleteTicksRequest{},
+			response: api.TrashBatch{},
+		},
+		{
+			method:   "POST",
+			path:     "/ticks/relabel",
+			handler:  s.relabel,
+			summary:  "Change the label of a range of ticks, keeping the originals in the trash",
+			request:  api.RelabelRequest{},
+			response: api.TrashBatch{},
+		},
+		{
+			method:   "GET",
+			path:     "/trash",
+			handler:  s.listTrash,
+			summary:  "List the batches of ticks in the trash (each made by one operation)",
+			response: api.ListTrashResponse{},
+		},
+		{
+			method:  "POST",
+			path:    "/undo",
+			handler: s.undo,
+			summary: "Undo the operation that made a batch of ticks in the trash. " +
+				"Ticks that collide with ticks added since are skipped and listed",
+			request:  api.UndoRequest{},
+			response: api.UndoResponse{},
+		},
+		{
+			method:     "GET",
+			path:       "/intervals",
+			handler:    s.getIntervals,
+			legacyPath: "/intervals",
+			summary:    "Get the intervals in which work was done",
+			query: []queryParam{
+				{"start", "integer", "Start of the time range, in seconds since epoch"},
+				{"end", "integer", "End of the time range, in seconds since epoch"},
+				{"host", "string", "Only use ticks received by this host"},
+				{"by_label", "boolean", "Split intervals wherever the label changes"},
+			},
+			response: api.GetIntervalsResponse{},
+		},
+		{
+			method:  "POST",
+			path:    "/intervals/batch",
+			handler: s.batchIntervals,
+			summary: "Get the intervals in each of several time ranges (listed, or " +
+				"given by start, end and step) at once",
+			request:  api.BatchIntervalsRequest{},
+			response: api.BatchIntervalsResponse{},
+		},
+		{
+			method:  "POST",
+			path:    "/intervals/rebuild",
+			handler: s.rebuildIntervals,
+			summary: "Recompute the stored intervals from the ticks in a range (or " +
+				"from every tick, if start and end are both 0)",
+			request:  api.RebuildIntervalsRequest{},
+			response: api.RebuildIntervalsResponse{},
+		},
+		{
+			method:  "GET",
+			path:    "/summary",
+			handler: s.summary,
+			summary: "Get the time worked on each label in each hour, day or week " +
+				"of a range (not counting time since the last tick)",
+			query: []queryParam{
+				{"start", "integer", "Start of the time range, in seconds since epoch"},
+				{"end", "integer", "End of the time range, in seconds since epoch"},
+				{"period", "string", `Length of each bucket: "hour", "day" (the default) or "week"`},
+				{"label", "string", "Only count time worked on this label"},
+				{"focus", "boolean", "Also measure how fragmented each bucket's work was"},
+			},
+			response: api.SummaryResponse{},
+		},
+		{
+			method:  "GET",
+			path:    "/stats/histogram",
+			handler: s.histogram,
+			summary: "Get the time worked in each hour of the day or day of the week " +
+				"over a range (not counting time since the last tick)",
+			query: []queryParam{
+				{"by", "string", `How to bin time: "hour" (of the day; the default) or "weekday"`},
+				{"from", "integer", "Start of the time range, in seconds since epoch (default: the first tick)"},
+				{"to", "integer", "End of the time range, in seconds since epoch (default: now)"},
+				{"label", "string", "Only count time worked on this label"},
+			},
+			response: api.HistogramResponse{},
+		},
+		{
+			method:     "GET",
+			path:       "/labels",
+			handler:    s.listLabels,
+			legacyPath: "/labels",
+			summary:    "List the labels that have metadata (e.g. a colour)",
+			response:   api.GetLabelsResponse{},
+		},
+		{
+			method:       "PUT",
+			path:         "/labels/{name}",
+			handler:      s.setLabel,
+			legacyPath:   "/labels",
+			legacyMethod: "POST",
+			summary: "Set a label's metadata. The body's Name may be omitted; an " +
+				"empty Color reverts the label to its default colour",
+			request: api.LabelInfo{},
+		},
+		{
+			method:     "GET",
+			path:       "/export",
+			handler:    s.export,
+			legacyPath: "/export",
+			summary:    "Export ticks, for merging into another server's DB",
+			query: []queryParam{
+				{"host", "string", "Only export ticks received by this host"},
+			},
+			response: api.TickSet{},
+		},
+		{
+			method:     "POST",
+			path:       "/sync",
+			handler:    s.sync,
+			legacyPath: "/sync",
+			summary:    "Merge exported ticks into this server's DB",
+			request:    api.TickSet{},
+			response:   api.SyncResponse{},
+		},
+		{
+			method:       "GET",
+			path:         "/events",
+			handler:      s.events,
+			legacyPath:   "/events",
+			summary:      "Stream events (as Server-Sent Events) as ticks arrive",
+			responseType: "text/event-stream",
+		},
+		{
+			method:     "POST",
+			path:       "/tokens",
+			handler:    s.createToken,
+			legacyPath: "/tokens",
+			socketOnly: true,
+			summary:    "Create a bearer token for requests made over TCP (unix socket only)",
+			request:    api.CreateTokenRequest{},
+			response:   api.CreateTokenResponse{},
+		},
+		{
+			method:     "POST",
+			path:       "/backups",
+			handler:    s.backup,
+			socketOnly: true,
+			summary: "Copy the DB to a new file on the server while it keeps " +
+				"serving (owner only; unix socket only)",
+			request:  api.BackupRequest{},
+			response: api.BackupResponse{},
+		},
+		{
+			method:     "POST",
+			path:       "/restore",
+			handler:    s.restore,
+			socketOnly: true,
+			summary: "Check a backup file on the server and replace the DB with " +
+				"it (owner only; unix socket only). In multi-user mode, only the " +
+				"owner's DB can be restored; other users' DBs are left as they are",
+			request:  api.RestoreRequest{},
+			response: api.RestoreResponse{},
+		},
+		{
+			method:     "GET",
+			path:       "/admin/users",
+			handler:    s.listUsers,
+			legacyPath: "/admin/users",
+			summary:    "List the users of a multi-user server (owner only)",
+			response:   api.ListUsersResponse{},
+		},
+		{
+			method:   "GET",
+			path:     "/openapi.json",
+			handler:  s.openAPI,
+			summary:  "Get this document",
+			response: map[string]interface{}{},
+		},
+	}
+}
+
+// pathParam returns the value of the final "{name}" segment of 'r's path, for
+// routes whose path is 'prefix'+"{name}". It returns "" if 'r' arrived at a
+// legacy path, which has no such segment
+func pathParam(r *http.Request, prefix string) string {
+	if !strings.HasPrefix(r.URL.Path, APIPrefix+prefix) {
+		return ""
+	}
+	return strings.TrimPrefix(r.URL.Path, APIPrefix+prefix)
+}
+
+// methodHandler dispatches requests for one path to the handler for their
+// method, so that each endpoint doesn't need to check the method itself
+type methodHandler struct {
+	path     string
+	handlers map[string]http.HandlerFunc
+}
+
+func (h methodHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if handler, ok := h.handlers[r.Method]; ok {
+		handler(w, r)
+		return
+	}
+	var allowed []string
+	for method := range h.handlers {
+		allowed = append(allowed, method)
+	}
+	sort.Strings(allowed)
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	msg := fmt.Sprintf("must use %s to access %s", strings.Join(allowed, " or "), h.path)
+	http.Error(w, msg, http.StatusMethodNotAllowed)
+}
+
+// deprecated wraps the handler of a legacy path, marking its responses as
+// deprecated in favour of 'successor' (per
+// https://datatracker.ietf.org/doc/html/rfc8594)
+func deprecated(successor string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		glog.Infof("%s is deprecated; clients should use %s instead", r.URL.Path, successor)
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+		handler(w, r)
+	}
+}
+
+// handleRoutes registers every route in the versioned API (and their legacy
+// aliases) with 'mux'. If 'socket' is false, socket-only routes are left out
+func (s httpAPIServer) handleRoutes(mux *http.ServeMux, socket bool) {
+	handlers := make(map[string]methodHandler) // by ServeMux pattern
+	var patterns []string
+	add := func(pattern, method string, handler http.HandlerFunc) {
+		h, ok := handlers[pattern]
+		if !ok {
+			h = methodHandler{path: pattern, handlers: make(map[string]http.HandlerFunc)}
+			handlers[pattern] = h
+			patterns = append(patterns, pattern)
+		}
+		h.handlers[method] = handler
+	}
+	for _, rt := range s.routes() {
+		if rt.socketOnly && !socket {
+			continue
+		}
+		// Paths ending in a parameter become subtree patterns (e.g.
+		// "/api/v1/labels/"), and handlers extract the parameter with pathParam
+		add(strings.TrimSuffix(APIPrefix+rt.path, "{name}"), rt.method, rt.handler)
+		if rt.legacyPath != "" {
+			method := rt.legacyMethod
+			if method == "" {
+				method = rt.method
+			}
+			add(rt.legacyPath, method, deprecated(APIPrefix+rt.path, rt.handler))
+		}
+	}
+	for _, pattern := range patterns {
+		mux.Handle(pattern, handlers[pattern])
+	}
+}
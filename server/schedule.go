@@ -0,0 +1,23 @@
+package server
+
+import "time"
+
+// scheduleInterval is how often the daemon runs its scheduled jobs (e.g.
+// purging expired trash, and checking whether a backup is due)
+const scheduleInterval = time.Hour
+
+// runScheduled runs each of 'jobs' once, and then again every time 'ticks'
+// receives, until 'stop' is closed. Jobs run one at a time, and a job that's
+// running when 'stop' is closed finishes before runScheduled returns
+func runScheduled(ticks <-chan time.Time, stop <-chan struct{}, jobs ...func()) {
+	for {
+		for _, job := range jobs {
+			job()
+		}
+		select {
+		case <-ticks:
+		case <-stop:
+			return
+		}
+	}
+}
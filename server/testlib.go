@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"net/http"
 	"path"
 	"runtime"
@@ -34,7 +35,18 @@ type TestServer struct {
 
 // Bring up an in-process time-tracker server, for the tests to talk to
 func StartTestServer(t *testing.T, tmpDir string) TestServer {
-	testPC, _, _, ok := runtime.Caller(1)
+	return startTestServer(t, tmpDir, nil)
+}
+
+// StartTestServerWithOptions is like StartTestServer, but passes 'opts' to
+// ServeOverHTTP (e.g. to also listen on a TCP port)
+func StartTestServerWithOptions(t *testing.T, tmpDir string, opts *Options) TestServer {
+	return startTestServer(t, tmpDir, opts)
+}
+
+func startTestServer(t *testing.T, tmpDir string, opts *Options) TestServer {
+	// Skip startTestServer and its exported wrapper to find the test's name
+	testPC, _, _, ok := runtime.Caller(2)
 	if !ok {
 		glog.Fatal("could not extract test name")
 	}
@@ -50,14 +62,15 @@ func StartTestServer(t *testing.T, tmpDir string) TestServer {
 	if err != nil {
 		glog.Fatal("could not create API Server: " + err.Error())
 	}
-	go ServeOverHTTP(socketPath, testClock, apiServer)
+	t.Cleanup(func() { apiServer.Close() })
+	go ServeOverHTTP(socketPath, testClock, apiServer, opts)
 
 	// Wait until the server is up before proceeding
 	client := cu.GetClient(socketPath)
 	secs := 60
 	for i := 0; i < secs; i++ {
 		glog.Infof("waiting until server is up to continue (%d/%d)", i, secs)
-		_, err := client.Get("/status")
+		_, err := client.Get(APIPrefix + "/status")
 		if err == nil {
 			return TestServer{t, client, testClock}
 		}
@@ -78,7 +91,7 @@ func (s TestServer) TickAt(label string, intervals ...int64) {
 	json.NewEncoder(buf).Encode(api.TickRequest{Label: label})
 	for _, i := range intervals {
 		s.TestingClock.Add(time.Duration(i * int64(time.Minute)))
-		resp, err := s.Client.Post("/tick", bytes.NewReader(buf.Bytes()))
+		resp, err := s.Client.Post(APIPrefix+"/ticks", bytes.NewReader(buf.Bytes()))
 		tu.Check(s.T,
 			tu.Nil(err),
 			tu.Eq(ReadBody(s.T, resp), ""),
@@ -86,3 +99,49 @@ func (s TestServer) TickAt(label string, intervals ...int64) {
 		)
 	}
 }
+
+// HistoryOptions configures SyntheticHistory
+type HistoryOptions struct {
+	Start        time.Time     // the first day of the history
+	Days         int           // the number of days in the history
+	Labels       []string      // the labels that ticks may have
+	TickInterval time.Duration // the average time between ticks while working
+	Seed         int64         // seeds the random choices, so that histories are reproducible
+}
+
+// SyntheticHistory generates a plausible history of ticks, for benchmarks. On
+// each weekday there are a few sessions of work, separated by breaks long
+// enough to end their intervals. Ticks arrive about every TickInterval during
+// a session, and now and then the label changes
+func SyntheticHistory(opts HistoryOptions) *api.TickSet {
+	r := rand.New(rand.NewSource(opts.Seed))
+	// between(lo, hi) returns a random duration in [lo, hi)
+	between := func(lo, hi time.Duration) time.Duration {
+		return lo + time.Duration(r.Int63n(int64(hi-lo)))
+	}
+	result := &api.TickSet{}
+	day := time.Date(opts.Start.Year(), opts.Start.Month(), opts.Start.Day(), 0, 0, 0, 0, opts.Start.Location())
+	for d := 0; d < opts.Days; d, day = d+1, day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		t := day.Add(between(8*time.Hour, 10*time.Hour))
+		label := opts.Labels[r.Intn(len(opts.Labels))]
+		for session := 0; session < 3; session++ {
+			end := t.Add(between(time.Hour, 3*time.Hour))
+			for ; t.Before(end); t = t.Add(between(opts.TickInterval/2, opts.TickInterval*3/2)) {
+				if r.Intn(50) == 0 {
+					label = opts.Labels[r.Intn(len(opts.Labels))]
+				}
+				result.Ticks = append(result.Ticks, api.TickRecord{
+					Time:  t.Unix(),
+					Nanos: int64(t.Nanosecond()),
+					Label: label,
+					Host:  "synthetic",
+				})
+			}
+			t = t.Add(between(30*time.Minute, 90*time.Minute)) // break
+		}
+	}
+	return result
+}
@@ -1,19 +1,35 @@
 package server
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/exec"
+	"path"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
 
 	"golang.org/x/net/html"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 
 	"github.com/msteffen/golang-time-tracker/api"
+	"github.com/msteffen/golang-time-tracker/pb"
 	tu "github.com/msteffen/golang-time-tracker/testutil"
 )
 
@@ -143,6 +159,58 @@ func TestGetIntervalsBoundary(t *testing.T) {
 	}
 }
 
+// TestTickZone checks that the zone sent with each tick is returned with the
+// intervals it forms, and that those intervals can be moved into their
+// recorded zone
+func TestTickZone(t *testing.T) {
+	s := StartTestServer(t, testDir)
+	ts := time.Date(
+		/* date */ 2017, 7, 1,
+		/* time */ 9, 0, 0,
+		/* nsec, location */ 0, time.UTC)
+	s.Set(ts)
+	for _, i := range []int64{0, 20} {
+		s.Add(time.Duration(i * int64(time.Minute)))
+		resp, err := s.PostString("/tick", `{"label":"work","zone":"+09:00"}`)
+		tu.Check(t,
+			tu.Nil(err),
+			tu.Eq(ReadBody(t, resp), ""),
+			tu.Eq(resp.StatusCode, http.StatusOK),
+		)
+	}
+
+	// Invalid zones are rejected
+	resp, err := s.PostString("/tick", `{"label":"work","zone":"Mars/Olympus_Mons"}`)
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusBadRequest),
+	)
+
+	morning := time.Date(2017, 7, 1, 0, 0, 0, 0, time.UTC)
+	url := fmt.Sprintf("/intervals?start=%d&end=%d",
+		morning.Unix(), morning.Add(24*time.Hour).Unix())
+	resp, err = s.Get(url)
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	var actual api.GetIntervalsResponse
+	json.NewDecoder(resp.Body).Decode(&actual)
+	expected := []api.Interval{{
+		Start: ts.Unix(),
+		End:   ts.Add(20 * time.Minute).Unix(),
+		Zone:  "+09:00",
+	}}
+	tu.Check(t, tu.Eq(actual.Intervals, expected))
+
+	// 9am UTC is 6pm in Tokyo, so the interval moves to 6pm UTC
+	tu.Check(t, tu.Eq(api.InRecordedZone(actual.Intervals, time.UTC), []api.Interval{{
+		Start: ts.Add(9 * time.Hour).Unix(),
+		End:   ts.Add(9*time.Hour + 20*time.Minute).Unix(),
+		Zone:  "+09:00",
+	}}))
+}
+
 func TestToday(t *testing.T) {
 	s := StartTestServer(t, testDir)
 	ts := time.Date(
@@ -183,6 +251,1719 @@ func TestToday(t *testing.T) {
 	tu.Check(t, tu.Eq(nIntervals, 2))
 }
 
+// TestTodayJSON checks the data that the /today page fetches to update itself
+func TestTodayJSON(t *testing.T) {
+	s := StartTestServer(t, testDir)
+	ts := time.Date(
+		/* date */ 2017, 7, 1,
+		/* time */ 9, 0, 0,
+		/* nsec, location */ 0, time.UTC)
+	s.Set(ts)
+	s.TickAt("work", 0, 20, 60, 20)
+	s.Add(5 * time.Minute)
+
+	resp, err := s.Get("/today?format=json")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+		tu.Eq(resp.Header.Get("Content-Type"), "application/json"),
+	)
+	var actual struct {
+		Divs                              []struct{ Left, Width int }
+		Morning, DayTotal, EndGap, MaxGap int64
+	}
+	tu.Check(t, tu.Nil(json.NewDecoder(resp.Body).Decode(&actual)))
+	tu.Check(t,
+		tu.Eq(len(actual.Divs), 2),
+		tu.Eq(actual.Morning, time.Date(2017, 7, 1, 0, 0, 0, 0, time.UTC).Unix()),
+		tu.Eq(actual.DayTotal, int64((45*time.Minute).Seconds())),
+		tu.Eq(actual.EndGap, int64((5*time.Minute).Seconds())),
+		tu.Eq(actual.MaxGap, api.MaxEventGap),
+	)
+
+	resp, err = s.Get("/today?format=xml")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusBadRequest),
+	)
+}
+
+// TestPastDay checks that /day/<date> renders days other than today
+func TestPastDay(t *testing.T) {
+	s := StartTestServer(t, testDir)
+	ts := time.Date(
+		/* date */ 2017, 7, 1,
+		/* time */ 9, 0, 0,
+		/* nsec, location */ 0, time.UTC)
+	s.Set(ts)
+	s.TickAt("work", 0, 20)
+	s.Set(ts.Add(48 * time.Hour))
+
+	type dayData struct {
+		Morning, DayTotal, EndGap int64
+		BgWidth                   float64
+		Live                      bool
+		Prev, Next, Today         string
+	}
+	get := func(url string) dayData {
+		t.Helper()
+		resp, err := s.Get(url)
+		tu.Check(t,
+			tu.Nil(err),
+			tu.Eq(resp.StatusCode, http.StatusOK),
+		)
+		var actual dayData
+		tu.Check(t, tu.Nil(json.NewDecoder(resp.Body).Decode(&actual)))
+		return actual
+	}
+	tu.Check(t, tu.Eq(get("/day/2017-07-01?format=json"), dayData{
+		Morning:  time.Date(2017, 7, 1, 0, 0, 0, 0, time.UTC).Unix(),
+		DayTotal: 20 * 60,
+		BgWidth:  500,
+		Prev:     "../day/2017-06-30",
+		Next:     "../day/2017-07-02",
+		Today:    "../today",
+	}))
+	tu.Check(t, tu.Eq(get("/day/2017-07-02?format=json").DayTotal, int64(0)))
+	resp, err := s.Get("/day/2017-07-01")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+		tu.Eq(countClass(t, resp, "timefg"), 1),
+	)
+	tu.Check(t, tu.Eq(get("/day/2017-07-03?format=json").Live, true))
+
+	// The width and time zone can be set with query parameters, and are kept in
+	// links to other days
+	tu.Check(t, tu.Eq(get("/day/2017-07-01?format=json&tz=%2B09:00&width=1000"), dayData{
+		Morning:  time.Date(2017, 7, 1, 0, 0, 0, 0, time.FixedZone("", 9*60*60)).Unix(),
+		DayTotal: 20 * 60,
+		BgWidth:  1000,
+		Prev:     "../day/2017-06-30?tz=%2B09%3A00&width=1000",
+		Next:     "../day/2017-07-02?tz=%2B09%3A00&width=1000",
+		Today:    "../today?tz=%2B09%3A00&width=1000",
+	}))
+
+	for _, url := range []string{
+		"/day/July-1",
+		"/day/2017-07-01?width=wide",
+		"/day/2017-07-01?width=5",
+		"/day/2017-07-01?tz=Mars/Olympus_Mons",
+	} {
+		resp, err = s.Get(url)
+		tu.Check(t,
+			tu.Nil(err),
+			tu.Eq(resp.StatusCode, http.StatusBadRequest),
+		)
+	}
+}
+
+// TestBarImage checks the /bar.svg and /bar.png images of a day's bar
+func TestBarImage(t *testing.T) {
+	s := StartTestServer(t, testDir)
+	ts := time.Date(
+		/* date */ 2017, 7, 1,
+		/* time */ 9, 0, 0,
+		/* nsec, location */ 0, time.UTC)
+	s.Set(ts)
+	s.TickAt("work", 0, 20)
+
+	// 9am is 3/8 of the way across the bar
+	resp, err := s.Get("/bar.svg?day=2017-07-01&width=240&height=10")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+		tu.Eq(resp.Header.Get("Content-Type"), "image/svg+xml"),
+	)
+	svg := ReadBody(t, resp)
+	tu.Check(t, tu.Eq(strings.Contains(svg, fmt.Sprintf(
+		`<rect x="90" y="0" width="3" height="10" fill="%s"><title>work</title></rect>`,
+		api.DefaultLabelColor("work"))), true))
+
+	pixel := func(url string, x, y int) (color.Color, image.Rectangle) {
+		t.Helper()
+		resp, err := s.Get(url)
+		tu.Check(t,
+			tu.Nil(err),
+			tu.Eq(resp.StatusCode, http.StatusOK),
+			tu.Eq(resp.Header.Get("Content-Type"), "image/png"),
+		)
+		img, err := png.Decode(resp.Body)
+		tu.Check(t, tu.Nil(err))
+		return color.RGBAModel.Convert(img.At(x, y)), img.Bounds()
+	}
+	rgb := func(hex string) color.Color {
+		r, g, b, err := api.ParseColor(hex)
+		tu.Check(t, tu.Nil(err))
+		return color.RGBA{r, g, b, 0xff}
+	}
+	c, bounds := pixel("/bar.png?width=240&height=10", 91, 5)
+	tu.Check(t,
+		tu.Eq(c, rgb(api.DefaultLabelColor("work"))),
+		tu.Eq(bounds, image.Rect(0, 0, 240, 16)),
+	)
+	c, bounds = pixel("/bar.png?width=240&height=10&axis=false&labels=false", 91, 5)
+	tu.Check(t,
+		tu.Eq(c, rgb(api.UnlabelledColor)),
+		tu.Eq(bounds, image.Rect(0, 0, 240, 10)),
+	)
+	c, _ = pixel("/bar.png?width=240&height=10", 10, 5)
+	tu.Check(t, tu.Eq(c, rgb("#d5d5d5")))
+
+	for _, url := range []string{
+		"/bar.png?width=0",
+		"/bar.svg?height=tall",
+		"/bar.svg?axis=maybe",
+		"/bar.svg?day=July-1",
+	} {
+		resp, err = s.Get(url)
+		tu.Check(t,
+			tu.Nil(err),
+			tu.Eq(resp.StatusCode, http.StatusBadRequest),
+		)
+	}
+}
+
+// TestTemplateOverrides checks that the web UI's templates and static assets
+// can be overridden, and that overrides are reloaded in dev mode
+func TestTemplateOverrides(t *testing.T) {
+	dir := path.Join(testDir, "TestTemplateOverrides")
+	tu.Check(t, tu.Nil(os.MkdirAll(path.Join(dir, "static"), 0700)))
+	write := func(name, content string) {
+		t.Helper()
+		tu.Check(t, tu.Nil(ioutil.WriteFile(path.Join(dir, name), []byte(content), 0600)))
+	}
+	write("today.html.template", `custom {{formatDuration .DayTotal}}`)
+	write("static/theme.css", `body { color: #123456; }`)
+	write("static/logo.txt", `logo`)
+	s := StartTestServerWithOptions(t, testDir, &Options{
+		TemplateDir:  dir,
+		DevTemplates: true,
+	})
+	get := func(url string) string {
+		t.Helper()
+		resp, err := s.Get(url)
+		tu.Check(t,
+			tu.Nil(err),
+			tu.Eq(resp.StatusCode, http.StatusOK),
+		)
+		return ReadBody(t, resp)
+	}
+	tu.Check(t, tu.Eq(get("/today"), "custom 0h 00m"))
+	write("today.html.template", `changed`)
+	tu.Check(t, tu.Eq(get("/today"), "changed"))
+	// Pages that aren't overridden use the built-in template, plus the theme
+	tu.Check(t, tu.Eq(strings.Contains(get("/week"), `body { color: #123456; }`), true))
+	tu.Check(t, tu.Eq(get("/static/logo.txt"), "logo"))
+
+	// Broken or misnamed templates are reported at startup
+	write("today.html.template", `{{range}}`)
+	err := ServeOverHTTP(path.Join(testDir, "unused.sock"), s.TestingClock, nil,
+		&Options{TemplateDir: dir})
+	tu.Check(t, tu.Eq(err != nil, true))
+	tu.Check(t, tu.Eq(strings.Contains(err.Error(), path.Join(dir, "today.html.template")), true))
+	tu.Check(t, tu.Nil(os.Remove(path.Join(dir, "today.html.template"))))
+	write("todya.html.template", `typo`)
+	err = ServeOverHTTP(path.Join(testDir, "unused.sock"), s.TestingClock, nil,
+		&Options{TemplateDir: dir})
+	tu.Check(t, tu.Eq(err != nil, true))
+	tu.Check(t, tu.Eq(strings.Contains(err.Error(), "todya.html.template"), true))
+}
+
+// TestLabels checks that intervals can be split by label, and that each
+// label's colour can be set
+func TestLabels(t *testing.T) {
+	s := StartTestServer(t, testDir)
+	ts := time.Date(
+		/* date */ 2017, 7, 1,
+		/* time */ 9, 0, 0,
+		/* nsec, location */ 0, time.UTC)
+	s.Set(ts)
+	s.TickAt("a", 0, 10)
+	s.TickAt("b", 10, 10)
+
+	url := fmt.Sprintf("/intervals?start=%d&end=%d&by_label=true",
+		ts.Unix(), ts.Add(time.Hour).Unix())
+	resp, err := s.Get(url)
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	var actual api.GetIntervalsResponse
+	json.NewDecoder(resp.Body).Decode(&actual)
+	// The time between the last "a" tick and the first "b" tick is spent on "b"
+	tu.Check(t, tu.Eq(actual.Intervals, []api.Interval{
+		{Start: ts.Unix(), End: ts.Add(10 * time.Minute).Unix(), Label: "a"},
+		{Start: ts.Add(10 * time.Minute).Unix(), End: ts.Add(30 * time.Minute).Unix(), Label: "b"},
+	}))
+
+	// Set a colour for "a"
+	resp, err = s.PostString("/labels", `{"name":"a","color":"#123456"}`)
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(ReadBody(t, resp), ""),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	resp, err = s.PostString("/labels", `{"name":"b","color":"blue"}`)
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusBadRequest),
+	)
+	resp, err = s.Get("/labels")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	var labels api.GetLabelsResponse
+	json.NewDecoder(resp.Body).Decode(&labels)
+	tu.Check(t, tu.Eq(labels.Labels, []api.LabelInfo{{Name: "a", Color: "#123456"}}))
+
+	// /today's legend has the time spent on each label, in its colour
+	resp, err = s.Get("/today?format=json")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	var today struct {
+		Legend []struct {
+			Label, Color string
+			Total        int64
+		}
+	}
+	tu.Check(t, tu.Nil(json.NewDecoder(resp.Body).Decode(&today)))
+	tu.Check(t,
+		tu.Eq(len(today.Legend), 2),
+		tu.Eq(today.Legend[0].Label, "b"),
+		tu.Eq(today.Legend[0].Color, api.DefaultLabelColor("b")),
+		tu.Eq(today.Legend[0].Total, int64(20*60)),
+		tu.Eq(today.Legend[1].Label, "a"),
+		tu.Eq(today.Legend[1].Color, "#123456"),
+		tu.Eq(today.Legend[1].Total, int64(10*60)),
+	)
+}
+
+// countClass parses the HTML page in 'resp' and returns the number of elements
+// with the class 'class'
+func countClass(t *testing.T, resp *http.Response, class string) int {
+	t.Helper()
+	doc, err := html.Parse(resp.Body)
+	tu.Check(t, tu.Nil(err))
+	count := 0
+	q := []*html.Node{doc}
+	var n *html.Node
+	for len(q) > 0 {
+		n, q = q[0], q[1:]
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			q = append(q, c) // Schedule children
+		}
+		for _, a := range n.Attr {
+			if a.Key != "class" {
+				continue
+			}
+			for _, c := range strings.Fields(a.Val) {
+				if c == class {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
+
+// TestCalendar checks that the /week, /month and /year pages draw each day's
+// work
+func TestCalendar(t *testing.T) {
+	s := StartTestServer(t, testDir)
+	// 2017-07-03 is a Monday
+	s.Set(time.Date(2017, 7, 3, 9, 0, 0, 0, time.UTC))
+	s.TickAt("work", 0, 20)
+	s.Set(time.Date(2017, 7, 5, 9, 0, 0, 0, time.UTC))
+	s.TickAt("work", 0, 20, 20, 20)
+
+	get := func(url string) *http.Response {
+		t.Helper()
+		resp, err := s.Get(url)
+		tu.Check(t,
+			tu.Nil(err),
+			tu.Eq(resp.StatusCode, http.StatusOK),
+		)
+		return resp
+	}
+	tu.Check(t, tu.Eq(countClass(t, get("/week"), "timefg"), 2))
+	tu.Check(t, tu.Eq(countClass(t, get("/week"), "day"), 7))
+	tu.Check(t, tu.Eq(countClass(t, get("/week?date=2017-07-02"), "timefg"), 0))
+	tu.Check(t, tu.Eq(countClass(t, get("/month"), "timefg"), 2))
+	tu.Check(t, tu.Eq(countClass(t, get("/month?date=2017-06-30"), "timefg"), 0))
+	tu.Check(t, tu.Eq(countClass(t, get("/year"), "level1"), 2))
+	tu.Check(t, tu.Eq(countClass(t, get("/year"), "level0"), 363))
+
+	resp, err := s.Get("/week?date=July-4")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusBadRequest),
+	)
+}
+
+// TestAPIv1 checks that the versioned API is served under APIPrefix, that the
+// unversioned paths still work but are marked as deprecated, and that the
+// OpenAPI document describes every route
+func TestAPIv1(t *testing.T) {
+	s := StartTestServer(t, testDir)
+	resp, err := s.Get(APIPrefix + "/status")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+		tu.Eq(resp.Header.Get("Deprecation"), ""),
+	)
+	resp, err = s.Get("/status")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+		tu.Eq(resp.Header.Get("Deprecation"), "true"),
+		tu.Eq(resp.Header.Get("Link"), `</api/v1/status>; rel="successor-version"`),
+	)
+
+	// Labels are named by the path
+	resp, err = s.Put(APIPrefix+"/labels/a", strings.NewReader(`{"color":"#123456"}`))
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(ReadBody(t, resp), ""),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	resp, err = s.Put(APIPrefix+"/labels/a", strings.NewReader(`{"name":"b"}`))
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusBadRequest),
+	)
+	resp, err = s.Get(APIPrefix + "/labels")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	var labels api.GetLabelsResponse
+	json.NewDecoder(resp.Body).Decode(&labels)
+	tu.Check(t, tu.Eq(labels.Labels, []api.LabelInfo{{Name: "a", Color: "#123456"}}))
+
+	// Methods are checked for every route
+	resp, err = s.Put(APIPrefix+"/ticks", strings.NewReader(`{}`))
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusMethodNotAllowed),
+		tu.Eq(resp.Header.Get("Allow"), "DELETE, GET, POST"),
+	)
+	s.TickAt("work", 0)
+	resp, err = s.Delete(APIPrefix+"/ticks", strings.NewReader(`{"confirm":"yes"}`))
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(ReadBody(t, resp), ""),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+
+	resp, err = s.Get(APIPrefix + "/openapi.json")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	var spec struct {
+		OpenAPI    string
+		Paths      map[string]map[string]json.RawMessage
+		Components struct {
+			Schemas map[string]json.RawMessage
+		}
+	}
+	tu.Check(t, tu.Nil(json.NewDecoder(resp.Body).Decode(&spec)))
+	tu.Check(t, tu.Eq(spec.OpenAPI, "3.0.3"))
+	for _, rt := range (httpAPIServer{}).routes() {
+		_, ok := spec.Paths[rt.path][strings.ToLower(rt.method)]
+		tu.Check(t, tu.Eq(ok, true))
+	}
+	_, ok := spec.Components.Schemas["TickRequest"]
+	tu.Check(t, tu.Eq(ok, true))
+}
+
+// TestMetrics checks that /metrics reports ticks (by label and source) and
+// the time worked today
+func TestMetrics(t *testing.T) {
+	s := StartTestServer(t, testDir)
+	s.Set(time.Date(
+		/* date */ 2017, 7, 1,
+		/* time */ 9, 0, 0,
+		/* nsec, location */ 0, time.UTC))
+	s.TickAt("metrics-a", 0, 10)
+	resp, err := s.PostString(APIPrefix+"/ticks", `{"label":"metrics-b","source":"vim"}`)
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	s.Add(5 * time.Minute)
+
+	resp, err = s.Get("/metrics")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(ReadBody(t, resp), "\n") {
+		lines[line] = true
+	}
+	for _, expected := range []string{
+		`timetracker_ticks_received_total{label="metrics-a",source="unknown"} 2`,
+		`timetracker_ticks_received_total{label="metrics-b",source="vim"} 1`,
+		"timetracker_today_worked_seconds 900",
+		"timetracker_current_interval_seconds 900",
+		"# TYPE timetracker_tick_insert_seconds histogram",
+		"# TYPE timetracker_db_size_bytes gauge",
+	} {
+		tu.Check(t, tu.Eq(lines[expected], true))
+	}
+}
+
+// TestTCPToken checks that requests made over the optional TCP listener must
+// carry a token created over the unix socket
+func TestTCPToken(t *testing.T) {
+	// Find a free port for the TCP listener
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	tu.Check(t, tu.Nil(err))
+	addr := l.Addr().String()
+	l.Close()
+	s := StartTestServerWithOptions(t, testDir, &Options{ListenAddr: addr})
+
+	get := func(path, token string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest("GET", "http://"+addr+path, nil)
+		tu.Check(t, tu.Nil(err))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		tu.Check(t, tu.Nil(err))
+		return resp
+	}
+	tu.Check(t, tu.Eq(get("/status", "").StatusCode, http.StatusUnauthorized))
+	tu.Check(t, tu.Eq(get("/status", "not-a-token").StatusCode, http.StatusUnauthorized))
+
+	// Create a token over the unix socket
+	resp, err := s.PostString("/tokens", `{"name":"test"}`)
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	var tokenResp api.CreateTokenResponse
+	tu.Check(t, tu.Nil(json.NewDecoder(resp.Body).Decode(&tokenResp)))
+
+	tu.Check(t, tu.Eq(get("/status", tokenResp.Token).StatusCode, http.StatusOK))
+	// Tokens can't be created over TCP
+	tu.Check(t, tu.Eq(get("/tokens", tokenResp.Token).StatusCode, http.StatusNotFound))
+	// Tokens aren't accepted in the URL
+	tu.Check(t, tu.Eq(get("/status?token="+tokenResp.Token, "").StatusCode, http.StatusUnauthorized))
+
+	// Browsers log in with a form, which sets a cookie holding the token
+	noRedirect := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	resp, err = noRedirect.PostForm("http://"+addr+"/login", url.Values{"token": {"not-a-token"}})
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusUnauthorized),
+		tu.Eq(len(resp.Cookies()), 0),
+	)
+	resp, err = noRedirect.PostForm("http://"+addr+"/login", url.Values{"token": {tokenResp.Token}})
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusSeeOther),
+		tu.Eq(resp.Header.Get("Location"), "/today"),
+	)
+	tu.Check(t, tu.Eq(len(resp.Cookies()), 1))
+	req, err := http.NewRequest("GET", "http://"+addr+"/status", nil)
+	tu.Check(t, tu.Nil(err))
+	req.AddCookie(resp.Cookies()[0])
+	resp, err = http.DefaultClient.Do(req)
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+}
+
+// TestMultiUser checks that, in multi-user mode, each user's ticks are stored
+// in their own DB
+func TestMultiUser(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	tu.Check(t, tu.Nil(err))
+	addr := l.Addr().String()
+	l.Close()
+	dataDir := path.Join(testDir, "TestMultiUser")
+	tu.Check(t, tu.Nil(os.Mkdir(dataDir, 0700)))
+	s := StartTestServerWithOptions(t, testDir, &Options{
+		ListenAddr: addr,
+		MultiUser:  true,
+		DataDir:    dataDir,
+	})
+	ts := time.Date(
+		/* date */ 2017, 7, 1,
+		/* time */ 9, 0, 0,
+		/* nsec, location */ 0, time.UTC)
+	s.Set(ts)
+
+	// The owner creates a token for "alice", who ticks over TCP
+	resp, err := s.PostString("/tokens", `{"name":"laptop","user":"alice"}`)
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	var tokenResp api.CreateTokenResponse
+	tu.Check(t, tu.Nil(json.NewDecoder(resp.Body).Decode(&tokenResp)))
+	do := func(method, path, body string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, "http://"+addr+path, strings.NewReader(body))
+		tu.Check(t, tu.Nil(err))
+		req.Header.Set("Authorization", "Bearer "+tokenResp.Token)
+		resp, err := http.DefaultClient.Do(req)
+		tu.Check(t, tu.Nil(err))
+		return resp
+	}
+	for _, i := range []int64{0, 20} {
+		s.Add(time.Duration(i * int64(time.Minute)))
+		tu.Check(t, tu.Eq(do("POST", "/tick", `{"label":"work"}`).StatusCode, http.StatusOK))
+	}
+
+	// alice sees her interval, but the owner doesn't
+	url := fmt.Sprintf("/intervals?start=%d&end=%d", ts.Unix(), ts.Add(time.Hour).Unix())
+	var actual api.GetIntervalsResponse
+	json.NewDecoder(do("GET", url, "").Body).Decode(&actual)
+	tu.Check(t, tu.Eq(actual.Intervals, []api.Interval{
+		{Start: ts.Unix(), End: ts.Add(20 * time.Minute).Unix()},
+	}))
+	resp, err = s.Get(url)
+	tu.Check(t, tu.Nil(err))
+	actual = api.GetIntervalsResponse{}
+	json.NewDecoder(resp.Body).Decode(&actual)
+	tu.Check(t, tu.Eq(actual.Intervals, []api.Interval{}))
+
+	// Only the owner may list users. Listing doesn't create DBs for users who
+	// haven't made requests ("bob")
+	tu.Check(t, tu.Nil(os.Mkdir(path.Join(dataDir, "users", "bob"), 0700)))
+	tu.Check(t, tu.Eq(do("GET", "/admin/users", "").StatusCode, http.StatusForbidden))
+	resp, err = s.Get("/admin/users")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	var users api.ListUsersResponse
+	tu.Check(t, tu.Nil(json.NewDecoder(resp.Body).Decode(&users)))
+	tu.Check(t, tu.Eq(len(users.Users), 3))
+	tu.Check(t, tu.Eq(users.Users[1].Name, "alice"))
+	tu.Check(t, tu.Eq(users.Users[1].DBBytes > 0, true))
+	tu.Check(t, tu.Eq(users.Users[2], api.UserInfo{Name: "bob"}))
+	_, err = os.Stat(path.Join(dataDir, "users", "bob", "db"))
+	tu.Check(t, tu.Eq(os.IsNotExist(err), true))
+}
+
+// TestSync checks that merging ticks from another host is idempotent, and that
+// each host's view can be rebuilt afterwards
+func TestSync(t *testing.T) {
+	s := StartTestServer(t, testDir)
+	ts := time.Date(
+		/* date */ 2017, 7, 1,
+		/* time */ 9, 0, 0,
+		/* nsec, location */ 0, time.UTC)
+	s.Set(ts)
+	s.TickAt("work", 0, 10) // local ticks at 9:00 and 9:10
+
+	// Merge ticks from "desktop" at 10:00 and 10:10 (twice)
+	buf := &bytes.Buffer{}
+	json.NewEncoder(buf).Encode(api.TickSet{Ticks: []api.TickRecord{
+		{Time: ts.Add(60 * time.Minute).Unix(), Label: "work", Host: "desktop"},
+		{Time: ts.Add(70 * time.Minute).Unix(), Label: "work", Host: "desktop"},
+	}})
+	for _, expected := range []api.SyncResponse{{Added: 2}, {Duplicates: 2}} {
+		resp, err := s.Post("/sync", bytes.NewReader(buf.Bytes()))
+		tu.Check(t,
+			tu.Nil(err),
+			tu.Eq(resp.StatusCode, http.StatusOK),
+		)
+		var actual api.SyncResponse
+		tu.Check(t, tu.Nil(json.NewDecoder(resp.Body).Decode(&actual)))
+		tu.Check(t, tu.Eq(actual, expected))
+	}
+
+	getIntervals := func(host string) []api.Interval {
+		url := fmt.Sprintf("/intervals?start=%d&end=%d&host=%s",
+			ts.Unix(), ts.Add(2*time.Hour).Unix(), host)
+		resp, err := s.Get(url)
+		tu.Check(t,
+			tu.Nil(err),
+			tu.Eq(resp.StatusCode, http.StatusOK),
+		)
+		var actual api.GetIntervalsResponse
+		json.NewDecoder(resp.Body).Decode(&actual)
+		return actual.Intervals
+	}
+	local := api.Interval{Start: ts.Unix(), End: ts.Add(10 * time.Minute).Unix()}
+	desktop := api.Interval{
+		Start: ts.Add(60 * time.Minute).Unix(),
+		End:   ts.Add(70 * time.Minute).Unix(),
+	}
+	tu.Check(t, tu.Eq(getIntervals(""), []api.Interval{local, desktop}))
+	tu.Check(t, tu.Eq(getIntervals("desktop"), []api.Interval{desktop}))
+}
+
+// TestIntervalCache checks that the stored intervals, which are updated
+// incrementally as ticks arrive and history is edited, match intervals rebuilt
+// from scratch
+func TestIntervalCache(t *testing.T) {
+	s := StartTestServer(t, testDir)
+	ts := time.Date(
+		/* date */ 2017, 7, 1,
+		/* time */ 9, 0, 0,
+		/* nsec, location */ 0, time.UTC)
+	s.Set(ts)
+	s.TickAt("a", 0, 10) // 9:00 and 9:10
+	s.TickAt("b", 30)    // 9:40, which starts a new interval
+	s.Add(5 * time.Minute)
+
+	getIntervals := func(byLabel bool) []api.Interval {
+		t.Helper()
+		url := fmt.Sprintf(APIPrefix+"/intervals?start=%d&end=%d&by_label=%t",
+			ts.Unix(), ts.Add(2*time.Hour).Unix(), byLabel)
+		resp, err := s.Get(url)
+		tu.Check(t,
+			tu.Nil(err),
+			tu.Eq(resp.StatusCode, http.StatusOK),
+		)
+		var actual api.GetIntervalsResponse
+		json.NewDecoder(resp.Body).Decode(&actual)
+		return actual.Intervals
+	}
+	// check returns the server's union and per-label intervals, after checking
+	// that rebuilding them doesn't change them
+	check := func() ([]api.Interval, []api.Interval) {
+		t.Helper()
+		union, byLabel := getIntervals(false), getIntervals(true)
+		resp, err := s.PostString(APIPrefix+"/intervals/rebuild", `{}`)
+		tu.Check(t,
+			tu.Nil(err),
+			tu.Eq(resp.StatusCode, http.StatusOK),
+		)
+		tu.Check(t,
+			tu.Eq(getIntervals(false), union),
+			tu.Eq(getIntervals(true), byLabel),
+		)
+		return union, byLabel
+	}
+	at := func(minutes int64) int64 {
+		return ts.Add(time.Duration(minutes) * time.Minute).Unix()
+	}
+	union, byLabel := check()
+	tu.Check(t,
+		tu.Eq(union, []api.Interval{{Start: at(0), End: at(10)}, {Start: at(40), End: at(45)}}),
+		tu.Eq(byLabel, []api.Interval{
+			{Start: at(0), End: at(10), Label: "a"},
+			{Start: at(40), End: at(45), Label: "b"},
+		}),
+	)
+
+	// Merge a tick at 9:25 from another host, which joins the two intervals
+	resp, err := s.PostString(APIPrefix+"/sync", fmt.Sprintf(
+		`{"ticks":[{"time":%d,"label":"c","host":"desktop"}]}`, at(25)))
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	joined := []api.Interval{{Start: at(0), End: at(45)}}
+	joinedByLabel := []api.Interval{
+		{Start: at(0), End: at(10), Label: "a"},
+		{Start: at(10), End: at(25), Label: "c"},
+		{Start: at(25), End: at(45), Label: "b"},
+	}
+	union, byLabel = check()
+	tu.Check(t,
+		tu.Eq(union, joined),
+		tu.Eq(byLabel, joinedByLabel),
+	)
+
+	// Deleting the merged tick splits them again, and undoing that rejoins them
+	resp, err = s.PostString(APIPrefix+"/ticks/delete", fmt.Sprintf(
+		`{"start":%d,"end":%d,"label":"c"}`, at(20), at(30)))
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	var batch api.TrashBatch
+	json.NewDecoder(resp.Body).Decode(&batch)
+	union, _ = check()
+	tu.Check(t, tu.Eq(union, []api.Interval{{Start: at(0), End: at(10)}, {Start: at(40), End: at(45)}}))
+	resp, err = s.PostString(APIPrefix+"/undo", fmt.Sprintf(`{"batch":%d}`, batch.ID))
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	union, byLabel = check()
+	tu.Check(t,
+		tu.Eq(union, joined),
+		tu.Eq(byLabel, joinedByLabel),
+	)
+
+	// Relabelling splits the per-label intervals differently
+	resp, err = s.PostString(APIPrefix+"/ticks/relabel", fmt.Sprintf(
+		`{"start":%d,"end":%d,"to":"b"}`, at(20), at(30)))
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	_, byLabel = check()
+	tu.Check(t, tu.Eq(byLabel, []api.Interval{
+		{Start: at(0), End: at(10), Label: "a"},
+		{Start: at(10), End: at(45), Label: "b"},
+	}))
+}
+
+// TestSummary checks that the hourly and daily roll-ups follow ticks and
+// edits to history
+func TestSummary(t *testing.T) {
+	s := StartTestServer(t, testDir)
+	ts := time.Date(
+		/* date */ 2017, 7, 1,
+		/* time */ 9, 50, 0,
+		/* nsec, location */ 0, time.Local)
+	s.Set(ts)
+	s.TickAt("a", 0, 10, 10) // 9:50, 10:00 and 10:10
+	s.TickAt("b", 10)        // 10:20
+	hour := ts.Add(-50 * time.Minute)
+	day := time.Date(2017, 7, 1, 0, 0, 0, 0, time.Local)
+
+	summary := func(period string, start, end time.Time) []api.SummaryBucket {
+		t.Helper()
+		resp, err := s.Get(fmt.Sprintf(APIPrefix+"/summary?start=%d&end=%d&period=%s",
+			start.Unix(), end.Unix(), period))
+		tu.Check(t,
+			tu.Nil(err),
+			tu.Eq(resp.StatusCode, http.StatusOK),
+		)
+		var actual api.SummaryResponse
+		tu.Check(t, tu.Nil(json.NewDecoder(resp.Body).Decode(&actual)))
+		return actual.Buckets
+	}
+	minutes := func(m int64) int64 { return m * 60 }
+	bucket := func(start time.Time, d time.Duration, seconds map[string]int64) api.SummaryBucket {
+		b := api.SummaryBucket{Start: start.Unix(), End: start.Add(d).Unix(), Seconds: seconds}
+		for _, s := range seconds {
+			b.Total += s
+		}
+		return b
+	}
+	tu.Check(t,
+		tu.Eq(summary(api.Hour, hour, hour.Add(2*time.Hour)), []api.SummaryBucket{
+			bucket(hour, time.Hour, map[string]int64{"a": minutes(10)}),
+			bucket(hour.Add(time.Hour), time.Hour, map[string]int64{"a": minutes(10), "b": minutes(10)}),
+		}),
+		tu.Eq(summary(api.Day, day, day.Add(time.Hour)), []api.SummaryBucket{
+			bucket(day, 24*time.Hour, map[string]int64{"a": minutes(20), "b": minutes(10)}),
+		}),
+	)
+
+	// Relabelling and deleting ticks moves time between labels. The 10:00 tick
+	// becomes "b", and the 10:20 tick is deleted
+	resp, err := s.PostString(APIPrefix+"/ticks/relabel", fmt.Sprintf(
+		`{"start":%d,"end":%d,"to":"b"}`, ts.Add(10*time.Minute).Unix(), ts.Add(11*time.Minute).Unix()))
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	resp, err = s.PostString(APIPrefix+"/ticks/delete", fmt.Sprintf(
+		`{"start":%d,"end":%d}`, ts.Add(30*time.Minute).Unix(), ts.Add(time.Hour).Unix()))
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	expected := []api.SummaryBucket{
+		bucket(day, 24*time.Hour, map[string]int64{"a": minutes(10), "b": minutes(10)}),
+	}
+	tu.Check(t, tu.Eq(summary(api.Day, day, day.Add(time.Hour)), expected))
+
+	// Rebuilding the roll-ups from scratch doesn't change them
+	resp, err = s.PostString(APIPrefix+"/intervals/rebuild", `{}`)
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	tu.Check(t, tu.Eq(summary(api.Day, day, day.Add(time.Hour)), expected))
+
+	// Invalid periods are rejected
+	resp, err = s.Get(fmt.Sprintf(APIPrefix+"/summary?start=%d&end=%d&period=month",
+		day.Unix(), day.Add(time.Hour).Unix()))
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusInternalServerError),
+	)
+}
+
+// TestSummaryDST checks that hourly roll-ups handle the nights when clocks go
+// forward (which have a 23-hour day) and back (which have a 25-hour day, with
+// two hours that start at 1:00)
+func TestSummaryDST(t *testing.T) {
+	const zone = "America/New_York"
+	if os.Getenv("TZ") != zone {
+		// time.Local can't be changed while servers' goroutines are using it,
+		// so run this test in a new process in 'zone'
+		if _, err := time.LoadLocation(zone); err != nil {
+			t.Skipf("could not load time zone: %v", err)
+		}
+		cmd := exec.Command(os.Args[0], "-test.run=^TestSummaryDST$")
+		cmd.Env = append(os.Environ(), "TZ="+zone)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("TestSummaryDST failed in %s: %v\n%s", zone, err, out)
+		}
+		return
+	}
+	ny := time.Local
+
+	s := StartTestServer(t, testDir)
+	for _, c := range []struct {
+		midnight time.Time
+		hours    int // the number of hours between midnight and 4:00
+	}{
+		{time.Date(2023, 3, 12, 0, 0, 0, 0, ny), 3}, // spring forward
+		{time.Date(2023, 11, 5, 0, 0, 0, 0, ny), 5}, // fall back
+	} {
+		// Work from midnight to 4:00
+		s.Set(c.midnight)
+		s.TickAt("a", 0)
+		for i := 0; i < 6*c.hours; i++ {
+			s.TickAt("a", 10)
+		}
+		for _, rebuild := range []bool{false, true} {
+			if rebuild {
+				resp, err := s.PostString(APIPrefix+"/intervals/rebuild", `{}`)
+				tu.Check(t,
+					tu.Nil(err),
+					tu.Eq(resp.StatusCode, http.StatusOK),
+				)
+			}
+			resp, err := s.Get(fmt.Sprintf(APIPrefix+"/summary?start=%d&end=%d&period=hour",
+				c.midnight.Unix(), c.midnight.Add(time.Duration(c.hours)*time.Hour).Unix()))
+			tu.Check(t,
+				tu.Nil(err),
+				tu.Eq(resp.StatusCode, http.StatusOK),
+			)
+			var actual api.SummaryResponse
+			tu.Check(t,
+				tu.Nil(json.NewDecoder(resp.Body).Decode(&actual)),
+				tu.Eq(len(actual.Buckets), c.hours),
+			)
+			for i, b := range actual.Buckets {
+				tu.Check(t,
+					tu.Eq(b.Start, c.midnight.Add(time.Duration(i)*time.Hour).Unix()),
+					tu.Eq(b.End-b.Start, int64(3600)),
+					tu.Eq(b.Seconds, map[string]int64{"a": 3600}),
+				)
+			}
+		}
+	}
+}
+
+// TestFocus checks that summaries report how fragmented each day's work was
+func TestFocus(t *testing.T) {
+	s := StartTestServer(t, testDir)
+	day := time.Date(2017, 7, 1, 0, 0, 0, 0, time.Local)
+	s.Set(day.Add(9 * time.Hour))
+	s.TickAt("a", 0, 10, 10, 10, 10, 10, 10) // 9:00 to 10:00
+	s.TickAt("b", 10, 10, 10)                // 10:10 to 10:30, continuing "a"'s interval
+	s.TickAt("a", 60, 10)                    // 11:30 and 11:40
+	s.TickAt("c", 80, 20)                    // 13:00 and 13:20
+
+	resp, err := s.Get(fmt.Sprintf(APIPrefix+"/summary?start=%d&end=%d&period=day&focus=true",
+		day.Unix(), day.Add(time.Hour).Unix()))
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	var actual api.SummaryResponse
+	tu.Check(t,
+		tu.Nil(json.NewDecoder(resp.Body).Decode(&actual)),
+		tu.Eq(len(actual.Buckets), 1),
+	)
+	// Intervals of 90, 10 and 20 minutes, with one switch (from "a" to "b")
+	tu.Check(t, tu.Eq(actual.Buckets[0].Focus, &api.Focus{
+		Switches:  1,
+		Longest:   90 * 60,
+		Median:    20 * 60,
+		DeepShare: 0.75,
+	}))
+
+	// Without 'focus', it's omitted
+	resp, err = s.Get(fmt.Sprintf(APIPrefix+"/summary?start=%d&end=%d&period=day",
+		day.Unix(), day.Add(time.Hour).Unix()))
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	actual = api.SummaryResponse{}
+	tu.Check(t,
+		tu.Nil(json.NewDecoder(resp.Body).Decode(&actual)),
+		tu.Eq(actual.Buckets[0].Focus == nil, true),
+	)
+}
+
+// TestHistogram checks that time worked can be binned by hour of the day and
+// by day of the week
+func TestHistogram(t *testing.T) {
+	s := StartTestServer(t, testDir)
+	sat := time.Date(2017, 7, 1, 9, 50, 0, 0, time.Local)
+	s.Set(sat)
+	s.TickAt("a", 0, 10, 10) // Saturday at 9:50, 10:00 and 10:10
+	s.Set(sat.AddDate(0, 0, 2).Add(4 * time.Hour))
+	s.TickAt("b", 10, 10) // Monday at 14:00 and 14:10
+
+	histogram := func(query string) *api.HistogramResponse {
+		t.Helper()
+		resp, err := s.Get(APIPrefix + "/stats/histogram?" + query)
+		tu.Check(t,
+			tu.Nil(err),
+			tu.Eq(resp.StatusCode, http.StatusOK),
+		)
+		var actual api.HistogramResponse
+		tu.Check(t, tu.Nil(json.NewDecoder(resp.Body).Decode(&actual)))
+		return &actual
+	}
+	// seconds returns the Seconds of each bin in 'h'
+	seconds := func(h *api.HistogramResponse) []int64 {
+		result := make([]int64, len(h.Bins))
+		for i, b := range h.Bins {
+			result[i] = b.Seconds
+		}
+		return result
+	}
+	hours := make([]int64, 24)
+	hours[9], hours[10], hours[14] = 600, 600, 600
+	h := histogram("by=hour")
+	tu.Check(t,
+		tu.Eq(h.Bins[9].Name, "09"),
+		tu.Eq(seconds(h), hours),
+		tu.Eq(h.Total, int64(1800)),
+	)
+	h = histogram("by=weekday")
+	tu.Check(t,
+		tu.Eq(h.Bins[0].Name, "Mon"),
+		tu.Eq(h.Bins[6].Name, "Sun"),
+		tu.Eq(seconds(h), []int64{600, 0, 0, 0, 0, 1200, 0}),
+	)
+
+	// Only the given label, or the given range, is counted
+	hours[14] = 0
+	tu.Check(t,
+		tu.Eq(seconds(histogram("label=a")), hours),
+		tu.Eq(seconds(histogram(fmt.Sprintf("by=weekday&from=%d", sat.AddDate(0, 0, 1).Unix()))),
+			[]int64{600, 0, 0, 0, 0, 0, 0}),
+		tu.Eq(seconds(histogram(fmt.Sprintf("by=weekday&to=%d", sat.AddDate(0, 0, 1).Unix()))),
+			[]int64{0, 0, 0, 0, 0, 1200, 0}),
+	)
+
+	// By default, the range ends now
+	s.Set(sat.AddDate(0, 0, 1))
+	tu.Check(t, tu.Eq(seconds(histogram("by=weekday")), []int64{0, 0, 0, 0, 0, 1200, 0}))
+
+	// Invalid bins are rejected
+	resp, err := s.Get(APIPrefix + "/stats/histogram?by=month")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusInternalServerError),
+	)
+}
+
+// TestBatchIntervals checks that the intervals in several ranges can be
+// requested at once
+func TestBatchIntervals(t *testing.T) {
+	s := StartTestServer(t, testDir)
+	ts := time.Date(
+		/* date */ 2017, 7, 1,
+		/* time */ 9, 0, 0,
+		/* nsec, location */ 0, time.UTC)
+	s.Set(ts)
+	s.TickAt("a", 0, 10)     // 9:00-9:10 on the first day
+	s.TickAt("b", 24*60, 20) // 9:10-9:30 on the second day
+	s.Add(time.Hour)
+	day := ts.Add(-9 * time.Hour)
+
+	batch := func(req string) api.BatchIntervalsResponse {
+		t.Helper()
+		resp, err := s.PostString(APIPrefix+"/intervals/batch", req)
+		tu.Check(t,
+			tu.Nil(err),
+			tu.Eq(resp.StatusCode, http.StatusOK),
+		)
+		var actual api.BatchIntervalsResponse
+		tu.Check(t, tu.Nil(json.NewDecoder(resp.Body).Decode(&actual)))
+		return actual
+	}
+	at := func(d time.Duration) int64 { return ts.Add(d).Unix() }
+	first := api.IntervalsBucket{
+		Start:     day.Unix(),
+		End:       day.Add(24 * time.Hour).Unix(),
+		Intervals: []api.Interval{{Start: at(0), End: at(10 * time.Minute), Label: "a"}},
+		Total:     600,
+	}
+	second := api.IntervalsBucket{
+		Start: day.Add(24 * time.Hour).Unix(),
+		End:   day.Add(48 * time.Hour).Unix(),
+		Intervals: []api.Interval{{
+			Start: at(24*time.Hour + 10*time.Minute),
+			End:   at(24*time.Hour + 30*time.Minute),
+			Label: "b",
+		}},
+		Total: 1200,
+	}
+	third := api.IntervalsBucket{
+		Start:     day.Add(48 * time.Hour).Unix(),
+		End:       day.Add(72 * time.Hour).Unix(),
+		Intervals: []api.Interval{},
+	}
+	tu.Check(t, tu.Eq(batch(fmt.Sprintf(`{"start":%d,"end":%d,"step":%d,"byLabel":true}`,
+		day.Unix(), day.Add(72*time.Hour).Unix(), 24*60*60)).Buckets,
+		[]api.IntervalsBucket{first, second, third}))
+
+	// Listed ranges are returned in the order requested
+	tu.Check(t, tu.Eq(batch(fmt.Sprintf(`{"ranges":[{"start":%d,"end":%d},{"start":%d,"end":%d}],"byLabel":true}`,
+		second.Start, second.End, first.Start, first.End)).Buckets,
+		[]api.IntervalsBucket{second, first}))
+
+	// Ranges that don't touch are read separately, with the same result
+	tu.Check(t, tu.Eq(batch(fmt.Sprintf(`{"ranges":[{"start":%d,"end":%d},{"start":%d,"end":%d}],"byLabel":true}`,
+		third.Start, third.End, first.Start, first.End)).Buckets,
+		[]api.IntervalsBucket{third, first}))
+
+	// Requests must give either ranges or a step
+	resp, err := s.PostString(APIPrefix+"/intervals/batch", fmt.Sprintf(`{"start":%d,"end":%d}`,
+		day.Unix(), day.Add(72*time.Hour).Unix()))
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusInternalServerError),
+	)
+}
+
+// TestListTicks checks that raw ticks can be listed a page at a time, or
+// streamed as NDJSON
+func TestListTicks(t *testing.T) {
+	s := StartTestServer(t, testDir)
+	ts := time.Date(
+		/* date */ 2017, 7, 1,
+		/* time */ 9, 0, 0,
+		/* nsec, location */ 0, time.UTC)
+	s.Set(ts)
+	s.TickAt("a", 0, 1, 1) // 9:00, 9:01 and 9:02
+	s.TickAt("b", 1, 1)    // 9:03 and 9:04
+	start, end := ts.Unix(), ts.Add(time.Hour).Unix()
+
+	// Page through the ticks, two at a time
+	var labels []string
+	cursor, pages := "", 0
+	for {
+		resp, err := s.Get(fmt.Sprintf(APIPrefix+"/ticks?start=%d&end=%d&limit=2&cursor=%s",
+			start, end, cursor))
+		tu.Check(t,
+			tu.Nil(err),
+			tu.Eq(resp.StatusCode, http.StatusOK),
+		)
+		var page api.ListTicksResponse
+		tu.Check(t, tu.Nil(json.NewDecoder(resp.Body).Decode(&page)))
+		for _, tick := range page.Ticks {
+			labels = append(labels, tick.Label)
+		}
+		pages++
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	tu.Check(t,
+		tu.Eq(pages, 3),
+		tu.Eq(labels, []string{"a", "a", "a", "b", "b"}),
+	)
+
+	// Stream the "b" ticks
+	resp, err := s.Get(fmt.Sprintf(APIPrefix+"/ticks?start=%d&end=%d&label=b&format=ndjson", start, end))
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+		tu.Eq(resp.Header.Get("Content-Type"), "application/x-ndjson"),
+	)
+	lines := strings.Split(strings.TrimSpace(ReadBody(t, resp)), "\n")
+	tu.Check(t, tu.Eq(len(lines), 2))
+	var tick api.StoredTick
+	tu.Check(t, tu.Nil(json.Unmarshal([]byte(lines[1]), &tick)))
+	tu.Check(t,
+		tu.Eq(tick.Label, "b"),
+		tu.Eq(tick.Time, ts.Add(4*time.Minute).Unix()),
+	)
+
+	// Invalid cursors are rejected
+	resp, err = s.Get(APIPrefix + "/ticks?cursor=bogus")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusInternalServerError),
+	)
+}
+
+// TestSameSecondTicks checks that several ticks may be sent at the same time
+func TestSameSecondTicks(t *testing.T) {
+	s := StartTestServer(t, testDir)
+	ts := time.Date(
+		/* date */ 2017, 7, 1,
+		/* time */ 9, 0, 0,
+		/* nsec, location */ 0, time.UTC)
+	s.Set(ts)
+	for _, label := range []string{"editor-a", "editor-b", "editor-b"} {
+		resp, err := s.PostString("/tick", fmt.Sprintf(`{"label":%q}`, label))
+		tu.Check(t,
+			tu.Nil(err),
+			tu.Eq(ReadBody(t, resp), ""),
+			tu.Eq(resp.StatusCode, http.StatusOK),
+		)
+	}
+	s.Add(500 * time.Millisecond)
+	s.TickAt("editor-a", 0)
+
+	resp, err := s.Get("/export")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	var ticks api.TickSet
+	tu.Check(t, tu.Nil(json.NewDecoder(resp.Body).Decode(&ticks)))
+	// The repeated "editor-b" tick is redundant and is dropped
+	tu.Check(t, tu.Eq(len(ticks.Ticks), 3))
+	tu.Check(t, tu.Eq(ticks.Ticks[2].Nanos, int64(500*time.Millisecond)))
+}
+
+// TestMigration checks that ticks in a DB created by the original version of
+// time-tracker survive schema migrations
+func TestMigration(t *testing.T) {
+	dbPath := path.Join(testDir, "TestMigration.db")
+	db, err := sql.Open("sqlite3", dbPath)
+	tu.Check(t, tu.Nil(err))
+	_, err = db.Exec(`
+	  CREATE TABLE ticks (time INTEGER PRIMARY KEY ASC, labels TEXT);
+	  INSERT INTO ticks VALUES (1498899600, "work");
+	  INSERT INTO ticks VALUES (1498900800, "play");
+	`)
+	tu.Check(t, tu.Nil(err))
+	tu.Check(t, tu.Nil(db.Close()))
+
+	s, err := api.NewServer(&api.TestingClock{}, dbPath)
+	tu.Check(t, tu.Nil(err))
+	defer s.Close()
+	ticks, err := s.Export(&api.ExportRequest{})
+	tu.Check(t, tu.Nil(err))
+	host, _ := os.Hostname()
+	tu.Check(t, tu.Eq(ticks.Ticks, []api.TickRecord{
+		{Time: 1498899600, Label: "work", Host: host},
+		{Time: 1498900800, Label: "play", Host: host},
+	}))
+}
+
+// TestBackupRestore checks that a backup made while the server is running can
+// be restored, and that invalid backups are rejected
+func TestBackupRestore(t *testing.T) {
+	s := StartTestServer(t, testDir)
+	s.Set(time.Date(
+		/* date */ 2017, 7, 1,
+		/* time */ 9, 0, 0,
+		/* nsec, location */ 0, time.UTC))
+	s.TickAt("work", 0, 10)
+
+	backupPath := path.Join(testDir, "TestBackupRestore.backup.db")
+	req := fmt.Sprintf(`{"path":%q}`, backupPath)
+	resp, err := s.PostString(APIPrefix+"/backups", req)
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	var backup api.BackupResponse
+	json.NewDecoder(resp.Body).Decode(&backup)
+	tu.Check(t,
+		tu.Eq(backup.Path, backupPath),
+		tu.Eq(backup.Bytes > 0, true),
+	)
+	// Backups never overwrite existing files
+	resp, err = s.PostString(APIPrefix+"/backups", req)
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusInternalServerError),
+	)
+
+	// Ticks added after the backup are gone once it's restored
+	s.TickAt("play", 10)
+	resp, err = s.PostString(APIPrefix+"/restore", req)
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	var restore api.RestoreResponse
+	json.NewDecoder(resp.Body).Decode(&restore)
+	tu.Check(t, tu.HasSuffix(restore.Previous, ".before-restore"))
+	resp, err = s.Get(APIPrefix + "/export")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	var ticks api.TickSet
+	json.NewDecoder(resp.Body).Decode(&ticks)
+	tu.Check(t, tu.Eq(len(ticks.Ticks), 2))
+	for _, tick := range ticks.Ticks {
+		tu.Check(t, tu.Eq(tick.Label, "work"))
+	}
+
+	// Files that aren't time-tracker DBs are rejected
+	notDB := path.Join(testDir, "TestBackupRestore.txt")
+	tu.Check(t, tu.Nil(ioutil.WriteFile(notDB, []byte("not a DB"), 0600)))
+	resp, err = s.PostString(APIPrefix+"/restore", fmt.Sprintf(`{"path":%q}`, notDB))
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusInternalServerError),
+		tu.HasPrefix(ReadBody(t, resp), "invalid backup"),
+	)
+}
+
+// TestScheduledBackups checks that scheduled backups are made once per day and
+// week, and that only the most recent ones are kept
+func TestScheduledBackups(t *testing.T) {
+	clock := &api.TestingClock{}
+	clock.Set(time.Date(
+		/* date */ 2017, 7, 1,
+		/* time */ 9, 0, 0,
+		/* nsec, location */ 0, time.UTC))
+	server, err := api.NewServer(clock, path.Join(testDir, "TestScheduledBackups.db"))
+	tu.Check(t, tu.Nil(err))
+	defer server.Close()
+	b := backupScheduler{
+		clock:  clock,
+		server: server,
+		dir:    path.Join(testDir, "TestScheduledBackups"),
+		daily:  2,
+		weekly: 1,
+	}
+	// Check twice per day, for ten days
+	for i := 0; i < 20; i++ {
+		tu.Check(t, tu.Nil(b.backupIfDue()))
+		clock.Add(12 * time.Hour)
+	}
+	files, err := ioutil.ReadDir(b.dir)
+	tu.Check(t, tu.Nil(err))
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	tu.Check(t, tu.Eq(names, []string{
+		"daily-2017-07-09.db",
+		"daily-2017-07-10.db",
+		"weekly-2017-W28.db",
+	}))
+}
+
+// TestScheduledJobs checks that the daemon runs scheduled jobs (backups and
+// trash purges) when its schedule ticks, and that runScheduled stops when told
+// to
+func TestScheduledJobs(t *testing.T) {
+	ticks := make(chan time.Time)
+	dir := path.Join(testDir, "TestScheduledJobs")
+	s := StartTestServerWithOptions(t, testDir, &Options{
+		DailyBackups:  1,
+		BackupDir:     dir,
+		scheduleTicks: ticks,
+	})
+	s.Set(time.Date(
+		/* date */ 2017, 7, 1,
+		/* time */ 9, 0, 0,
+		/* nsec, location */ 0, time.UTC))
+	// The schedule only receives a tick once its jobs have finished, so the
+	// second tick waits for the first tick's backup
+	ticks <- time.Time{}
+	ticks <- time.Time{}
+	_, err := os.Stat(path.Join(dir, "daily-2017-07-01.db"))
+	tu.Check(t, tu.Nil(err))
+
+	// Expired trash is purged without being read
+	s.TickAt("a", 0)
+	resp, err := s.PostString(APIPrefix+"/ticks/delete", fmt.Sprintf(`{"start":%d,"end":%d}`,
+		s.Now().Unix(), s.Now().Add(time.Minute).Unix()))
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	db, err := sql.Open("sqlite3", path.Join(testDir, "server.TestScheduledJobs.db"))
+	tu.Check(t, tu.Nil(err))
+	defer db.Close()
+	trashed := func() int {
+		t.Helper()
+		var n int
+		tu.Check(t, tu.Nil(db.QueryRow(`SELECT COUNT(*) FROM trash_batches`).Scan(&n)))
+		return n
+	}
+	tu.Check(t, tu.Eq(trashed(), 1))
+	s.Add(api.TrashRetention + time.Hour)
+	ticks <- time.Time{}
+	ticks <- time.Time{}
+	tu.Check(t, tu.Eq(trashed(), 0))
+
+	runs, stop, done := 0, make(chan struct{}), make(chan struct{})
+	ticks = make(chan time.Time)
+	go func() {
+		defer close(done)
+		runScheduled(ticks, stop, func() { runs++ })
+	}()
+	ticks <- time.Time{}
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("runScheduled didn't stop")
+	}
+	tu.Check(t, tu.Eq(runs, 2))
+}
+
+// TestTrash checks that deleted, relabelled and cleared ticks can be restored
+// from the trash, and that old trash is purged
+func TestTrash(t *testing.T) {
+	s := StartTestServer(t, testDir)
+	ts := time.Date(
+		/* date */ 2017, 7, 1,
+		/* time */ 9, 0, 0,
+		/* nsec, location */ 0, time.UTC)
+	s.Set(ts)
+	s.TickAt("a", 0, 10)
+	s.TickAt("b", 10, 10)
+	labels := func() []string {
+		t.Helper()
+		resp, err := s.Get(APIPrefix + "/export")
+		tu.Check(t,
+			tu.Nil(err),
+			tu.Eq(resp.StatusCode, http.StatusOK),
+		)
+		var ticks api.TickSet
+		json.NewDecoder(resp.Body).Decode(&ticks)
+		var result []string
+		for _, tick := range ticks.Ticks {
+			result = append(result, tick.Label)
+		}
+		return result
+	}
+	// post sends 'req' to 'endpoint', and returns the trash batch that it made
+	post := func(endpoint, req string) api.TrashBatch {
+		t.Helper()
+		resp, err := s.PostString(APIPrefix+endpoint, req)
+		tu.Check(t,
+			tu.Nil(err),
+			tu.Eq(resp.StatusCode, http.StatusOK),
+		)
+		var batch api.TrashBatch
+		json.NewDecoder(resp.Body).Decode(&batch)
+		return batch
+	}
+	undo := func(batch int64) api.UndoResponse {
+		t.Helper()
+		resp, err := s.PostString(APIPrefix+"/undo", fmt.Sprintf(`{"batch":%d}`, batch))
+		tu.Check(t,
+			tu.Nil(err),
+			tu.Eq(resp.StatusCode, http.StatusOK),
+		)
+		var result api.UndoResponse
+		tu.Check(t, tu.Nil(json.NewDecoder(resp.Body).Decode(&result)))
+		return result
+	}
+	tu.Check(t, tu.Eq(labels(), []string{"a", "a", "b", "b"}))
+
+	// Relabel the first three ticks, then undo it
+	relabel := post("/ticks/relabel", fmt.Sprintf(`{"start":%d,"end":%d,"to":"c"}`,
+		ts.Unix(), ts.Add(21*time.Minute).Unix()))
+	tu.Check(t,
+		tu.Eq(relabel.Ticks, 3),
+		tu.Eq(labels(), []string{"c", "c", "c", "b"}),
+	)
+	tu.Check(t, tu.Eq(undo(relabel.ID).Restored, 3))
+	tu.Check(t, tu.Eq(labels(), []string{"a", "a", "b", "b"}))
+
+	// Delete the "b" ticks, and clear the rest. Both are listed in the trash
+	del := post("/ticks/delete", fmt.Sprintf(`{"start":%d,"end":%d,"label":"b"}`,
+		ts.Unix(), ts.Add(time.Hour).Unix()))
+	tu.Check(t,
+		tu.Eq(del.Ticks, 2),
+		tu.Eq(labels(), []string{"a", "a"}),
+	)
+	resp, err := s.Delete(APIPrefix+"/ticks", strings.NewReader(`{"confirm":"yes"}`))
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	tu.Check(t, tu.Eq(labels(), []string(nil)))
+	resp, err = s.Get(APIPrefix + "/trash")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	var trash api.ListTrashResponse
+	json.NewDecoder(resp.Body).Decode(&trash)
+	tu.Check(t, tu.Eq(len(trash.Batches), 2))
+	tu.Check(t,
+		tu.Eq(trash.Batches[0].ID, del.ID),
+		tu.Eq(trash.Batches[1].Description, "clear"),
+		tu.Eq(trash.Batches[1].Ticks, 2),
+	)
+	tu.Check(t,
+		tu.Eq(undo(trash.Batches[1].ID).Restored, 2),
+		tu.Eq(undo(del.ID).Restored, 2),
+	)
+	tu.Check(t, tu.Eq(labels(), []string{"a", "a", "b", "b"}))
+
+	// Ticks that collide with a tick added since the batch was made are skipped
+	// and reported
+	del = post("/ticks/delete", fmt.Sprintf(`{"start":%d,"end":%d}`,
+		ts.Unix(), ts.Add(time.Minute).Unix()))
+	now := s.Now()
+	s.Set(ts)
+	s.TickAt("a", 0)
+	s.Set(now)
+	result := undo(del.ID)
+	tu.Check(t,
+		tu.Eq(result.Restored, 0),
+		tu.Eq(len(result.Skipped), 1),
+	)
+	tu.Check(t,
+		tu.Eq(result.Skipped[0].Time, ts.Unix()),
+		tu.Eq(result.Skipped[0].Label, "a"),
+	)
+	tu.Check(t, tu.Eq(labels(), []string{"a", "a", "b", "b"}))
+
+	// Undone batches are gone, and old batches are purged
+	resp, err = s.PostString(APIPrefix+"/undo", fmt.Sprintf(`{"batch":%d}`, del.ID))
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusInternalServerError),
+	)
+	post("/ticks/delete", fmt.Sprintf(`{"start":%d,"end":%d}`,
+		ts.Unix(), ts.Add(time.Minute).Unix()))
+	s.Add(api.TrashRetention + time.Hour)
+	resp, err = s.Get(APIPrefix + "/trash")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(ReadBody(t, resp), `{"Batches":[]}`),
+	)
+}
+
+// TestClose checks that closing an APIServer stops its goroutines
+func TestClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+	s, err := api.NewServer(&api.TestingClock{}, path.Join(testDir, "TestClose.db"))
+	tu.Check(t, tu.Nil(err))
+	tu.Check(t, tu.Nil(s.Close()))
+	// Goroutines may take a moment to exit after being stopped
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	tu.Check(t, tu.Eq(runtime.NumGoroutine() <= before, true))
+}
+
+// TestEvents checks that /events streams interval starts and ends as ticks
+// arrive and the clock advances
+func TestEvents(t *testing.T) {
+	s := StartTestServer(t, testDir)
+	ts := time.Date(
+		/* date */ 2017, 7, 1,
+		/* time */ 9, 0, 0,
+		/* nsec, location */ 0, time.Local)
+	s.Set(ts)
+
+	resp, err := s.Get("/events")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	defer resp.Body.Close()
+	events := make(chan api.Event)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if line := scanner.Text(); strings.HasPrefix(line, "data: ") {
+				var e api.Event
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e)
+				events <- e
+			}
+		}
+	}()
+	next := func() api.Event {
+		t.Helper()
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for event")
+		}
+		return api.Event{}
+	}
+
+	s.TickAt("work", 0, 10) // 9:00 and 9:10
+	interval := &api.Interval{Start: ts.Unix(), End: ts.Unix(), Label: "work"}
+	tu.Check(t, tu.Eq(next(), api.Event{Type: api.IntervalStarted, Time: ts.Unix(), Interval: interval}))
+	tu.Check(t, tu.Eq(next(), api.Event{Type: api.TickReceived, Time: ts.Unix(), Label: "work"}))
+	tu.Check(t, tu.Eq(next(), api.Event{Type: api.DayTotalChanged, Time: ts.Unix()}))
+	ts2 := ts.Add(10 * time.Minute)
+	tu.Check(t, tu.Eq(next(), api.Event{Type: api.TickReceived, Time: ts2.Unix(), Label: "work"}))
+	tu.Check(t, tu.Eq(next(), api.Event{Type: api.DayTotalChanged, Time: ts2.Unix(), DayTotal: 600}))
+
+	// Once the gap is exceeded, the interval ends
+	s.Add(30 * time.Minute)
+	interval = &api.Interval{Start: ts.Unix(), End: ts2.Unix(), Label: "work"}
+	tu.Check(t, tu.Eq(next(), api.Event{
+		Type:     api.IntervalEnded,
+		Time:     ts2.Add(30 * time.Minute).Unix(),
+		Interval: interval,
+	}))
+}
+
+// TestConcurrentTickEvents checks that ticks sent at the same time are
+// published in the order of their times
+func TestConcurrentTickEvents(t *testing.T) {
+	s := StartTestServer(t, testDir)
+	s.Set(time.Date(
+		/* date */ 2017, 7, 1,
+		/* time */ 9, 0, 0,
+		/* nsec, location */ 0, time.Local))
+
+	resp, err := s.Get("/events")
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(resp.StatusCode, http.StatusOK),
+	)
+	defer resp.Body.Close()
+	times := make(chan int64)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if line := scanner.Text(); strings.HasPrefix(line, "data: ") {
+				var e api.Event
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e)
+				if e.Type == api.TickReceived {
+					times <- e.Time
+				}
+			}
+		}
+	}()
+
+	const n = 20
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			s.Add(time.Second)
+			resp, err := s.PostString(APIPrefix+"/ticks", `{"label":"work"}`)
+			if err == nil && resp.StatusCode != http.StatusOK {
+				err = fmt.Errorf("status %d", resp.StatusCode)
+			}
+			errs <- err
+		}()
+	}
+	for i := 0; i < n; i++ {
+		tu.Check(t, tu.Nil(<-errs))
+	}
+	var last int64
+	for i := 0; i < n; i++ {
+		select {
+		case ts := <-times:
+			tu.Check(t, tu.Eq(ts >= last, true))
+			last = ts
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for event")
+		}
+	}
+}
+
+// TestSocketPermissions checks that only the server's owner can connect to the
+// unix socket
+func TestSocketPermissions(t *testing.T) {
+	StartTestServer(t, testDir)
+	info, err := os.Stat(path.Join(testDir, "server.TestSocketPermissions.sock"))
+	tu.Check(t, tu.Nil(err))
+	tu.Check(t, tu.Eq(info.Mode().Perm(), os.FileMode(0600)))
+}
+
+// TestGRPC checks that the API served over gRPC matches the HTTP API, and
+// that bad requests get INVALID_ARGUMENT
+func TestGRPC(t *testing.T) {
+	socketPath := path.Join(testDir, "TestGRPC.grpc.sock")
+	s := StartTestServerWithOptions(t, testDir, &Options{GRPCSocket: socketPath})
+	ts := time.Date(
+		/* date */ 2017, 7, 1,
+		/* time */ 9, 0, 0,
+		/* nsec, location */ 0, time.Local)
+	s.Set(ts)
+
+	conn, err := grpc.NewClient("unix://"+socketPath,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.WaitForReady(true)))
+	tu.Check(t, tu.Nil(err))
+	defer conn.Close()
+	c := pb.NewTimeTrackerClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Subscribe before ticking, and wait until the server has subscribed
+	stream, err := c.Events(ctx, &pb.EventsRequest{})
+	tu.Check(t, tu.Nil(err))
+	_, err = stream.Header()
+	tu.Check(t, tu.Nil(err))
+
+	// Tick at 9:00 and 9:10
+	_, err = c.Tick(ctx, &pb.TickRequest{Label: "work", Source: "test"})
+	tu.Check(t, tu.Nil(err))
+	s.Add(10 * time.Minute)
+	_, err = c.Tick(ctx, &pb.TickRequest{Label: "work", Zone: "UTC"})
+	tu.Check(t, tu.Nil(err))
+
+	e, err := stream.Recv()
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(e.Type, string(api.IntervalStarted)),
+		tu.Eq(e.Interval.Start, ts.Unix()),
+	)
+	e, err = stream.Recv()
+	tu.Check(t,
+		tu.Nil(err),
+		tu.Eq(e.Type, string(api.TickReceived)),
+		tu.Eq(e.Label, "work"),
+	)
+
+	intervals, err := c.GetIntervals(ctx, &pb.GetIntervalsRequest{
+		Start: ts.Unix(),
+		End:   ts.Add(time.Hour).Unix(),
+	})
+	tu.Check(t, tu.Nil(err))
+	tu.Check(t, tu.Eq(len(intervals.Intervals), 1))
+	tu.Check(t,
+		tu.Eq(intervals.Intervals[0].Start, ts.Unix()),
+		tu.Eq(intervals.Intervals[0].End, ts.Add(10*time.Minute).Unix()),
+	)
+
+	summary, err := c.Summary(ctx, &pb.SummaryRequest{
+		Start: ts.Unix(),
+		End:   ts.Add(time.Hour).Unix(),
+		Focus: true,
+	})
+	tu.Check(t, tu.Nil(err))
+	tu.Check(t, tu.Eq(len(summary.Buckets), 1))
+	tu.Check(t,
+		tu.Eq(summary.Buckets[0].Total, int64(600)),
+		tu.Eq(summary.Buckets[0].Seconds["work"], int64(600)),
+		tu.Eq(summary.Buckets[0].Focus != nil, true),
+	)
+
+	// Bad requests are rejected with INVALID_ARGUMENT
+	_, err = c.Tick(ctx, &pb.TickRequest{})
+	tu.Check(t, tu.Eq(status.Code(err), codes.InvalidArgument))
+	_, err = c.Tick(ctx, &pb.TickRequest{Label: "work", Zone: "Mars/Base"})
+	tu.Check(t, tu.Eq(status.Code(err), codes.InvalidArgument))
+	_, err = c.Summary(ctx, &pb.SummaryRequest{Start: 1, End: 2, Period: "year"})
+	tu.Check(t, tu.Eq(status.Code(err), codes.InvalidArgument))
+	_, err = c.GetIntervals(ctx, &pb.GetIntervalsRequest{Start: 2, End: 1})
+	tu.Check(t, tu.Eq(status.Code(err), codes.InvalidArgument))
+}
+
 func TestMain(m *testing.M) {
 	// create temporary directory for housing test data
 	var err error
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/golang/glog"
+	"github.com/msteffen/golang-time-tracker/api"
+)
+
+// deleteTicks moves a range of ticks to the trash
+func (s httpAPIServer) deleteTicks(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /ticks/delete")
+	// Unmarshal and validate request
+	var req api.DeleteTicksRequest
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		msg := fmt.Sprintf("request did not match expected type: %v", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	// Process request
+	server := s.userServer(w, r)
+	if server == nil {
+		return
+	}
+	result, err := server.DeleteTicks(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, "could not serialize result: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(resultJSON)
+}
+
+// relabel changes the label of a range of ticks
+func (s httpAPIServer) relabel(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /ticks/relabel")
+	// Unmarshal and validate request
+	var req api.RelabelRequest
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		msg := fmt.Sprintf("request did not match expected type: %v", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	// Process request
+	server := s.userServer(w, r)
+	if server == nil {
+		return
+	}
+	result, err := server.Relabel(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, "could not serialize result: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(resultJSON)
+}
+
+// purgeTrash purges expired trash from the owner's DB and from every user DB
+// that's open. It's a scheduled job (see runScheduled), so errors are logged
+// rather than returned. User DBs that aren't open are purged when they're
+// next opened and their trash is read or written
+func (s httpAPIServer) purgeTrash() {
+	servers := []api.APIServer{s.APIServer}
+	if s.users != nil {
+		servers = append(servers, s.users.open()...)
+	}
+	for _, server := range servers {
+		if err := server.PurgeTrash(); err != nil {
+			glog.Errorf("could not purge trash: %v", err)
+		}
+	}
+}
+
+// listTrash returns the batches of ticks in the trash
+func (s httpAPIServer) listTrash(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /trash")
+	// Process request
+	server := s.userServer(w, r)
+	if server == nil {
+		return
+	}
+	result, err := server.ListTrash()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, "could not serialize result: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(resultJSON)
+}
+
+// undo reverts the operation that created a batch of ticks in the trash
+func (s httpAPIServer) undo(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("handling /undo")
+	// Unmarshal and validate request
+	var req api.UndoRequest
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		msg := fmt.Sprintf("request did not match expected type: %v", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	// Process request
+	server := s.userServer(w, r)
+	if server == nil {
+		return
+	}
+	result, err := server.Undo(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, "could not serialize result: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(resultJSON)
+}
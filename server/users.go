@@ -0,0 +1,201 @@
+// users.go implements multi-user mode, in which one time-tracker daemon serves
+// a whole team. Each request is attributed to a user--by the uid of the peer
+// process for requests over the unix socket, or by the bearer token for
+// requests over TCP--and is served by an APIServer backed by that user's own
+// DB, at <data dir>/users/<name>/db. The daemon's owner keeps using the
+// daemon's main DB, and is the only user who may call admin endpoints.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/msteffen/golang-time-tracker/api"
+)
+
+type ctxKey int
+
+const (
+	// peerUIDKey holds the uid of the process that sent a request over the
+	// unix socket (set by connContext)
+	peerUIDKey ctxKey = iota
+	// tokenUserKey holds the user authenticated by a request's bearer token
+	// (set by tokenAuthHandler)
+	tokenUserKey
+)
+
+// peerConn is a unix socket connection accepted by peerCredListener,
+// annotated with the uid of the process on the other end
+type peerConn struct {
+	net.Conn
+	uid uint32
+}
+
+// connContext is the http.Server ConnContext hook for the unix socket. It
+// makes the peer's uid (if known) available to handlers
+func connContext(ctx context.Context, c net.Conn) context.Context {
+	if pc, ok := c.(*peerConn); ok {
+		return context.WithValue(ctx, peerUIDKey, pc.uid)
+	}
+	return ctx
+}
+
+// userNameRe matches the user names that may be used as directory names
+var userNameRe = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]*$`)
+
+// userServers opens (and caches) an APIServer for each user in multi-user mode
+type userServers struct {
+	//// Not owned
+	clock api.Clock
+	owner api.APIServer // the daemon's main APIServer (used for the owner)
+
+	//// Owned
+	dataDir   string // user DBs are at dataDir/users/<name>/db
+	ownerName string
+	ownerUID  uint32
+	mu        sync.Mutex
+	servers   map[string]api.APIServer
+}
+
+func newUserServers(clock api.Clock, owner api.APIServer, dataDir string) (*userServers, error) {
+	me, err := user.Current()
+	if err != nil {
+		return nil, fmt.Errorf("could not look up the current user: %v", err)
+	}
+	return &userServers{
+		clock:     clock,
+		owner:     owner,
+		dataDir:   dataDir,
+		ownerName: me.Username,
+		ownerUID:  uint32(os.Getuid()),
+		servers:   make(map[string]api.APIServer),
+	}, nil
+}
+
+// get returns the APIServer holding 'name's data ("" is the owner), creating
+// the user's DB if necessary
+func (u *userServers) get(name string) (api.APIServer, error) {
+	if name == "" || name == u.ownerName {
+		return u.owner, nil
+	}
+	if !userNameRe.MatchString(name) {
+		return nil, fmt.Errorf("invalid user name %q", name)
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if s, ok := u.servers[name]; ok {
+		return s, nil
+	}
+	dir := path.Join(u.dataDir, "users", name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("could not create data dir for %q: %v", name, err)
+	}
+	s, err := api.NewServer(u.clock, path.Join(dir, "db"))
+	if err != nil {
+		return nil, fmt.Errorf("could not open DB for %q: %v", name, err)
+	}
+	u.servers[name] = s
+	return s, nil
+}
+
+// open returns the APIServers of the users whose DBs are open (not including
+// the owner's)
+func (u *userServers) open() []api.APIServer {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	servers := make([]api.APIServer, 0, len(u.servers))
+	for _, s := range u.servers {
+		servers = append(servers, s)
+	}
+	return servers
+}
+
+// close closes every user's APIServer (but not the owner's)
+func (u *userServers) close() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for name, s := range u.servers {
+		s.Close()
+		delete(u.servers, name)
+	}
+}
+
+// requestUser returns the name of the user who sent 'r' ("" for the owner)
+func (u *userServers) requestUser(r *http.Request) (string, error) {
+	return u.contextUser(r.Context())
+}
+
+// contextUser returns the name of the user whose request carries 'ctx' ("" for
+// the owner), per the peer uid or token user that it holds
+func (u *userServers) contextUser(ctx context.Context) (string, error) {
+	if name, ok := ctx.Value(tokenUserKey).(string); ok {
+		if name == u.ownerName {
+			return "", nil
+		}
+		return name, nil
+	}
+	uid, ok := ctx.Value(peerUIDKey).(uint32)
+	if !ok {
+		return "", fmt.Errorf("could not identify the user who sent the request")
+	}
+	if uid == u.ownerUID {
+		return "", nil
+	}
+	peer, err := user.LookupId(strconv.FormatUint(uint64(uid), 10))
+	if err != nil {
+		return "", fmt.Errorf("could not look up uid %d: %v", uid, err)
+	}
+	if peer.Username == u.ownerName {
+		return "", nil
+	}
+	return peer.Username, nil
+}
+
+// list returns every user with a DB (including the owner), and the size of
+// each user's DB
+func (u *userServers) list() ([]api.UserInfo, error) {
+	names := []string{u.ownerName}
+	entries, err := ioutil.ReadDir(path.Join(u.dataDir, "users"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() && userNameRe.MatchString(e.Name()) && e.Name() != u.ownerName {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names[1:])
+	result := make([]api.UserInfo, 0, len(names))
+	for i, name := range names {
+		// Other users' DBs are measured on disk, rather than by opening a
+		// server for each
+		var size int64
+		if i == 0 {
+			var err error
+			if size, err = u.owner.DBSize(); err != nil {
+				return nil, fmt.Errorf("could not get DB size for %q: %v", name, err)
+			}
+		} else if info, err := os.Stat(path.Join(u.dataDir, "users", name, "db")); err == nil {
+			size = info.Size()
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("could not get DB size for %q: %v", name, err)
+		}
+		result = append(result, api.UserInfo{
+			Name:    name,
+			Owner:   name == u.ownerName,
+			DBBytes: size,
+		})
+	}
+	return result, nil
+}
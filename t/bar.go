@@ -18,6 +18,7 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/msteffen/golang-time-tracker/api"
@@ -141,6 +142,59 @@ func eighths(duration int64) rune {
 	return rune((duration + 90) / 180)
 }
 
+// defaultSGR is the SGR code that sets the colour of intervals whose label
+// isn't in the Palette passed to ColorBar (33 = set fg to yellow)
+const defaultSGR = "33"
+
+// Palette maps labels to the SGR codes that set the foreground colour of their
+// intervals (e.g. "38;5;214")
+type Palette map[string]string
+
+// colorMode is the kind of colour codes that a terminal understands
+type colorMode int
+
+const (
+	color256  colorMode = iota // 38;5;<n>, with n from the 6x6x6 colour cube
+	colorTrue                  // 38;2;<r>;<g>;<b>
+)
+
+// termColorMode guesses whether the terminal supports truecolor, per the
+// (informal) $COLORTERM convention
+func termColorMode() colorMode {
+	switch os.Getenv("COLORTERM") {
+	case "truecolor", "24bit":
+		return colorTrue
+	}
+	return color256
+}
+
+// sgrColor returns the SGR code that sets the foreground colour to 'color'
+// ("#rrggbb"), or defaultSGR if 'color' is invalid
+func sgrColor(color string, mode colorMode) string {
+	r, g, b, err := api.ParseColor(color)
+	if err != nil {
+		return defaultSGR
+	}
+	if mode == colorTrue {
+		return fmt.Sprintf("38;2;%d;%d;%d", r, g, b)
+	}
+	// Round each component to one of the cube's six levels
+	cube := func(c uint8) int { return (int(c)*5 + 127) / 255 }
+	return fmt.Sprintf("38;5;%d", 16+36*cube(r)+6*cube(g)+cube(b))
+}
+
+// NewPalette returns a Palette with the colours in 'colors' of every label in
+// 'intervals'. Unlabelled intervals keep the default colour
+func NewPalette(intervals []api.Interval, colors api.LabelColors, mode colorMode) Palette {
+	p := make(Palette)
+	for _, i := range intervals {
+		if i.Label != "" {
+			p[i.Label] = sgrColor(colors.Color(i.Label), mode)
+		}
+	}
+	return p
+}
+
 type barOp struct {
 	// buf contains result of computing the day's bar
 	buf bytes.Buffer
@@ -150,11 +204,16 @@ type barOp struct {
 
 	// whether the buffer has set the terminal to be inverted
 	inverted bool
+
+	// the SGR colour code for the next character, and the code that was last
+	// written to buf
+	color, written string
 }
 
 func newBarOp() *barOp {
 	op := &barOp{
 		empty: true,
+		color: defaultSGR,
 	}
 	op.buf.WriteByte('[')
 	return op
@@ -163,11 +222,12 @@ func newBarOp() *barOp {
 // writeInverted is a helper function that writes 'r' to b.buf with inverted
 // colors (i.e. if colors are already inverted, it just writes 'r')
 func (b *barOp) writeInverted(r rune) {
-	if b.empty || !b.inverted {
-		// SGR code -- 7 = inverted, 33 = set fg to yellow
-		b.buf.Write([]byte{0x1b, '[', '7', ';', '3', '3', 'm'})
+	if b.empty || !b.inverted || b.written != b.color {
+		// SGR code -- 7 = inverted, then set fg colour (e.g. 33 = yellow)
+		b.buf.WriteString("\x1b[7;" + b.color + "m")
 		b.empty = false
 		b.inverted = true
+		b.written = b.color
 	}
 	b.buf.WriteRune(r)
 }
@@ -175,15 +235,16 @@ func (b *barOp) writeInverted(r rune) {
 // writeInverted is a helper function that writes 'r' to b.buf with non-inverted
 // colors (if colors are already normal, it just writes 'r')
 func (b *barOp) writeNormal(r rune) {
-	if b.empty {
-		// set fg to yellow (colors are already normal)
-		b.buf.Write([]byte{0x1b, '[', '3', '3', 'm'})
+	if b.empty || (!b.inverted && b.written != b.color) {
+		// set fg colour (colors are already normal)
+		b.buf.WriteString("\x1b[" + b.color + "m")
 		b.empty = false
 	} else if b.inverted {
-		// SGR code -- 0 = normal, 33 = set fg to yellow
-		b.buf.Write([]byte{0x1b, '[', '0', ';', '3', '3', 'm'})
+		// SGR code -- 0 = normal, then set fg colour
+		b.buf.WriteString("\x1b[0;" + b.color + "m")
 		b.inverted = false
 	}
+	b.written = b.color
 	b.buf.WriteRune(r)
 }
 
@@ -235,6 +296,12 @@ var emptyBar = func() string {
 
 // Bar generates a bar containing a day's worth of intervals (for raw 't' cmd)
 func Bar(morning time.Time, intervals []api.Interval) (res string) {
+	return ColorBar(morning, intervals, nil)
+}
+
+// ColorBar is like Bar, but draws each character in the colour (from 'palette')
+// of the label that covers most of it
+func ColorBar(morning time.Time, intervals []api.Interval, palette Palette) (res string) {
 	if len(intervals) == 0 {
 		return emptyBar // special case; no intervals
 	}
@@ -257,8 +324,10 @@ func Bar(morning time.Time, intervals []api.Interval) (res string) {
 
 		// The current "character" (24-minute window)
 		window byte
+
+		// The amount of the current character covered by each label
+		labelDuration = make(map[string]time.Duration)
 	)
-	fmt.Printf("I: [%d,%d]\n", int(il.Sub(morning).Minutes()), int(ir.Sub(morning).Minutes()))
 	for i := 0; i < (60 * 8); i++ {
 		cl = cr
 		cr = cl.Add(3 * time.Minute)
@@ -274,7 +343,9 @@ func Bar(morning time.Time, intervals []api.Interval) (res string) {
 			}
 			if Leq(cl, ir) {
 				// il <= cr and cl <= ir, there is overlap
-				duration += MinT(cr, ir).Sub(MaxT(cl, il))
+				overlap := MinT(cr, ir).Sub(MaxT(cl, il))
+				duration += overlap
+				labelDuration[intervals[n].Label] += overlap
 			}
 			if Leq(cr, ir) {
 				break // intervals[n] overlaps next bit as well
@@ -284,7 +355,6 @@ func Bar(morning time.Time, intervals []api.Interval) (res string) {
 			if n < len(intervals) {
 				il, ir = time.Unix(intervals[n].Start, 0), time.Unix(intervals[n].End, 0)
 			}
-			fmt.Printf("I: [%d,%d]\n", int(il.Sub(morning).Minutes()), int(ir.Sub(morning).Minutes()))
 		}
 		if duration > 90*time.Second {
 			// fmt.Printf("window (%s) |= (1 << (7-(%d%%8))\n", bin(window), i)
@@ -296,6 +366,10 @@ func Bar(morning time.Time, intervals []api.Interval) (res string) {
 		if i%8 == 7 {
 			// Window is filled out -- append to bar
 			// fmt.Printf("window: %s\n", bin(window))
+			if window != 0 {
+				op.color = palette.sgr(labelDuration)
+			}
+			labelDuration = make(map[string]time.Duration)
 			if window == 0 || window == 0xff {
 				op.put(int(window >> 7)) // hack -- works for put(0) and put(1)
 			} else {
@@ -320,6 +394,43 @@ func Bar(morning time.Time, intervals []api.Interval) (res string) {
 	return op.finish()
 }
 
+// HistogramBar draws a bar of 'width' characters, filled from the left in
+// proportion to 'seconds' out of 'max' (to the nearest eighth of a character)
+// with the same boxes as Bar. The rest of the bar is blank
+func HistogramBar(seconds, max int64, width int) string {
+	var n int64 // the number of eighths of a character to fill
+	if max > 0 {
+		n = (seconds*int64(width)*8 + max/2) / max
+	}
+	buf := bytes.Buffer{}
+	for i := int64(0); i < n/8; i++ {
+		buf.WriteRune(fullBlock)
+	}
+	if rem := rune(n % 8); rem > 0 {
+		// [fullBlock+1, fullBlock+7] are the left boxes, from 7/8 to 1/8
+		buf.WriteRune(fullBlock + 8 - rem)
+	}
+	for i := (n + 7) / 8; i < int64(width); i++ {
+		buf.WriteByte(' ')
+	}
+	return buf.String()
+}
+
+// sgr returns the SGR colour code of the label with the largest duration in
+// 'durations' (ties go to the label that sorts first)
+func (p Palette) sgr(durations map[string]time.Duration) string {
+	best, bestDuration := "", time.Duration(-1)
+	for label, d := range durations {
+		if d > bestDuration || (d == bestDuration && label < best) {
+			best, bestDuration = label, d
+		}
+	}
+	if code, ok := p[best]; ok {
+		return code
+	}
+	return defaultSGR
+}
+
 func bin(x byte) string {
 	var result [8]byte
 	for i := 0; i < 8; i++ {
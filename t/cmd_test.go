@@ -70,3 +70,62 @@ func TestBarIntervalFitsInChar(t *testing.T) {
 		tu.HasSuffix(barStr, "████████████████████████████████████████████████████████\x1b[m]"),
 	)
 }
+
+func TestColorBar(t *testing.T) {
+	barStr := ColorBar(ts, []api.Interval{
+		{
+			Start: ts.Unix(),
+			End:   ts.Add(48 * time.Minute).Unix(),
+			Label: "a",
+		},
+		{
+			Start: ts.Add(48 * time.Minute).Unix(),
+			End:   ts.Add(96 * time.Minute).Unix(),
+			Label: "b",
+		},
+	}, Palette{"a": "31", "b": "32"})
+	tu.Check(t,
+		tu.HasPrefix(barStr, "[\x1b[31m██\x1b[32m██\x1b[7;32m███"),
+		tu.HasSuffix(barStr, "████████████████████████████████████████████████████████\x1b[m]"),
+	)
+}
+
+func TestSGRColor(t *testing.T) {
+	tu.Check(t,
+		tu.Eq(sgrColor("#ff0000", colorTrue), "38;2;255;0;0"),
+		tu.Eq(sgrColor("#ff0000", color256), "38;5;196"),
+		tu.Eq(sgrColor("#ffb915", color256), "38;5;220"),
+		tu.Eq(sgrColor("yellow", color256), defaultSGR),
+	)
+}
+
+// BenchmarkColorBar measures drawing a busy day: eight hours of work in which
+// the label changes every five minutes
+func TestHistogramBar(t *testing.T) {
+	tu.Check(t,
+		tu.Eq(HistogramBar(0, 0, 4), "    "),
+		tu.Eq(HistogramBar(100, 100, 4), "████"),
+		tu.Eq(HistogramBar(50, 100, 4), "██  "),
+		// 3/8 of a character past 2
+		tu.Eq(HistogramBar(59, 100, 4), "██▍ "),
+		tu.Eq(HistogramBar(1, 100, 4), "    "),
+	)
+}
+
+func BenchmarkColorBar(b *testing.B) {
+	labels := []string{"a", "b", "c"}
+	var intervals []api.Interval
+	for start := ts; start.Before(ts.Add(8 * time.Hour)); start = start.Add(5 * time.Minute) {
+		intervals = append(intervals, api.Interval{
+			Start: start.Unix(),
+			End:   start.Add(5 * time.Minute).Unix(),
+			Label: labels[len(intervals)%len(labels)],
+		})
+	}
+	palette := Palette{"a": "31", "b": "32", "c": "33"}
+	morning := ts.Add(-9 * time.Hour)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ColorBar(morning, intervals, palette)
+	}
+}
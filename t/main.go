@@ -1,12 +1,19 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -20,38 +27,88 @@ var (
 	/* const */ dataDir = os.Getenv("HOME") + "/.time-tracker"
 	/* const */ dbFile = dataDir + "/db"
 	/* const */ socketFile = dataDir + "/sock"
+	/* const */ backupDir = dataDir + "/backups"
 )
 
-func Today() error {
+// Today prints a bar for each of the next seven days. If 'inRecordedZone' is
+// true, intervals are drawn at the wall-clock time at which they were recorded
+// rather than in the local time zone
+func Today(inRecordedZone bool) error {
 	now := time.Now()
-	morning, night := time.Time{}, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
 
-	// Make sure to set local time (silly--see https://github.com/msteffen/golang-time-tracker/issues/2)
+	colors, err := getLabelColors()
+	if err != nil {
+		return err
+	}
+	mode := termColorMode()
+
+	// Days aren't always 24 hours long (e.g. across a DST change), so day
+	// boundaries are computed with AddDate
+	req := api.BatchIntervalsRequest{ByLabel: true}
 	for day := 0; day < 7; day++ {
-		morning = night
-		night = morning.Add(24 * time.Hour)
-		c := cu.GetClient(socketFile)
-		httpResp, err := c.Get(fmt.Sprintf("/intervals?start=%d&end=%d", morning.Unix(), night.Unix()))
-		if err != nil {
-			return fmt.Errorf("could not retrieve today's intervals: %v", err)
-		}
-		var resp api.GetIntervalsResponse
-		if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
-			return fmt.Errorf("could not decode response: %v", err)
+		start, end := today.AddDate(0, 0, day), today.AddDate(0, 0, day+1)
+		if inRecordedZone {
+			// Intervals outside of this day may be shifted into it
+			start, end = start.Add(-api.MaxZoneShift), end.Add(api.MaxZoneShift)
 		}
-		workDuration := time.Duration(0)
-		for _, i := range resp.Intervals {
-			workDuration += time.Duration(i.End-i.Start) * time.Second
+		req.Ranges = append(req.Ranges, api.TimeRange{Start: start.Unix(), End: end.Unix()})
+	}
+	buf := &bytes.Buffer{}
+	json.NewEncoder(buf).Encode(req)
+	httpResp, err := cu.GetClient(socketFile).Post(server.APIPrefix+"/intervals/batch", buf)
+	if err != nil {
+		return fmt.Errorf("could not retrieve today's intervals: %v", err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		buf.Reset()
+		io.Copy(buf, httpResp.Body)
+		return fmt.Errorf("could not retrieve today's intervals: %s", buf.String())
+	}
+	var resp api.BatchIntervalsResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return fmt.Errorf("could not decode response: %v", err)
+	}
+	for day, bucket := range resp.Buckets {
+		morning := today.AddDate(0, 0, day)
+		intervals, workDuration := bucket.Intervals, time.Duration(bucket.Total)*time.Second
+		if inRecordedZone {
+			night := today.AddDate(0, 0, day+1)
+			intervals = api.ClampIntervals(
+				api.InRecordedZone(intervals, time.Local), morning.Unix(), night.Unix())
+			workDuration = 0
+			for _, i := range intervals {
+				workDuration += time.Duration(i.End-i.Start) * time.Second
+			}
 		}
 		// block chars = u2588 (full) - u258f (left eighth)
 		fmt.Printf("%s: %s \x1b[1;33m%s\x1b[m\n",
 			morning.Format("2006/02/01 "),
-			Bar(morning, resp.Intervals),
+			ColorBar(morning, intervals, NewPalette(intervals, colors, mode)),
 			workDuration.String())
 	}
 	return nil
 }
 
+// getLabelColors retrieves the colour of each label from the server
+func getLabelColors() (api.LabelColors, error) {
+	c := cu.GetClient(socketFile)
+	httpResp, err := c.Get(server.APIPrefix + "/labels")
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve labels: %v", err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		buf := &bytes.Buffer{}
+		io.Copy(buf, httpResp.Body)
+		return nil, fmt.Errorf("could not retrieve labels: %s", buf.String())
+	}
+	var resp api.GetLabelsResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("could not decode response: %v", err)
+	}
+	return api.NewLabelColors(resp.Labels), nil
+}
+
 // func watchCmd() *cobra.Command {
 // 	return &cobra.Command{
 // 		Use:   "watch <directory>",
@@ -69,7 +126,13 @@ func tickCmd() *cobra.Command {
 		Long:  "Append a tick (work event) with the given label",
 		Run: BoundedCommand(1, 1, func(args []string) error {
 			c := cu.GetClient(socketFile)
-			resp, err := c.PostString("/tick", fmt.Sprintf("{ label: \"%s\" }", args[0]))
+			buf := &bytes.Buffer{}
+			json.NewEncoder(buf).Encode(api.TickRequest{
+				Label:  args[0],
+				Zone:   api.LocalZone(time.Now()),
+				Source: "t",
+			})
+			resp, err := c.Post(server.APIPrefix+"/ticks", buf)
 			if err != nil {
 				buf := &bytes.Buffer{}
 				io.Copy(buf, resp.Body)
@@ -80,8 +143,842 @@ func tickCmd() *cobra.Command {
 	}
 }
 
-func serveCmd() *cobra.Command {
+func tokenCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "token",
+		Short: "Manage the bearer tokens accepted by 't serve --listen'",
+		Long:  "Manage the bearer tokens accepted by 't serve --listen'",
+	}
+	var user string
+	createCmd := &cobra.Command{
+		Use:   "create [name]",
+		Short: "Create a new bearer token and print it",
+		Long: "Create a new bearer token and print it. Only a hash of the token " +
+			"is stored, so this is the only time it's printed",
+		Run: BoundedCommand(0, 1, func(args []string) error {
+			req := api.CreateTokenRequest{User: user}
+			if len(args) > 0 {
+				req.Name = args[0]
+			}
+			buf := &bytes.Buffer{}
+			json.NewEncoder(buf).Encode(req)
+			c := cu.GetClient(socketFile)
+			httpResp, err := c.Post(server.APIPrefix+"/tokens", buf)
+			if err != nil {
+				return fmt.Errorf("could not create token: %v", err)
+			}
+			if httpResp.StatusCode != http.StatusOK {
+				buf.Reset()
+				io.Copy(buf, httpResp.Body)
+				return fmt.Errorf("could not create token: %s", buf.String())
+			}
+			var resp api.CreateTokenResponse
+			if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+				return fmt.Errorf("could not decode response: %v", err)
+			}
+			fmt.Println(resp.Token)
+			return nil
+		}),
+	}
+	createCmd.Flags().StringVar(&user, "user", "", "In multi-user mode, the "+
+		"user whose data the token grants access to (only the server's owner may "+
+		"create tokens for other users)")
+	cmd.AddCommand(createCmd)
+	return cmd
+}
+
+func followCmd() *cobra.Command {
 	return &cobra.Command{
+		Use:   "follow",
+		Short: "Print work events (ticks, interval starts/ends) as they happen",
+		Long:  "Print work events (ticks, interval starts/ends) as they happen",
+		Run: BoundedCommand(0, 0, func(_ []string) error {
+			c := cu.GetClient(socketFile)
+			httpResp, err := c.Get(server.APIPrefix + "/events")
+			if err != nil {
+				return fmt.Errorf("could not follow events: %v", err)
+			}
+			defer httpResp.Body.Close()
+			if httpResp.StatusCode != http.StatusOK {
+				buf := &bytes.Buffer{}
+				io.Copy(buf, httpResp.Body)
+				return fmt.Errorf("could not follow events: %s", buf.String())
+			}
+			// Events are separated by blank lines; only 'data' lines are needed,
+			// as the event type is also in the JSON
+			scanner := bufio.NewScanner(httpResp.Body)
+			for scanner.Scan() {
+				line := scanner.Text()
+				if !strings.HasPrefix(line, "data: ") {
+					continue
+				}
+				var e api.Event
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e); err != nil {
+					return fmt.Errorf("could not decode event: %v", err)
+				}
+				fmt.Println(formatEvent(e))
+			}
+			if err := scanner.Err(); err != nil {
+				return fmt.Errorf("lost connection to server: %v", err)
+			}
+			return nil
+		}),
+	}
+}
+
+// formatEvent renders 'e' as a line of terminal output for 't follow'
+func formatEvent(e api.Event) string {
+	ts := time.Unix(e.Time, 0).Format("15:04:05")
+	switch e.Type {
+	case api.TickReceived:
+		return fmt.Sprintf("%s tick (%s)", ts, e.Label)
+	case api.IntervalStarted:
+		return fmt.Sprintf("%s \x1b[1;33mstarted working\x1b[m", ts)
+	case api.IntervalEnded:
+		d := time.Duration(e.Interval.End-e.Interval.Start) * time.Second
+		return fmt.Sprintf("%s \x1b[1mstopped working\x1b[m after %s (last tick at %s)",
+			ts, d, time.Unix(e.Interval.End, 0).Format("15:04:05"))
+	case api.DayTotalChanged:
+		return fmt.Sprintf("%s today: \x1b[1;33m%s\x1b[m", ts, time.Duration(e.DayTotal)*time.Second)
+	}
+	return fmt.Sprintf("%s %s", ts, e.Type)
+}
+
+func exportCmd() *cobra.Command {
+	var host string
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Print all ticks as JSON, for merging into another DB with 't sync'",
+		Long:  "Print all ticks as JSON, for merging into another DB with 't sync'",
+		Run: BoundedCommand(0, 0, func(_ []string) error {
+			c := cu.GetClient(socketFile)
+			httpResp, err := c.Get(server.APIPrefix + "/export?host=" + url.QueryEscape(host))
+			if err != nil {
+				return fmt.Errorf("could not export ticks: %v", err)
+			}
+			if httpResp.StatusCode != http.StatusOK {
+				buf := &bytes.Buffer{}
+				io.Copy(buf, httpResp.Body)
+				return fmt.Errorf("could not export ticks: %s", buf.String())
+			}
+			_, err = io.Copy(os.Stdout, httpResp.Body)
+			return err
+		}),
+	}
+	cmd.Flags().StringVar(&host, "host", "", "If set, only export ticks "+
+		"received by this host")
+	return cmd
+}
+
+// readTickSet reads the ticks to be merged by 't sync --from' from 'path',
+// which may be a time-tracker DB or the output of 't export'
+func readTickSet(path, defaultHost string) (*api.TickSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	header := make([]byte, 16)
+	n, _ := io.ReadFull(f, header)
+	if string(header[:n]) == "SQLite format 3\x00" {
+		return api.ReadTickDB(path, defaultHost)
+	}
+	f.Seek(0, io.SeekStart)
+	var ticks api.TickSet
+	if err := json.NewDecoder(f).Decode(&ticks); err != nil {
+		return nil, fmt.Errorf("%s is neither a time-tracker DB nor an export: %v", path, err)
+	}
+	return &ticks, nil
+}
+
+func syncCmd() *cobra.Command {
+	var from, to, token, defaultHost string
+	cmd := &cobra.Command{
+		Use:   "sync (--from <db-or-export> | --to <url>)",
+		Short: "Merge ticks from another machine's DB into this one (or vice versa)",
+		Long: "Merge ticks from another machine's DB into this one (--from), or " +
+			"push this machine's ticks to another time-tracker server (--to, which " +
+			"must be served with 't serve --listen'). Merging is idempotent.",
+		Run: BoundedCommand(0, 0, func(_ []string) error {
+			if (from == "") == (to == "") {
+				return fmt.Errorf("must pass exactly one of --from and --to")
+			}
+			buf := &bytes.Buffer{}
+			var httpResp *http.Response
+			var err error
+			if from != "" {
+				ticks, err := readTickSet(from, defaultHost)
+				if err != nil {
+					return fmt.Errorf("could not read ticks: %v", err)
+				}
+				json.NewEncoder(buf).Encode(ticks)
+				httpResp, err = cu.GetClient(socketFile).Post(server.APIPrefix+"/sync", buf)
+				if err != nil {
+					return fmt.Errorf("could not sync ticks: %v", err)
+				}
+			} else {
+				httpResp, err = cu.GetClient(socketFile).Get(server.APIPrefix + "/export")
+				if err != nil {
+					return fmt.Errorf("could not export ticks: %v", err)
+				}
+				if httpResp.StatusCode != http.StatusOK {
+					io.Copy(buf, httpResp.Body)
+					return fmt.Errorf("could not export ticks: %s", buf.String())
+				}
+				req, err := http.NewRequest("POST", strings.TrimSuffix(to, "/")+server.APIPrefix+"/sync", httpResp.Body)
+				if err != nil {
+					return err
+				}
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("Authorization", "Bearer "+token)
+				httpResp, err = http.DefaultClient.Do(req)
+				if err != nil {
+					return fmt.Errorf("could not sync ticks: %v", err)
+				}
+			}
+			if httpResp.StatusCode != http.StatusOK {
+				buf.Reset()
+				io.Copy(buf, httpResp.Body)
+				return fmt.Errorf("could not sync ticks: %s", buf.String())
+			}
+			var resp api.SyncResponse
+			if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+				return fmt.Errorf("could not decode response: %v", err)
+			}
+			fmt.Printf("added %d ticks (%d already present)\n", resp.Added, resp.Duplicates)
+			return nil
+		}),
+	}
+	cmd.Flags().StringVar(&from, "from", "", "A time-tracker DB or the output "+
+		"of 't export' to merge into this machine's DB")
+	cmd.Flags().StringVar(&to, "to", "", "The URL of another time-tracker "+
+		"server (e.g. https://desktop:10101) to push this machine's ticks to")
+	cmd.Flags().StringVar(&token, "token", "", "Bearer token for the --to server")
+	cmd.Flags().StringVar(&defaultHost, "host", "", "Host to attribute ticks "+
+		"to, if the --from DB predates host tracking")
+	return cmd
+}
+
+func labelCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "label",
+		Short: "Manage label metadata, such as the colour of each label's intervals",
+		Long:  "Manage label metadata, such as the colour of each label's intervals",
+	}
+	colorCmd := &cobra.Command{
+		Use:   "color <label> [#rrggbb]",
+		Short: "Set the colour in which a label's intervals are drawn",
+		Long: "Set the colour in which a label's intervals are drawn. If no " +
+			"colour is given, the label reverts to a colour derived from its name",
+		Run: BoundedCommand(1, 2, func(args []string) error {
+			req := api.LabelInfo{Name: args[0]}
+			if len(args) > 1 {
+				req.Color = args[1]
+			}
+			buf := &bytes.Buffer{}
+			json.NewEncoder(buf).Encode(req)
+			c := cu.GetClient(socketFile)
+			httpResp, err := c.Put(server.APIPrefix+"/labels/"+url.PathEscape(req.Name), buf)
+			if err != nil {
+				return fmt.Errorf("could not set label colour: %v", err)
+			}
+			if httpResp.StatusCode != http.StatusOK {
+				buf.Reset()
+				io.Copy(buf, httpResp.Body)
+				return fmt.Errorf("could not set label colour: %s", buf.String())
+			}
+			return nil
+		}),
+	}
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the labels that have colours set with 't label color'",
+		Long:  "List the labels that have colours set with 't label color'",
+		Run: BoundedCommand(0, 0, func(_ []string) error {
+			colors, err := getLabelColors()
+			if err != nil {
+				return err
+			}
+			labels := make([]string, 0, len(colors))
+			for l := range colors {
+				labels = append(labels, l)
+			}
+			sort.Strings(labels)
+			mode := termColorMode()
+			for _, l := range labels {
+				fmt.Printf("\x1b[%sm██\x1b[m %s %s\n", sgrColor(colors[l], mode), colors[l], l)
+			}
+			return nil
+		}),
+	}
+	cmd.AddCommand(colorCmd)
+	cmd.AddCommand(listCmd)
+	return cmd
+}
+
+func usersCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "users",
+		Short: "List the users of a multi-user time-tracker server",
+		Long: "List the users of a multi-user time-tracker server, and the size " +
+			"of each user's DB. Only the server's owner may run this",
+		Run: BoundedCommand(0, 0, func(_ []string) error {
+			c := cu.GetClient(socketFile)
+			httpResp, err := c.Get(server.APIPrefix + "/admin/users")
+			if err != nil {
+				return fmt.Errorf("could not list users: %v", err)
+			}
+			if httpResp.StatusCode != http.StatusOK {
+				buf := &bytes.Buffer{}
+				io.Copy(buf, httpResp.Body)
+				return fmt.Errorf("could not list users: %s", buf.String())
+			}
+			var resp api.ListUsersResponse
+			if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+				return fmt.Errorf("could not decode response: %v", err)
+			}
+			for _, u := range resp.Users {
+				owner := ""
+				if u.Owner {
+					owner = " (owner)"
+				}
+				fmt.Printf("%-20s %10d bytes%s\n", u.Name, u.DBBytes, owner)
+			}
+			return nil
+		}),
+	}
+}
+
+// timeFormats are the formats accepted by parseTime
+var timeFormats = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// parseTime parses a time given on the command line, in the local time zone
+// unless it has an offset
+func parseTime(s string) (time.Time, error) {
+	for _, format := range timeFormats {
+		if t, err := time.ParseInLocation(format, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (must look like \"2006-01-02 15:04\" "+
+		"or \"2006-01-02\")", s)
+}
+
+// parseRange parses the <start> and <end> arguments of 't delete' and
+// 't relabel'
+func parseRange(start, end string) (int64, int64, error) {
+	s, err := parseTime(start)
+	if err != nil {
+		return 0, 0, err
+	}
+	e, err := parseTime(end)
+	if err != nil {
+		return 0, 0, err
+	}
+	return s.Unix(), e.Unix(), nil
+}
+
+// postTrashOp sends a request that moves ticks to the trash (to /ticks/delete
+// or /ticks/relabel) and reports the resulting trash batch
+func postTrashOp(endpoint string, req interface{}) error {
+	buf := &bytes.Buffer{}
+	json.NewEncoder(buf).Encode(req)
+	httpResp, err := cu.GetClient(socketFile).Post(server.APIPrefix+endpoint, buf)
+	if err != nil {
+		return fmt.Errorf("request failed: %v", err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		buf.Reset()
+		io.Copy(buf, httpResp.Body)
+		return fmt.Errorf("request failed: %s", buf.String())
+	}
+	var batch api.TrashBatch
+	if err := json.NewDecoder(httpResp.Body).Decode(&batch); err != nil {
+		return fmt.Errorf("could not decode response: %v", err)
+	}
+	if batch.ID == 0 {
+		fmt.Println("no ticks matched")
+		return nil
+	}
+	fmt.Printf("%d ticks changed (trash batch %d; undo with 't undo %d')\n",
+		batch.Ticks, batch.ID, batch.ID)
+	return nil
+}
+
+func deleteCmd() *cobra.Command {
+	var label string
+	cmd := &cobra.Command{
+		Use:   "delete <start> <end>",
+		Short: "Move the ticks in a time range to the trash",
+		Long: "Move the ticks in [start, end) to the trash, from which they can be " +
+			"restored with 't undo' for " + fmt.Sprintf("%.0f days", api.TrashRetention.Hours()/24),
+		Run: BoundedCommand(2, 2, func(args []string) error {
+			start, end, err := parseRange(args[0], args[1])
+			if err != nil {
+				return err
+			}
+			return postTrashOp("/ticks/delete", api.DeleteTicksRequest{
+				Start: start,
+				End:   end,
+				Label: label,
+			})
+		}),
+	}
+	cmd.Flags().StringVar(&label, "label", "", "Only delete ticks with this label")
+	return cmd
+}
+
+func relabelCmd() *cobra.Command {
+	var from string
+	cmd := &cobra.Command{
+		Use:   "relabel <start> <end> <new label>",
+		Short: "Change the label of the ticks in a time range",
+		Long: "Change the label of the ticks in [start, end). The original ticks " +
+			"are kept in the trash, and can be restored with 't undo'",
+		Run: BoundedCommand(3, 3, func(args []string) error {
+			start, end, err := parseRange(args[0], args[1])
+			if err != nil {
+				return err
+			}
+			return postTrashOp("/ticks/relabel", api.RelabelRequest{
+				Start: start,
+				End:   end,
+				From:  from,
+				To:    args[2],
+			})
+		}),
+	}
+	cmd.Flags().StringVar(&from, "label", "", "Only relabel ticks with this label")
+	return cmd
+}
+
+func trashCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "trash",
+		Short: "Inspect the ticks that were deleted or relabelled",
+		Long:  "Inspect the ticks that were deleted or relabelled",
+	}
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the batches of ticks in the trash",
+		Long: "List the batches of ticks in the trash (one per delete, relabel or " +
+			"clear). Batches are purged after " + fmt.Sprintf("%.0f days", api.TrashRetention.Hours()/24),
+		Run: BoundedCommand(0, 0, func(_ []string) error {
+			httpResp, err := cu.GetClient(socketFile).Get(server.APIPrefix + "/trash")
+			if err != nil {
+				return fmt.Errorf("could not list trash: %v", err)
+			}
+			if httpResp.StatusCode != http.StatusOK {
+				buf := &bytes.Buffer{}
+				io.Copy(buf, httpResp.Body)
+				return fmt.Errorf("could not list trash: %s", buf.String())
+			}
+			var resp api.ListTrashResponse
+			if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+				return fmt.Errorf("could not decode response: %v", err)
+			}
+			for _, b := range resp.Batches {
+				fmt.Printf("%-6d %s %6d ticks  %s\n", b.ID,
+					time.Unix(b.Created, 0).Format("2006-01-02 15:04"), b.Ticks, b.Description)
+			}
+			return nil
+		}),
+	}
+	cmd.AddCommand(listCmd)
+	return cmd
+}
+
+func undoCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "undo <batch>",
+		Short: "Undo the delete, relabel or clear that made a batch in the trash",
+		Long: "Undo the delete, relabel or clear that made a batch in the trash " +
+			"(see 't trash list')",
+		Run: BoundedCommand(1, 1, func(args []string) error {
+			batch, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid batch %q: %v", args[0], err)
+			}
+			buf := &bytes.Buffer{}
+			json.NewEncoder(buf).Encode(api.UndoRequest{Batch: batch})
+			httpResp, err := cu.GetClient(socketFile).Post(server.APIPrefix+"/undo", buf)
+			if err != nil {
+				return fmt.Errorf("could not undo: %v", err)
+			}
+			if httpResp.StatusCode != http.StatusOK {
+				buf.Reset()
+				io.Copy(buf, httpResp.Body)
+				return fmt.Errorf("could not undo: %s", buf.String())
+			}
+			var resp api.UndoResponse
+			if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+				return fmt.Errorf("could not decode response: %v", err)
+			}
+			fmt.Printf("%d ticks restored\n", resp.Restored)
+			if len(resp.Skipped) > 0 {
+				fmt.Printf("%d ticks were skipped, because an identical tick was added since:\n", len(resp.Skipped))
+				for _, t := range resp.Skipped {
+					fmt.Printf("  %s %q\n", time.Unix(t.Time, t.Nanos).Format("2006-01-02 15:04:05"), t.Label)
+				}
+			}
+			return nil
+		}),
+	}
+}
+
+func rebuildIntervalsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rebuild-intervals [<start> <end>]",
+		Short: "Recompute the server's stored intervals from its ticks",
+		Long: "Recompute the intervals that the server stores (and serves from) " +
+			"from its ticks, in [start, end] or (by default) everywhere. The server " +
+			"keeps them up to date itself, so this is only needed if they're ever " +
+			"wrong, e.g. after editing the DB by hand",
+		Run: BoundedCommand(0, 2, func(args []string) error {
+			var req api.RebuildIntervalsRequest
+			switch len(args) {
+			case 1:
+				return fmt.Errorf("must give both <start> and <end>, or neither")
+			case 2:
+				var err error
+				if req.Start, req.End, err = parseRange(args[0], args[1]); err != nil {
+					return err
+				}
+			}
+			buf := &bytes.Buffer{}
+			json.NewEncoder(buf).Encode(req)
+			httpResp, err := cu.GetClient(socketFile).Post(server.APIPrefix+"/intervals/rebuild", buf)
+			if err != nil {
+				return fmt.Errorf("could not rebuild intervals: %v", err)
+			}
+			if httpResp.StatusCode != http.StatusOK {
+				buf.Reset()
+				io.Copy(buf, httpResp.Body)
+				return fmt.Errorf("could not rebuild intervals: %s", buf.String())
+			}
+			var resp api.RebuildIntervalsResponse
+			if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+				return fmt.Errorf("could not decode response: %v", err)
+			}
+			fmt.Printf("rebuilt %d intervals\n", resp.Intervals)
+			return nil
+		}),
+	}
+}
+
+func ticksCmd() *cobra.Command {
+	var label, host string
+	var limit int
+	cmd := &cobra.Command{
+		Use:   "ticks [<start> <end>]",
+		Short: "Print the raw ticks in a time range",
+		Long: "Print the raw ticks in [start, end) (by default, today's), with " +
+			"their zones and hosts, e.g. to see why an interval formed the way it did",
+		Run: BoundedCommand(0, 2, func(args []string) error {
+			now := time.Now()
+			start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).Unix()
+			end := start + 24*60*60
+			switch len(args) {
+			case 1:
+				return fmt.Errorf("must give both <start> and <end>, or neither")
+			case 2:
+				var err error
+				if start, end, err = parseRange(args[0], args[1]); err != nil {
+					return err
+				}
+			}
+			q := url.Values{}
+			q.Set("start", strconv.FormatInt(start, 10))
+			q.Set("end", strconv.FormatInt(end, 10))
+			q.Set("format", "ndjson")
+			if label != "" {
+				q.Set("label", label)
+			}
+			if host != "" {
+				q.Set("host", host)
+			}
+			if limit > 0 {
+				q.Set("limit", strconv.Itoa(limit))
+			}
+			httpResp, err := cu.GetClient(socketFile).Get(server.APIPrefix + "/ticks?" + q.Encode())
+			if err != nil {
+				return fmt.Errorf("could not list ticks: %v", err)
+			}
+			defer httpResp.Body.Close()
+			if httpResp.StatusCode != http.StatusOK {
+				buf := &bytes.Buffer{}
+				io.Copy(buf, httpResp.Body)
+				return fmt.Errorf("could not list ticks: %s", buf.String())
+			}
+			d := json.NewDecoder(httpResp.Body)
+			for {
+				var tick api.StoredTick
+				if err := d.Decode(&tick); err == io.EOF {
+					return nil
+				} else if err != nil {
+					return fmt.Errorf("could not decode tick: %v", err)
+				}
+				zone := tick.Zone
+				if zone == "" {
+					zone = "-"
+				}
+				fmt.Printf("%s  %-20s %-8s %s\n",
+					time.Unix(tick.Time, tick.Nanos).Format("2006-01-02 15:04:05.000"),
+					tick.Label, zone, tick.Host)
+			}
+		}),
+	}
+	cmd.Flags().StringVar(&label, "label", "", "Only print ticks with this label")
+	cmd.Flags().StringVar(&host, "host", "", "Only print ticks received by this host")
+	cmd.Flags().IntVar(&limit, "limit", 0, "Print at most this many ticks (default: all)")
+	return cmd
+}
+
+// bucketFormats are the formats in which 't report' prints the start of each
+// bucket, by period
+var bucketFormats = map[string]string{
+	api.Hour: "Mon 2006-01-02 15:04",
+	api.Day:  "Mon 2006-01-02",
+	api.Week: "week of 2006-01-02",
+}
+
+// formatLabelTimes formats the time worked on each label in 'seconds', longest
+// first
+func formatLabelTimes(seconds map[string]int64) string {
+	labels := make([]string, 0, len(seconds))
+	for label := range seconds {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if seconds[labels[i]] != seconds[labels[j]] {
+			return seconds[labels[i]] > seconds[labels[j]]
+		}
+		return labels[i] < labels[j]
+	})
+	parts := make([]string, len(labels))
+	for i, label := range labels {
+		parts[i] = fmt.Sprintf("%s %s", label, time.Duration(seconds[label])*time.Second)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatFocus describes 'f' on one line
+func formatFocus(f *api.Focus) string {
+	return fmt.Sprintf("%d switches, longest %s, median %s, %.0f%% in intervals over %s",
+		f.Switches, time.Duration(f.Longest)*time.Second, time.Duration(f.Median)*time.Second,
+		100*f.DeepShare, time.Duration(api.DeepWorkThreshold)*time.Second)
+}
+
+func reportCmd() *cobra.Command {
+	var period, from, to, label string
+	var focus bool
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Print the time worked in each hour, day or week",
+		Long: "Print the time worked on each label in each hour, day or week " +
+			"between --from and --to (by default, each day of the past week)",
+		Run: BoundedCommand(0, 0, func(_ []string) error {
+			end := time.Now()
+			if to != "" {
+				var err error
+				if end, err = parseTime(to); err != nil {
+					return err
+				}
+			}
+			start := time.Date(end.Year(), end.Month(), end.Day()-6, 0, 0, 0, 0, time.Local)
+			if from != "" {
+				var err error
+				if start, err = parseTime(from); err != nil {
+					return err
+				}
+			}
+			format, ok := bucketFormats[period]
+			if !ok {
+				return fmt.Errorf("invalid --period %q (must be \"hour\", \"day\" or \"week\")", period)
+			}
+			q := url.Values{}
+			q.Set("start", strconv.FormatInt(start.Unix(), 10))
+			q.Set("end", strconv.FormatInt(end.Unix(), 10))
+			q.Set("period", period)
+			if label != "" {
+				q.Set("label", label)
+			}
+			if focus {
+				q.Set("focus", "true")
+			}
+			httpResp, err := cu.GetClient(socketFile).Get(server.APIPrefix + "/summary?" + q.Encode())
+			if err != nil {
+				return fmt.Errorf("could not get summary: %v", err)
+			}
+			if httpResp.StatusCode != http.StatusOK {
+				buf := &bytes.Buffer{}
+				io.Copy(buf, httpResp.Body)
+				return fmt.Errorf("could not get summary: %s", buf.String())
+			}
+			var resp api.SummaryResponse
+			if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+				return fmt.Errorf("could not decode response: %v", err)
+			}
+			var total int64
+			for _, b := range resp.Buckets {
+				total += b.Total
+				fmt.Printf("%-22s %10s  %s\n", time.Unix(b.Start, 0).Format(format),
+					time.Duration(b.Total)*time.Second, formatLabelTimes(b.Seconds))
+				if b.Focus != nil && b.Focus.Longest > 0 {
+					fmt.Printf("%-22s %10s  %s\n", "", "", formatFocus(b.Focus))
+				}
+			}
+			fmt.Printf("%-22s %10s\n", "total", time.Duration(total)*time.Second)
+			return nil
+		}),
+	}
+	cmd.Flags().StringVar(&period, "period", api.Day, "Length of each row: \"hour\", \"day\" or \"week\"")
+	cmd.Flags().StringVar(&from, "from", "", "Start of the report (default: midnight six days ago)")
+	cmd.Flags().StringVar(&to, "to", "", "End of the report (default: now)")
+	cmd.Flags().StringVar(&label, "label", "", "Only count time worked on this label")
+	cmd.Flags().BoolVar(&focus, "focus", false, "Also print how fragmented each row's work was")
+	return cmd
+}
+
+func histogramCmd() *cobra.Command {
+	var by, from, to, label string
+	cmd := &cobra.Command{
+		Use:   "histogram",
+		Short: "Print the time worked in each hour of the day or day of the week",
+		Long: "Print a histogram of the time worked in each hour of the day (or " +
+			"with --by=weekday, each day of the week) between --from and --to " +
+			"(by default, over all ticks)",
+		Run: BoundedCommand(0, 0, func(_ []string) error {
+			q := url.Values{}
+			q.Set("by", by)
+			for _, param := range []struct{ name, value string }{{"from", from}, {"to", to}} {
+				if param.value == "" {
+					continue
+				}
+				t, err := parseTime(param.value)
+				if err != nil {
+					return err
+				}
+				q.Set(param.name, strconv.FormatInt(t.Unix(), 10))
+			}
+			if label != "" {
+				q.Set("label", label)
+			}
+			httpResp, err := cu.GetClient(socketFile).Get(server.APIPrefix + "/stats/histogram?" + q.Encode())
+			if err != nil {
+				return fmt.Errorf("could not get histogram: %v", err)
+			}
+			if httpResp.StatusCode != http.StatusOK {
+				buf := &bytes.Buffer{}
+				io.Copy(buf, httpResp.Body)
+				return fmt.Errorf("could not get histogram: %s", buf.String())
+			}
+			var resp api.HistogramResponse
+			if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+				return fmt.Errorf("could not decode response: %v", err)
+			}
+			var max int64
+			for _, b := range resp.Bins {
+				if b.Seconds > max {
+					max = b.Seconds
+				}
+			}
+			for _, b := range resp.Bins {
+				fmt.Printf("%-4s %s %10s\n", b.Name, HistogramBar(b.Seconds, max, 60),
+					time.Duration(b.Seconds)*time.Second)
+			}
+			fmt.Printf("%-4s %60s %10s\n", "", "total", time.Duration(resp.Total)*time.Second)
+			return nil
+		}),
+	}
+	cmd.Flags().StringVar(&by, "by", api.ByHour, "How to bin time: \"hour\" (of the day) or \"weekday\"")
+	cmd.Flags().StringVar(&from, "from", "", "Start of the histogram (default: the first tick)")
+	cmd.Flags().StringVar(&to, "to", "", "End of the histogram (default: now)")
+	cmd.Flags().StringVar(&label, "label", "", "Only count time worked on this label")
+	return cmd
+}
+
+func backupCmd() *cobra.Command {
+	var to string
+	cmd := &cobra.Command{
+		Use:   "backup [--to <file>]",
+		Short: "Copy the time-tracker DB to a backup file",
+		Long: "Copy the time-tracker DB to a backup file, while the daemon keeps " +
+			"running. By default, the backup is written to " + backupDir,
+		Run: BoundedCommand(0, 0, func(_ []string) error {
+			if to == "" {
+				if err := os.MkdirAll(backupDir, 0700); err != nil {
+					return err
+				}
+				to = filepath.Join(backupDir, "manual-"+time.Now().Format("2006-01-02-150405")+".db")
+			}
+			// The daemon (whose working directory may differ) writes the file
+			path, err := filepath.Abs(to)
+			if err != nil {
+				return err
+			}
+			buf := &bytes.Buffer{}
+			json.NewEncoder(buf).Encode(api.BackupRequest{Path: path})
+			httpResp, err := cu.GetClient(socketFile).Post(server.APIPrefix+"/backups", buf)
+			if err != nil {
+				return fmt.Errorf("could not back up DB: %v", err)
+			}
+			if httpResp.StatusCode != http.StatusOK {
+				buf.Reset()
+				io.Copy(buf, httpResp.Body)
+				return fmt.Errorf("could not back up DB: %s", buf.String())
+			}
+			var resp api.BackupResponse
+			if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+				return fmt.Errorf("could not decode response: %v", err)
+			}
+			fmt.Printf("backed up %d bytes to %s\n", resp.Bytes, resp.Path)
+			return nil
+		}),
+	}
+	cmd.Flags().StringVar(&to, "to", "", "The backup file to write (must not exist)")
+	return cmd
+}
+
+func restoreCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "restore <file>",
+		Short: "Replace the time-tracker DB with a backup",
+		Long: "Check that a backup (made by 't backup') is intact, and replace the " +
+			"time-tracker DB with it while the daemon keeps running. The replaced DB " +
+			"is itself backed up first, in case the restore was a mistake. Only the " +
+			"daemon's owner may restore, and in multi-user mode only the " +
+			"owner's DB is restored; other users' DBs (under " + dataDir + "/users) " +
+			"are left as they are",
+		Run: BoundedCommand(1, 1, func(args []string) error {
+			path, err := filepath.Abs(args[0])
+			if err != nil {
+				return err
+			}
+			buf := &bytes.Buffer{}
+			json.NewEncoder(buf).Encode(api.RestoreRequest{Path: path})
+			httpResp, err := cu.GetClient(socketFile).Post(server.APIPrefix+"/restore", buf)
+			if err != nil {
+				return fmt.Errorf("could not restore DB: %v", err)
+			}
+			if httpResp.StatusCode != http.StatusOK {
+				buf.Reset()
+				io.Copy(buf, httpResp.Body)
+				return fmt.Errorf("could not restore DB: %s", buf.String())
+			}
+			var resp api.RestoreResponse
+			if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+				return fmt.Errorf("could not decode response: %v", err)
+			}
+			fmt.Printf("restored %s (the replaced DB was saved to %s)\n", path, resp.Previous)
+			return nil
+		}),
+	}
+}
+
+func serveCmd() *cobra.Command {
+	var opts server.Options
+	cmd := &cobra.Command{
 		Use:   "serve",
 		Short: "Start the time-tracker server",
 		Long:  "Start the time-tracker server",
@@ -101,9 +998,37 @@ func serveCmd() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("could not create APIServer: %v", err)
 			}
-			return server.ServeOverHTTP(socketFile, api.SystemClock, apiServer)
+			defer apiServer.Close()
+			opts.DataDir = dataDir
+			opts.BackupDir = backupDir
+			return server.ServeOverHTTP(socketFile, api.SystemClock, apiServer, &opts)
 		}),
 	}
+	cmd.Flags().StringVar(&opts.ListenAddr, "listen", "", "If set, also serve "+
+		"over TCP at this address (e.g. 127.0.0.1:10101). Requests over TCP must "+
+		"carry a token created with 't token create'")
+	cmd.Flags().StringVar(&opts.TLSCertFile, "tls-cert", "", "TLS certificate "+
+		"file for the --listen address")
+	cmd.Flags().StringVar(&opts.TLSKeyFile, "tls-key", "", "TLS key file for "+
+		"the --listen address")
+	cmd.Flags().StringVar(&opts.GRPCSocket, "grpc-socket", "", "If set, also "+
+		"serve the API over gRPC (see pb/timetracker.proto) on a unix socket at this path")
+	cmd.Flags().UintSliceVar(&opts.AllowedUIDs, "allow-uid", nil, "Uids (in "+
+		"addition to the server's own) whose processes may connect to the unix socket")
+	cmd.Flags().BoolVar(&opts.MultiUser, "multi-user", false, "Serve each user "+
+		"(identified by uid or token) from their own DB under "+dataDir+"/users. "+
+		"Local users must also be allowed with --allow-uid. Linux only")
+	cmd.Flags().StringVar(&opts.TemplateDir, "templates", dataDir+"/templates",
+		"Directory of web UI templates (e.g. today.html.template) that override "+
+			"the built-in ones. Its static/ subdirectory is served at /static/, and "+
+			"static/theme.css is added to every page")
+	cmd.Flags().BoolVar(&opts.DevTemplates, "dev-templates", false, "Re-read "+
+		"templates for every page, so that changes appear without a restart")
+	cmd.Flags().IntVar(&opts.DailyBackups, "daily-backups", 0, "If nonzero, "+
+		"back up the DB to "+backupDir+" once per day, and keep this many daily backups")
+	cmd.Flags().IntVar(&opts.WeeklyBackups, "weekly-backups", 0, "If nonzero, "+
+		"back up the DB to "+backupDir+" once per week, and keep this many weekly backups")
+	return cmd
 }
 
 func statusCmd() *cobra.Command {
@@ -113,7 +1038,7 @@ func statusCmd() *cobra.Command {
 		Long:  "Give the status of the time-tracker daemon (or start if it it's stopped)",
 		Run: BoundedCommand(1, 1, func(args []string) error {
 			c := cu.GetClient(socketFile)
-			resp, err := c.Get("/status")
+			resp, err := c.Get(server.APIPrefix + "/status")
 			if err != nil {
 				return fmt.Errorf("GET error: %v", err)
 			}
@@ -126,20 +1051,45 @@ func statusCmd() *cobra.Command {
 }
 
 func main() {
+	var zone string
 	rootCmd := cobra.Command{
 		Use:   "t",
 		Short: "T is the client for the golang-time-tracker server",
 		Long: "Client-side CLI for a time-tracking/time-gamifying tool that helps " +
 			"distractable people use their time more mindfully",
 		Run: BoundedCommand(0, 0, func(_ []string) error {
-			Today()
-			return nil
+			switch zone {
+			case "local":
+				return Today(false)
+			case "recorded":
+				return Today(true)
+			}
+			return fmt.Errorf("invalid --zone %q (must be \"local\" or \"recorded\")", zone)
 		}),
 	}
+	rootCmd.Flags().StringVar(&zone, "zone", "local", "Time zone in which to "+
+		"draw intervals: \"local\" (the current zone) or \"recorded\" (the zone "+
+		"in which each interval's work happened)")
 	// rootCmd.AddCommand(watchCmd())
 	rootCmd.AddCommand(serveCmd())
 	rootCmd.AddCommand(statusCmd())
 	rootCmd.AddCommand(tickCmd())
+	rootCmd.AddCommand(tokenCmd())
+	rootCmd.AddCommand(usersCmd())
+	rootCmd.AddCommand(labelCmd())
+	rootCmd.AddCommand(followCmd())
+	rootCmd.AddCommand(exportCmd())
+	rootCmd.AddCommand(syncCmd())
+	rootCmd.AddCommand(deleteCmd())
+	rootCmd.AddCommand(relabelCmd())
+	rootCmd.AddCommand(trashCmd())
+	rootCmd.AddCommand(undoCmd())
+	rootCmd.AddCommand(backupCmd())
+	rootCmd.AddCommand(restoreCmd())
+	rootCmd.AddCommand(rebuildIntervalsCmd())
+	rootCmd.AddCommand(reportCmd())
+	rootCmd.AddCommand(ticksCmd())
+	rootCmd.AddCommand(histogramCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Printf("Error: %v\n", err)
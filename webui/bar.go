@@ -0,0 +1,185 @@
+package webui
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"time"
+
+	"github.com/msteffen/golang-time-tracker/api"
+)
+
+// BarFormat is the image format written by a BarOp
+type BarFormat int
+
+const (
+	// SVG images have hour labels on their axis, and label names as tooltips
+	SVG BarFormat = iota
+	// PNG images are for places that don't accept SVG (e.g. some chat apps)
+	PNG
+)
+
+// Colours of the parts of a bar image that aren't intervals
+const (
+	barBgColor   = "#d5d5d5"
+	barAxisColor = "#777777"
+)
+
+// The height of the axis drawn below bar images, in pixels. PNG axes only have
+// tick marks (the standard library can't draw text), while SVG axes also have
+// hour labels
+const (
+	svgAxisHeight = 16
+	pngAxisHeight = 6
+)
+
+// BarOp has all of the internal data structures retrieved/computed while
+// generating the /bar.svg and /bar.png images: a single day's bar, as drawn by
+// /today, but without the page around it (e.g. for embedding in other pages)
+type BarOp struct {
+	//// Not Owned
+	// The 'server' that handles incoming requests
+	Server api.APIServer
+	// The clock used by 'server' for testing
+	Clock api.Clock
+	// The http response writer that must receive the image
+	Writer http.ResponseWriter
+
+	//// Owned
+	Format BarFormat
+	// The day to render. If zero, today
+	Date time.Time
+	// The time zone whose midnights bound the day. If nil, Clock's
+	Location *time.Location
+	// The size of the bar, in pixels (not including the axis)
+	Width, Height int
+	// If true, draw each label's intervals in that label's colour. Otherwise,
+	// draw all intervals in api.UnlabelledColor
+	ByLabel bool
+	// If true, draw an axis with hour marks below the bar
+	Axis bool
+	// If true, render intervals at the wall-clock time at which they were
+	// recorded, rather than in Location (see api.InRecordedZone)
+	InRecordedZone bool
+
+	// The start and end of the day being rendered
+	morning, night time.Time
+	// The intervals in the day, laid out on the bar
+	divs []div
+}
+
+func (b *BarOp) Start() {
+	if b.Location == nil {
+		b.Location = b.Clock.Now().Location()
+	}
+	if b.Date.IsZero() {
+		b.Date = b.Clock.Now()
+	}
+	b.morning = midnight(b.Date.In(b.Location))
+	b.night = b.morning.AddDate(0, 0, 1)
+	b.getIntervals()
+}
+
+func (b *BarOp) getIntervals() {
+	result, err := getDayIntervals(b.Server, b.morning, b.night, b.InRecordedZone, b.ByLabel)
+	if err != nil {
+		http.Error(b.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	colors := api.LabelColors{}
+	if b.ByLabel {
+		if colors, err = labelColors(b.Server); err != nil {
+			http.Error(b.Writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	b.divs = layoutDivs(result.Intervals, b.morning.Unix(), b.night.Sub(b.morning),
+		float64(b.Width), colors)
+	switch b.Format {
+	case SVG:
+		b.writeSVG()
+	case PNG:
+		b.writePNG()
+	}
+}
+
+// hourX returns the x coordinate of the hour mark 'hour' hours after midnight
+func (b *BarOp) hourX(hour int) int {
+	return hour * b.Width / 24
+}
+
+func (b *BarOp) writeSVG() {
+	height := b.Height
+	if b.Axis {
+		height += svgAxisHeight
+	}
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		b.Width, height, b.Width, height)
+	fmt.Fprintf(buf, `<rect x="0" y="0" width="%d" height="%d" fill="%s"/>`+"\n",
+		b.Width, b.Height, barBgColor)
+	for _, d := range b.divs {
+		fmt.Fprintf(buf, `<rect x="%d" y="0" width="%d" height="%d" fill="%s">`,
+			d.Left, d.Width, b.Height, d.Color)
+		if d.Label != "" {
+			buf.WriteString("<title>")
+			xml.EscapeText(buf, []byte(d.Label))
+			buf.WriteString("</title>")
+		}
+		buf.WriteString("</rect>\n")
+	}
+	if b.Axis {
+		for hour := 0; hour <= 24; hour += 3 {
+			x := b.hourX(hour)
+			fmt.Fprintf(buf, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s"/>`+"\n",
+				x, b.Height, x, b.Height+4, barAxisColor)
+			if hour > 0 && hour < 24 {
+				fmt.Fprintf(buf, `<text x="%d" y="%d" font-size="10" font-family="sans-serif" `+
+					`text-anchor="middle" fill="%s">%d:00</text>`+"\n",
+					x, height-2, barAxisColor, hour)
+			}
+		}
+	}
+	buf.WriteString("</svg>\n")
+	b.Writer.Header().Set("Content-Type", "image/svg+xml")
+	b.Writer.Write(buf.Bytes())
+}
+
+func (b *BarOp) writePNG() {
+	height := b.Height
+	if b.Axis {
+		height += pngAxisHeight
+	}
+	img := image.NewRGBA(image.Rect(0, 0, b.Width, height))
+	fill := func(r image.Rectangle, hex string) {
+		red, green, blue, _ := api.ParseColor(hex)
+		draw.Draw(img, r, &image.Uniform{color.RGBA{red, green, blue, 0xff}}, image.Point{}, draw.Src)
+	}
+	// The axis area (if any) is transparent, so the image sits on any page
+	fill(image.Rect(0, 0, b.Width, b.Height), barBgColor)
+	for _, d := range b.divs {
+		fill(image.Rect(d.Left, 0, d.Left+d.Width, b.Height), d.Color)
+	}
+	if b.Axis {
+		for hour := 3; hour < 24; hour += 3 {
+			x := b.hourX(hour)
+			tick := pngAxisHeight / 2
+			if hour%6 == 0 {
+				tick = pngAxisHeight
+			}
+			fill(image.Rect(x, b.Height, x+1, b.Height+tick), barAxisColor)
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		http.Error(b.Writer, "could not encode PNG: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	b.Writer.Header().Set("Content-Type", "image/png")
+	b.Writer.Write(buf.Bytes())
+}
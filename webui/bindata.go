@@ -1,25 +1,41 @@
-// Code generated by go-bindata.
+// Code generated for package webui by go-bindata DO NOT EDIT. (@generated)
 // sources:
+// month.html.template
 // today.html.template
-// DO NOT EDIT!
-
+// week.html.template
+// year.html.template
 package webui
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
-// bindataRead reads the given file from disk. It returns an error on failure.
-func bindataRead(path, name string) ([]byte, error) {
-	buf, err := ioutil.ReadFile(path)
+func bindataRead(data []byte, name string) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewBuffer(data))
 	if err != nil {
-		err = fmt.Errorf("Error reading asset %s at %s: %v", name, path, err)
+		return nil, fmt.Errorf("Read %q: %v", name, err)
+	}
+
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, gz)
+	clErr := gz.Close()
+
+	if err != nil {
+		return nil, fmt.Errorf("Read %q: %v", name, err)
+	}
+	if clErr != nil {
+		return nil, err
 	}
-	return buf, err
+
+	return buf.Bytes(), nil
 }
 
 type asset struct {
@@ -27,22 +43,121 @@ type asset struct {
 	info  os.FileInfo
 }
 
-// todayHtmlTemplate reads file data from disk. It returns an error on failure.
+type bindataFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+// Name return file name
+func (fi bindataFileInfo) Name() string {
+	return fi.name
+}
+
+// Size return file size
+func (fi bindataFileInfo) Size() int64 {
+	return fi.size
+}
+
+// Mode return file mode
+func (fi bindataFileInfo) Mode() os.FileMode {
+	return fi.mode
+}
+
+// Mode return file modify time
+func (fi bindataFileInfo) ModTime() time.Time {
+	return fi.modTime
+}
+
+// IsDir return file whether a directory
+func (fi bindataFileInfo) IsDir() bool {
+	return fi.mode&os.ModeDir != 0
+}
+
+// Sys return file is sys mode
+func (fi bindataFileInfo) Sys() interface{} {
+	return nil
+}
+
+var _monthHtmlTemplate = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x7c\x55\x4d\x6f\xe3\x36\x10\x3d\x4b\xbf\x62\xe0\x05\x16\xbb\x6d\x2d\x25\x41\xdb\x45\x15\x46\x97\x0d\x7a\x4a\x8b\x02\x09\x10\xf4\x48\x99\x23\x89\x08\x4d\x0a\xd4\xd8\x89\xc1\xea\xbf\x17\x43\x49\x96\x9d\x64\x63\x1f\x3c\x1a\x3e\xbe\x99\x37\x1f\xb2\x68\x51\xaa\x32\x4d\x04\x69\x32\x58\x86\x90\x3d\xb0\x31\x0c\x22\x1f\x3d\x69\x22\x7a\x3a\x18\x04\x3a\x74\x78\xb3\x22\x7c\xa1\x7c\xd3\xf7\xab\x32\x4d\x92\xca\xa9\x03\x84\x34\x49\x92\xda\x59\x5a\xd7\x72\xab\xcd\xa1\x80\x5e\xda\x7e\xdd\xa3\xd7\xf5\x75\x9a\x24\x43\x9a\x26\x49\x66\xe5\xfe\x17\xc8\x22\x25\xff\x3a\x92\x66\xbc\xf9\xac\x15\xb5\x05\x6c\xa4\xd9\x7c\xf9\x06\x3f\xc1\x97\x10\xaa\xe6\x91\x9d\xc3\xd0\x11\xfc\x0c\x97\x57\x1d\x7d\xfd\xca\x4c\xc9\x56\xfa\x46\xdb\x02\x2e\x2f\x3a\x02\xb9\x23\x77\xe4\x27\x59\x19\x84\x70\x0a\x9a\xcf\x93\xca\x79\x85\x7e\xbd\x71\xc6\xc8\xae\xc7\x02\x66\x6b\xb9\xdd\x9e\xa8\x78\x46\xdd\xb4\x54\x80\x75\x7e\x2b\x0d\x63\x92\x8d\x33\xce\x17\xf0\xe9\x5b\xfc\x2c\xd7\xd4\x99\x84\xb3\xc4\x19\x94\x74\x52\x29\x6d\x9b\x02\x7e\x9f\x1c\x63\x2a\x05\x5c\x76\x2f\xd0\x3b\xa3\x15\x7c\x52\xbf\xf1\x37\x9e\xee\xd1\x93\xde\x48\xb3\x96\x46\x37\xb6\x00\x72\xdd\x69\x2c\x09\xe1\x24\x19\x6d\x5b\xf4\x7a\xa4\xe5\xa6\xac\x15\x6e\x9c\x97\xa4\x9d\xe5\xdc\xed\xa2\x2e\x53\xf2\x70\x52\xf0\xda\x38\x49\x05\x78\x56\xf9\x91\xba\x8c\xf4\x16\xab\x66\x0c\xda\xb9\x5e\x8f\xd4\x1e\x8d\x24\xbd\xc7\xeb\x0f\x95\xb7\x53\x11\xaf\x2e\x26\xc7\xd8\x95\x35\xb9\xae\x80\x5f\x27\x5f\x25\x37\x4f\x8d\x77\x3b\xab\xd6\x73\x0e\x4b\x35\x96\x1c\xea\x37\x39\xc8\xaa\x77\x66\x47\x63\x0e\x91\xf2\xe2\xfd\xa8\xef\x44\xa8\xeb\xea\x8f\xcb\x29\x42\x22\xf2\x38\xdb\x3f\x1e\xf2\x10\xa8\xc5\x2d\x7e\xbf\xbf\x1f\x86\x23\x58\xe4\xe3\xd2\x08\x9e\xff\x32\x15\x4a\xef\x61\x63\x64\xdf\xdf\xac\xac\xdc\xf3\x66\x08\x09\xad\xc7\xfa\x66\x15\x42\xf6\x8f\xc7\xfd\x30\xac\xca\xcf\x46\x7a\x7f\x0d\x9d\xc7\xbd\xc8\xe5\x2b\xd0\xdf\xf8\x42\x0c\xb2\xf8\x42\xf0\xd9\x33\x72\x04\xfd\x77\x8e\x7b\x70\x4a\x1e\x18\x48\x6c\xbc\xe5\x79\x44\x7c\xe2\xe3\x67\xc4\xa7\xe9\xb4\x2a\xb7\xce\x52\x2b\xf2\xea\x15\xf6\x5f\x94\x9e\xb1\x07\x94\x3e\x62\x45\xae\xf4\xbe\x4c\x45\x7b\x35\xcb\x89\xfb\xba\x3a\x7b\x2b\xb4\x57\x65\x2a\xe2\xba\x31\x1d\x79\x7e\x0f\x08\x6a\xcb\xbf\x9c\x15\x39\xb5\x25\xdb\x0f\x3b\x3c\xda\x8f\xa8\x16\x7f\xbb\x3b\xda\x7f\x7a\x7d\xb4\xef\x25\x2d\xf6\x6e\xe4\x49\x13\x91\x33\x7b\x08\x5e\xda\x06\x21\x4a\xeb\x87\x61\x0e\x7a\xf4\xb3\x2b\x11\xa4\xca\x10\x74\x0d\xd9\x30\x9c\x68\x54\xf2\x70\xa7\xed\x13\x64\xb7\x92\x90\xb5\xf2\x50\x84\x10\x1f\xb3\x5b\xae\xe4\xe8\xe0\x8b\x0f\xbc\x22\xc3\x20\xfa\x4e\xda\x59\xff\xbc\x39\x5c\x82\x9a\xdf\x07\x74\xbb\x1b\x77\x6c\xc1\xe7\x7c\xa1\x0c\x01\xad\x1a\xe9\x4e\xe7\x81\xe7\xb7\x6a\xe6\xb8\x53\xc6\xb7\x7a\x1f\x85\xbc\x83\xad\x9b\x15\xc4\x29\xbb\x59\x19\xac\xa9\x80\x10\xb2\x3b\xac\x29\x2e\x16\x1c\xd7\x2d\x5b\xb6\x0d\xde\xce\x78\x08\xd9\x77\xb6\x86\xe1\x7a\x05\xb1\x85\xb1\xdf\x77\xb2\x42\xc3\x45\x98\xfa\x1c\x95\x2f\x59\xcf\x4e\x1e\x85\xc9\x2f\x72\xe2\xff\x86\x23\x6a\xee\xc8\xf8\x28\xf2\x69\x0c\xce\x44\x8c\xe5\x8a\xc5\x29\xe0\x83\xaa\xc5\x68\x22\xaf\x9c\x3a\x94\xe9\xff\x03\x00\xf5\x65\xdb\x01\x86\x06\x00\x00")
+
+func monthHtmlTemplateBytes() ([]byte, error) {
+	return bindataRead(
+		_monthHtmlTemplate,
+		"month.html.template",
+	)
+}
+
+func monthHtmlTemplate() (*asset, error) {
+	bytes, err := monthHtmlTemplateBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "month.html.template", size: 1670, mode: os.FileMode(420), modTime: time.Unix(1792358650, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _todayHtmlTemplate = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xb4\x58\x5f\x73\xdb\xb8\x11\x7f\x96\x3e\xc5\x96\x9d\x4b\xa8\xb3\x44\xc9\x97\x3b\x77\x2a\x4b\xca\xf4\x92\xb4\xbd\xc6\x49\x6f\xe2\x74\xfa\x90\xe6\x01\x22\x96\x22\x6a\x0a\xe0\x00\x90\x64\x0d\xcd\xef\xde\x59\x00\xa4\xa8\x3f\x4e\x1f\xda\x1b\xcf\x58\xe0\x62\xff\xe3\x87\xdd\x25\x67\x39\x32\xbe\xe8\xf7\x66\xc6\xee\x0b\x04\xbb\x2f\x71\x1e\x59\x7c\xb4\xe3\xd4\x98\x68\xd1\xef\xf5\x12\x2b\xd6\xb8\x5c\x41\xd5\xef\xf5\x7a\xa5\x32\xc2\x0a\x25\xa7\xa0\xb1\x60\x56\x6c\xf1\x96\xc8\x3b\xc1\x6d\x3e\x85\xaa\x5a\xae\xfe\x49\xcb\xba\x2e\xad\xdb\xc8\x51\xac\x72\x3b\x85\x9b\x49\x20\x58\x55\x4e\xe1\x87\xe6\x69\xcd\xf4\x4a\xc8\x29\xb0\x8d\x55\x6e\x7b\xc9\xd2\x87\x95\x56\x1b\xc9\x47\xa9\x2a\x94\x9e\xc2\xef\xf9\x4f\xf4\x47\xbb\x75\xbf\x71\x27\x3b\x73\x87\x2d\x8d\x2a\x36\x16\x0f\x46\x26\x97\x1d\xb8\x60\x21\xcb\x96\x7f\xbc\xee\x5a\x50\x96\x15\x50\x75\x1d\x7c\x35\x29\xad\xf3\x12\x26\x07\x67\x9f\x8d\x3a\x53\xd2\x8e\x32\xb6\x16\xc5\x7e\x0a\x86\x49\x33\x32\xa8\x45\x76\xb0\x90\xa9\x74\x63\x8e\x2d\x5c\xff\xbf\x2c\xf4\x9a\xb0\x6e\x6e\x6e\x0e\x16\x0b\x5c\xa1\xe4\xbf\x91\x49\x6f\xc2\xec\x98\x4d\x73\x6f\x82\x0b\x53\x16\x6c\x3f\x05\x21\x0b\x21\x71\xb4\x2c\x54\xfa\xd0\xd5\x7f\x3d\x39\x41\x48\x4b\xf0\xde\x8d\xb4\x07\xce\x8f\x9e\xea\x2d\x48\xb6\x3d\x8e\xc0\xfb\x7d\x08\xe4\x7f\x88\xa0\x37\x1b\xbb\x2b\xf0\xfc\x5d\xa8\x2a\x9b\xe3\x1a\xdf\xdc\xdf\xd7\x75\xcb\x3c\x1b\xfb\x0b\x34\x5b\x2a\xbe\x5f\xf4\x67\x5c\x6c\x21\x2d\x98\x31\xf3\x48\xb2\x2d\x5d\xa0\x19\x83\x5c\x63\x36\x8f\xaa\x2a\xf9\x55\xe3\xb6\xae\x23\xb0\xc2\x16\x38\x8f\x4a\x8d\x5b\xa1\x36\x06\x38\xdb\x43\xfc\xa2\x60\x5a\xdf\x0e\xa2\x85\x5f\x00\xed\xce\xc6\xec\x44\xc5\x47\x7c\xb4\x1d\x15\x12\x1f\x6d\x10\xd7\x41\xdc\x91\xfc\x93\x17\x7f\xea\xf7\xaa\x4a\x64\x90\xdc\x89\x2d\xd6\xf5\x6c\xb9\xb0\x8a\xb3\xfd\x6c\xbc\x5c\x54\x15\x16\x86\x68\x1d\x0b\x9f\x69\xb3\x63\xc2\x31\x43\x6c\x07\x51\x23\xc7\x48\x4e\xf2\xba\x3e\x76\xed\x93\x52\xb6\xae\x77\x88\x0f\xaf\x39\xb3\x38\xaf\xaa\xe4\x2d\xb3\x58\xd7\xd1\x82\x88\xe7\xb1\x78\x81\xb5\x92\x36\x3f\x93\x70\xd4\xe7\x44\xf6\xc8\xf4\x99\x04\x11\x9d\xc0\x6c\xcc\xc5\x76\xd1\x9f\xe5\xaf\x8e\x8e\xa2\x65\x9d\x8d\xf3\x57\xc7\x47\x45\x35\x65\xb9\x8a\x40\xf0\x76\xbd\xe8\x57\x95\x66\x72\x85\x90\xbc\x15\x5b\xe3\x62\x3d\x91\xc8\x56\x11\x38\x18\xcc\xa3\x02\x33\x4b\x90\x4b\xee\x30\xb3\xee\x96\x42\x0b\xc3\xe4\x80\x42\x38\xaf\x3e\x55\x95\xbc\xa1\x55\x5d\xdf\xb6\x19\x27\x3d\x6c\x89\x05\x65\xae\xdf\x0b\xe1\x34\x29\x6f\xa2\xeb\x3a\x43\x05\x2b\x5a\x7c\xa6\x9f\x29\xcc\x4c\xc9\xa4\x0f\x85\x08\x04\xdc\x4c\xe9\x35\xb3\x6f\x37\x9a\x51\xf1\x86\xe4\x2d\xdb\x3b\x66\x07\xe4\x92\xc9\xc5\x05\xa5\xbe\x62\xf8\x9c\x84\x75\x27\x27\x77\x8e\xd2\x64\x65\xe1\x6d\x06\x49\x5f\x08\xda\xdc\x7c\x3b\xe6\x45\xf0\xa0\xc5\xa8\x0f\xfc\x90\x82\x16\xa2\x62\x21\x15\x14\x44\x9c\x8d\x45\x83\xc0\x29\x9c\x47\xd7\x86\xf6\xdf\xf2\xe6\xca\xb0\x8f\xd0\x2f\x17\x55\xb5\x13\x36\x87\xe4\xcf\xf4\x48\xb6\x9d\x4b\x4a\xae\xd0\x58\x0a\xb6\xaa\x92\xfb\x9d\xb0\x69\x8e\xa6\xae\xbd\x33\x60\x02\x01\x5e\xac\x05\xe7\xca\xde\x42\xe1\x05\x40\x48\x8b\x7a\xcb\x8a\x0b\x3e\x76\x74\xb6\x62\x6b\xe4\x82\xc9\x0b\xcc\x1f\xdc\x46\x5d\x1f\x2c\x54\x55\x89\x3a\x45\x69\x21\x79\x8b\x58\xde\xe7\x4c\x63\x5d\x7f\x07\x42\xb6\x46\x0d\xa8\x2d\x6a\xf8\x69\xb2\x6e\x52\x10\x7e\x9a\x44\x98\x54\x8b\xd2\x2e\xfa\xbd\xf1\x18\x3e\xe7\x08\x25\x5b\x21\x08\x03\x1a\x25\x47\x8d\x1c\x0c\xea\x2d\xea\x91\x11\x1c\x87\xb0\xdc\x58\x10\x19\x08\x0b\x26\x57\x3b\x03\xae\x14\x0c\xe9\xd9\xe6\x28\xe1\x01\xb1\x34\x4e\x95\xb0\x06\x8b\x0c\x36\x25\x58\x05\x74\x47\xa7\x60\x73\x84\x82\x75\x13\x12\x8b\x0c\x54\x89\x72\x00\x2b\x4d\xda\x84\x04\x8d\xac\x00\xba\x7c\x43\x60\x92\x93\x8c\x53\xe7\xbc\xd2\x38\xca\xd0\x27\x59\x58\x2a\x97\x96\x41\xcc\x0c\xfc\xed\xfe\xef\x1f\x07\xb0\xcb\x51\x22\xc5\x4a\x76\xbc\xd3\xa0\xb1\x54\xda\x1a\x60\x20\x71\x07\x56\xa4\x0f\xfd\xde\x96\x69\x30\x96\x59\x84\x39\x5d\xcb\xba\xbe\xf5\xb4\x42\x31\x8e\x1c\xe6\x40\xd5\x2a\x91\x6a\x17\x0f\x6e\xfb\xfd\x5e\xb6\x91\xa9\x03\xd4\xf1\x71\xc4\x06\x53\x33\x70\x7d\x88\x84\xd7\x30\x87\x0f\xcc\xe6\x49\x56\x28\xa5\xdd\x26\x8c\xe1\x66\x32\x80\xef\xe0\xc6\xcd\x20\x1a\xed\x46\xcb\x0b\x4c\xaf\x6e\x26\x93\x01\x5c\x41\x94\x43\x04\x57\x10\xaf\x61\x06\xd7\x13\x78\x0d\xd1\x24\x82\x29\x44\x11\x6d\xae\x89\x61\x1d\xdd\xf6\x7b\x75\xd7\x27\x7f\x48\xf1\xc1\x0f\xa9\x76\x27\x11\xf4\x7a\x22\x83\xd8\x05\xec\x2a\x3f\xbc\x78\xe1\xb8\xc6\x70\x3d\x99\x4c\x60\x31\xf7\xc9\x48\x3e\x28\x2d\x85\x5c\xc1\x15\xfc\xf0\x23\x7c\x0f\x37\x13\xf7\xcf\x6b\x6e\xb0\x41\x1d\x40\x78\x44\xdd\x92\x98\xb6\x21\xb3\x4a\x22\xb5\xd6\x9d\x90\x5c\xed\x92\x42\xa5\x0e\xb2\x89\x46\x4a\x2a\xe5\xb1\xd7\x24\x20\xb4\x59\xd2\x78\x8f\xa9\x92\xdc\x80\xcd\x99\xbd\x00\x8e\x9c\x19\x07\x0b\x09\x46\xc8\x14\xe1\xa5\xf3\xf3\x25\xec\x98\x01\x0f\x03\x1e\x82\xf6\x5c\x73\x70\x79\xa6\x2c\x70\xb1\x35\xd0\x04\x46\x75\x1b\x9e\x9e\xe0\xcb\xd7\x26\x19\xb4\x9d\x14\x28\x57\x36\x87\x05\x4c\x28\x23\x9e\xf5\x9d\xe4\x7f\x61\x25\xd1\x42\xdc\xa4\x0c\x0b\x56\x1a\xe4\xc7\x07\x1c\x53\x0a\x47\x01\x33\x83\x90\x4c\x1f\x67\xe3\x8d\x3b\xe9\x35\x7b\x8c\x27\xc3\xb0\x16\x32\x0e\xca\x86\xc1\xe0\x07\xf6\x48\x06\x47\x47\xf6\x07\x83\x26\x49\x64\x7e\xb9\x82\x39\x70\x95\x6e\xd6\x28\x6d\xb2\x42\xfb\xae\x40\x5a\xfe\xbc\xff\x85\xc7\x4d\x9b\x72\x12\xcb\x55\x22\xa4\x44\xfd\xd7\xcf\x1f\xee\x60\x0e\x11\xa1\xa5\xe7\x62\xcd\x94\x7e\xc7\xd2\x3c\x6e\x70\x13\xf3\x21\x88\x4e\x88\xae\x49\x91\x19\xdf\xa4\x48\xce\xa1\x46\xc0\x7c\x3e\x87\x6e\xba\x46\x70\x1d\xe4\xfc\x80\x05\x57\x47\x69\xf1\x61\xfc\xec\xc7\x54\xf8\x3e\x9c\xdf\x18\xe2\x23\x4c\x39\x6f\x5d\x80\xce\x3a\x16\xdd\x08\x53\x8d\xcc\x62\x08\x32\x8e\xb8\xd8\x46\x9e\x1f\x8b\xc4\x75\x96\x8f\x6c\x4d\x57\xb7\xe9\xbd\xcd\x9e\x6b\x33\x09\x75\x60\xd2\xe6\x3a\x30\xdd\x99\xd2\x9e\x70\x34\xb1\x06\xf7\x2f\x70\x1c\x3a\x95\x6b\x4f\x4e\x9d\x5b\x35\x6c\xae\x3f\x3b\xf2\x1d\x95\x7e\x47\x5e\xae\x12\x56\x96\x28\xf9\x9b\x5c\x14\x3c\xc6\xc2\x39\x5d\xbb\xff\xcf\x9f\x1e\xb5\xa8\x68\x90\xd0\x74\xf9\x46\x49\x4b\x95\x7c\x4e\xda\x4e\x6b\x8d\x4b\x6b\xd3\xae\xe1\xca\xe7\xd5\x95\x27\x97\xc1\x30\xd6\x7f\x03\x27\xa1\x75\x3b\x77\xfc\xfa\x02\x56\x9a\x2a\xe1\xf6\xfd\x95\x19\x9c\x63\x07\x3b\xc0\xb1\xce\x9f\x39\xa0\xef\xb6\x2d\x70\x2e\xdc\x30\xf4\x9d\xbc\x45\xd4\x97\x13\x58\x7d\xf5\xfb\x0d\xba\xbc\xe6\xab\xb9\x0f\xf5\x18\x30\x5a\xed\xba\xb1\x3e\x8b\x18\xe2\x0d\xaf\x23\xcf\xb3\xd3\xd8\x11\xf8\x3d\xef\x31\xca\xc2\x14\xd3\xdd\x7f\x0e\x25\xd8\x41\x89\x56\xbb\x23\x3c\x78\x35\x07\xb7\xa4\x07\xf1\x33\x4e\x35\xb9\x7a\x0d\xde\x3d\x6a\x03\x22\x38\x49\x92\xc7\x80\x81\x86\xfd\xe9\x09\xa2\x66\x38\x8a\x2e\xba\x41\xc2\x83\x8b\x3b\x27\x9e\x7c\xc6\x47\xfb\x51\x71\x8c\xa3\xa9\xeb\x4a\x27\x80\x74\xa7\x33\xf0\x85\xaa\xc1\x53\x57\x9b\x56\xbb\x16\xff\x01\xa3\x59\x5b\x90\xdd\x54\xf5\xcd\x7b\xe1\xc7\xb0\x93\x7b\x01\xbf\xcb\x9a\x19\x8c\x9a\x63\x04\x53\x8a\x23\x6b\x27\x31\xba\xed\xa7\xb3\xd8\xbf\x36\x93\xc9\xf2\x0f\xe7\x93\xd8\x85\x90\x5a\xe5\x03\xb8\x22\xc5\x51\x23\x1c\xe6\xb1\x8b\x22\x7e\x24\xa3\x06\xdd\xb2\x47\x5e\xdc\x55\x7c\xf7\x09\x23\xbe\x9e\x50\xdd\xcb\x0e\x23\x9a\xe3\x7f\x66\x4a\xbb\xd0\xe2\x33\x8d\x26\xef\xf4\xf8\x92\x69\xb6\xa6\xfe\x46\x33\xcd\x3f\x3e\xdd\xdd\x23\xd3\x69\xfe\xab\xa3\xc6\xa7\x2d\xd8\xb8\x4d\x77\x18\x5e\x2e\x31\x68\xe3\xc8\x47\x12\x0d\x21\xfa\xb7\x51\x01\xff\xae\xa9\x9e\x29\x28\x99\xcd\x09\x36\xe4\xf3\x6b\x4a\x42\x50\x63\xd5\xbd\xd5\x42\xae\xe2\xc1\x20\xa1\xd9\xef\x50\x20\x34\x9a\x32\x5c\xe3\x30\xf4\x10\x25\x21\x43\x71\x40\xc5\x89\x04\x4d\x72\x41\xa2\x99\xcc\x88\x44\xbc\xbd\x8b\x63\x99\x53\xed\x47\x9f\xb6\xcc\x52\xd6\x8e\x47\x1d\xaf\x92\x68\x21\xa8\x77\x5b\x94\xf6\x5e\x6d\x74\xda\x14\x31\x4a\x61\x87\x1a\x84\xe9\xf5\x92\xe2\x65\xa5\x18\x6f\xaf\xc7\x48\x0c\x84\x47\xc6\xb9\x63\xbe\x13\xc6\xa2\x44\x4d\xfd\x37\x7d\x18\x69\x4c\x51\x6c\x91\x47\xc3\xe6\xb4\xda\x0e\x6e\xd0\xfe\x12\xce\x38\xf6\xc3\xda\x10\x5e\x11\x1c\x9a\x79\x81\xbc\x1e\x8f\xe1\x3d\xee\x97\x8a\x69\x4e\x63\xb5\xb6\xe9\xc6\x9a\x29\x50\x2f\x1b\xbb\x0f\x20\xc0\xb4\x9b\x8f\xd7\x6a\x8b\xb0\x44\xbb\x43\x94\xf4\xba\x6f\x86\xf0\xd2\xbe\x84\x95\x42\x9a\xc4\xfd\x30\xde\x3f\x5c\xab\x73\x77\x1f\x70\xcf\xd5\x4e\x46\x43\x68\x73\xdf\xc9\x12\x26\xac\xb0\xef\x71\x4f\xa5\x1f\x93\xd4\xea\xa2\x7d\x58\xa3\x65\xef\x71\x1f\xb2\x76\x3c\xca\x51\x3d\x2b\x84\x7c\x20\x48\x56\xd1\x9f\xc8\x55\xea\xbe\xd1\x34\xdc\x77\xfa\xdc\x31\x04\xbf\xf1\x89\xc2\x69\x77\xe8\x2b\xc6\x10\xa2\x03\xc1\x7f\x74\x68\xe6\x34\xa7\xf4\x0b\x26\x0f\xb8\xff\x1a\x2c\x9f\xa2\x93\x3e\x57\xc0\x1c\xba\x9c\xc1\x2d\xc2\xc4\x6c\xdc\xbc\xdd\xcc\xc6\x4b\xc5\xf7\x8b\xfe\x7f\x06\x00\xaf\x9b\x53\x9e\xe9\x14\x00\x00")
+
+func todayHtmlTemplateBytes() ([]byte, error) {
+	return bindataRead(
+		_todayHtmlTemplate,
+		"today.html.template",
+	)
+}
+
 func todayHtmlTemplate() (*asset, error) {
-	path := "/home/mjs/clients/time-tracker/src/github.com/msteffen/golang-time-tracker/webui/today.html.template"
-	name := "today.html.template"
-	bytes, err := bindataRead(path, name)
+	bytes, err := todayHtmlTemplateBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "today.html.template", size: 5353, mode: os.FileMode(420), modTime: time.Unix(1792360622, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _weekHtmlTemplate = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x53\xdd\x6e\xdb\x3c\x0c\xbd\x96\x9f\x82\xf0\x07\x14\xdf\xb0\xc5\x49\x3b\x6c\x58\x1d\xc7\x37\x2d\x76\x31\xb4\xc3\x80\x16\x28\x76\x49\xc7\xb4\x2d\xc4\x91\x0c\x59\x71\x63\x68\x7a\xf7\x81\xce\x4f\xe3\x66\x7f\xc8\x85\x18\x89\x3c\xe7\x90\x3e\x4c\x2a\xc2\x3c\x0d\x44\x62\xa5\xad\x29\x75\x2e\x7a\xe4\xc0\xfb\x64\xba\xbb\x09\x44\xd2\xda\xbe\x26\xb0\x7d\x43\x8b\xd0\xd2\xd6\x4e\x97\x6d\x1b\xa6\x81\x10\x99\xce\x7b\x70\x81\x10\xa2\xd0\xca\x4e\x0a\x5c\xcb\xba\x8f\xa1\x45\xd5\x4e\x5a\x32\xb2\x98\x07\x42\xf8\x20\x10\x22\x52\xd8\xbd\x83\x68\x80\xe4\x53\x5b\xac\x77\x95\xcf\x32\xb7\x55\x0c\xce\x65\xe5\x13\x87\xde\x37\x96\xcb\xc4\x1a\x4d\x29\x55\x0c\x97\xb3\xc6\x02\x6e\xac\x7e\x01\xcb\x71\x4f\x9b\xcb\xb6\xa9\xb1\x8f\xa1\xa8\x69\xcb\xef\x02\x6b\x59\xaa\x89\xb4\xb4\x6e\x63\x58\x92\xb2\x64\xe6\x27\x34\x4b\xac\x97\xff\x8f\xb8\xe0\x2d\x5c\x7e\x9a\x35\xf6\xcd\x88\xf4\xe3\x19\x67\x8d\x19\x8d\x25\x5f\xcf\x1a\x3b\xd2\x74\xde\xd5\x21\x45\xf0\xd8\x26\x83\xb6\x18\x8c\x2c\xab\x93\x42\x2b\xd7\x94\x95\x3b\xe4\x46\xb7\xd2\x4a\xad\x62\x30\x54\xa3\x95\x1d\xcd\xff\x38\xa3\x8a\x18\x2b\x86\xf7\x07\x9e\x0c\x97\xab\xd2\xe8\x8d\xca\x27\x4b\x5d\x6b\x13\xc3\x7f\xf9\x07\xfe\x8d\xf9\x8a\x33\x3e\xcc\x5a\x5d\x6f\xec\x8e\xcf\xea\x26\x86\xd9\x3f\x33\x14\x45\x76\x7d\xb9\x67\x10\xc9\x74\xb0\xcb\xef\x7d\xe3\x9c\xad\x68\x4d\x37\x0f\x0f\xde\x1f\x93\x93\xe9\xce\x87\x09\x5b\x2a\x0d\x92\x5c\x76\xb0\xac\xb1\x6d\x17\xa1\xc2\x8e\xcd\x96\x20\x54\x86\x8a\x45\xe8\x5c\xf4\xcd\x50\xe7\x7d\x98\x5e\xd4\x68\xcc\x1c\x1a\x43\x5d\x32\xc5\x57\x49\x5f\x69\x6b\x39\x49\xd1\xd6\xc2\x85\xe1\xcc\x5d\xd2\x8f\x71\xde\xa3\xce\xb1\xe7\x44\xcb\xc1\x1e\x27\x4b\x9f\x89\x56\xc9\x34\x7b\x05\x7a\xaf\x15\x5b\x34\x4c\xd7\x1c\x9c\x93\x7e\x27\x34\xfc\xdc\x13\x9a\xe1\x35\x99\xe6\xb2\x4b\x83\xa4\xba\x3a\xf4\x33\xec\x40\x38\xda\xb4\xea\x2a\x0d\x9c\x33\xa8\x4a\x82\xe8\x89\x68\xd5\x7a\x7f\xfc\xcf\xa1\x2c\x20\xf2\x7e\x34\x96\x1c\xfb\x61\x2c\x27\x57\x83\x45\xc3\xf4\x44\x4f\x8e\xfd\x9d\x54\x2b\x88\x6e\xd1\x12\xeb\x72\x6e\x08\xa3\xcf\xda\xac\xd1\x42\x78\xaf\x15\x7c\x41\x05\x57\x21\xeb\xc0\x74\x2f\x77\x04\xcb\x86\xc9\x4a\x26\x3b\x6a\xba\x95\x5d\xeb\x7d\x20\xce\xf2\x8a\x32\x84\xe1\x93\x2e\xc2\x9a\x0a\xcb\x9e\x8d\xee\xa8\xb0\xc3\x56\xc3\xd1\xc7\xd1\x8b\x8d\xe1\xdc\x50\xce\x45\x37\x1c\x79\x3f\x0f\x61\x18\xd7\x30\xfb\x3b\x6e\x8f\x9b\x38\x88\x74\x8e\x54\xce\x32\x7e\xa5\xfa\xb0\x8f\xdc\x73\x31\x74\x7b\xbb\x31\xc8\xcb\x05\xd1\x23\x3f\x70\xc3\x43\xd9\xfe\xd8\xa3\x8d\x8f\xd1\xc8\xf7\x78\x43\x75\x0c\x7f\x87\xcd\x74\xde\xa7\xc1\xcf\x01\x00\x4e\xe8\xf8\x93\x62\x05\x00\x00")
+
+func weekHtmlTemplateBytes() ([]byte, error) {
+	return bindataRead(
+		_weekHtmlTemplate,
+		"week.html.template",
+	)
+}
+
+func weekHtmlTemplate() (*asset, error) {
+	bytes, err := weekHtmlTemplateBytes()
 	if err != nil {
 		return nil, err
 	}
 
-	fi, err := os.Stat(path)
+	info := bindataFileInfo{name: "week.html.template", size: 1378, mode: os.FileMode(420), modTime: time.Unix(1792358650, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _yearHtmlTemplate = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x7c\x93\xcf\x6f\xab\x38\x10\xc7\xcf\xf6\x5f\x31\x62\xa5\x9e\x1a\x48\xd2\x1f\xea\x26\x2e\x97\xad\xf6\xb0\x6a\x57\x4f\x6a\xa5\x77\x36\x78\x08\x28\xc6\x46\xc6\xa1\x41\x7e\xfe\xdf\x9f\x0c\x49\x4a\xda\xe6\x9d\x18\x66\x3e\x33\xfe\x7a\xf4\x35\x2b\x91\x8b\x94\x12\x66\x2b\x2b\x31\x75\x2e\x7e\x0b\x81\xf7\x2c\x19\x33\x94\xb0\xd6\xf6\x12\xc1\xf6\x0d\x3e\x46\x16\xf7\x36\xc9\xdb\x36\x4a\x29\x21\x99\x16\x3d\x38\x4a\x08\x29\xb4\xb2\xb3\x82\xd7\x95\xec\x57\xd0\x72\xd5\xce\x5a\x34\x55\xb1\xa6\x84\x78\x4a\x09\x89\x15\xef\xae\x21\x1e\x46\x86\xaf\xb6\x5c\x5e\x43\x5c\x22\xb7\x35\x6f\xc6\x19\xef\x95\xb0\xe5\x0a\xee\xef\xe6\x8d\x0d\x8d\xa4\xe6\x66\x53\xa9\x15\x2c\xe6\x8d\x05\xbe\xb3\xfa\x63\xdc\x59\xa3\xa8\xda\x46\xf2\x7e\x05\x85\xc4\xfd\x07\xf3\x8e\xb8\xbd\x00\x90\x40\xce\x44\x65\x30\xb7\x95\x56\x2b\xc8\xb5\xdc\xd5\xea\xa3\x37\x47\x29\x3f\xf5\x66\x52\xe7\xdb\xf5\x44\xe8\xe2\xa8\xb3\xc4\x6a\x53\xda\x49\xe2\x24\x7c\xfc\x1f\x47\x4a\xec\x50\xce\xc1\x41\xc6\xf3\xed\xc6\xe8\x9d\x12\xb3\x5c\x4b\x6d\x56\xf0\x17\x66\x28\x8a\xf9\x1a\xfc\x09\x5c\x7c\x0f\x16\x05\x3e\x64\x67\xe0\xf2\x12\x28\x6e\xee\x71\x0a\xde\x5c\x02\xb3\xbf\x17\x77\x53\xf0\xf6\x7b\x50\xdc\x3e\xf0\xf9\x78\x34\x4b\x06\x4b\x5c\xf6\x86\x73\xb6\xc4\x1a\xff\x79\x7d\xf5\xfe\x04\xb3\x64\xf4\x1a\x0b\xb6\x49\x29\x13\x55\x07\xb9\xe4\x6d\xfb\x18\x29\xde\x05\x43\x31\x0e\xa5\xc1\xe2\x31\x72\x2e\xfe\x61\xb0\xf3\x3e\x4a\xaf\x24\x37\x66\x0d\x8d\xc1\x8e\x25\xfc\x13\xf4\x3f\xee\x6d\x80\x14\xee\x2d\x5c\x99\x40\x8e\xd0\xaf\x73\xee\x4d\x0b\xde\x07\xd0\x86\xe0\xeb\x9c\x9f\x88\xdb\x50\x0e\x96\xf9\x5a\x7d\xd1\xca\x96\xa1\x5c\x87\xe0\x50\xcf\xd2\x1e\xb9\x61\x49\x96\x52\x96\x88\xaa\x4b\x29\x2b\x97\xc7\xfb\x0c\x3e\x8f\xce\x5e\x53\xb9\x3c\xbf\xf2\xc1\xc1\x51\x4a\x9d\x33\x5c\x6d\x10\x06\x15\xad\xf7\x94\x4c\xb9\x20\x29\xec\xe6\x44\x05\x80\x38\x57\x15\x87\x90\xf1\x23\x3a\x98\x76\x30\x85\x73\xf1\x73\xf8\x7a\x1f\x9d\xae\x21\x78\xff\x5c\xa9\x2d\xc4\x4f\xdc\xa2\xf7\x11\x25\x04\x00\x06\xa5\xc3\x2e\x43\x3a\xfe\x57\x9b\x9a\x5b\x88\x5e\xb4\x82\xff\xb8\x82\x65\xe4\xfd\x0a\x9c\x2b\x86\xfc\xd3\xce\xf0\xf0\x5e\x20\x7e\x0b\x0f\x38\xac\x64\xdc\x06\x71\x0e\x65\x8b\xa3\x9e\xb6\xe1\x6a\x2a\x29\x40\x21\x77\xe0\x94\x08\xd8\x29\x38\x2c\xef\xf8\x7f\xdc\xe5\x64\x03\x36\x9c\x15\xa5\xc3\x91\x7f\xd0\x72\xec\x4c\x32\x2d\xfa\x94\xfe\x1e\x00\x94\xdb\x90\xef\xd9\x04\x00\x00")
+
+func yearHtmlTemplateBytes() ([]byte, error) {
+	return bindataRead(
+		_yearHtmlTemplate,
+		"year.html.template",
+	)
+}
+
+func yearHtmlTemplate() (*asset, error) {
+	bytes, err := yearHtmlTemplateBytes()
 	if err != nil {
-		err = fmt.Errorf("Error reading asset info %s at %s: %v", name, path, err)
+		return nil, err
 	}
 
-	a := &asset{bytes: bytes, info: fi}
-	return a, err
+	info := bindataFileInfo{name: "year.html.template", size: 1241, mode: os.FileMode(420), modTime: time.Unix(1792358650, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
 }
 
 // Asset loads and returns the asset for the given name.
@@ -97,7 +212,10 @@ func AssetNames() []string {
 
 // _bindata is a table, holding each asset generator, mapped to its name.
 var _bindata = map[string]func() (*asset, error){
+	"month.html.template": monthHtmlTemplate,
 	"today.html.template": todayHtmlTemplate,
+	"week.html.template":  weekHtmlTemplate,
+	"year.html.template":  yearHtmlTemplate,
 }
 
 // AssetDir returns the file names below a certain
@@ -139,8 +257,12 @@ type bintree struct {
 	Func     func() (*asset, error)
 	Children map[string]*bintree
 }
+
 var _bintree = &bintree{nil, map[string]*bintree{
+	"month.html.template": &bintree{monthHtmlTemplate, map[string]*bintree{}},
 	"today.html.template": &bintree{todayHtmlTemplate, map[string]*bintree{}},
+	"week.html.template":  &bintree{weekHtmlTemplate, map[string]*bintree{}},
+	"year.html.template":  &bintree{yearHtmlTemplate, map[string]*bintree{}},
 }}
 
 // RestoreAsset restores an asset under the given directory
@@ -189,4 +311,3 @@ func _filePath(dir, name string) string {
 	cannonicalName := strings.Replace(name, "\\", "/", -1)
 	return filepath.Join(append([]string{dir}, strings.Split(cannonicalName, "/")...)...)
 }
-
@@ -0,0 +1,248 @@
+package webui
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/msteffen/golang-time-tracker/api"
+)
+
+// Period identifies the span of time rendered by a CalendarOp
+type Period int
+
+const (
+	// Week renders one bar per day, stacked, from Monday to Sunday
+	Week Period = iota
+	// Month renders a calendar grid with a small bar in each day's cell
+	Month
+	// Year renders a heatmap of hours worked per day
+	Year
+)
+
+// String returns the name of the page that renders 'p' (e.g. "week")
+func (p Period) String() string {
+	switch p {
+	case Week:
+		return "week"
+	case Month:
+		return "month"
+	case Year:
+		return "year"
+	}
+	return fmt.Sprintf("Period(%d)", int(p))
+}
+
+// DateFormat is the format of the 'date' parameter accepted by the calendar
+// pages, which selects the week/month/year containing that date
+const DateFormat = "2006-01-02"
+
+// day is the rendered form of one day in a calendar page
+type day struct {
+	Date  time.Time
+	Divs  []div
+	Total int64 // seconds worked
+	Level int   // heatmap intensity, from 0 (no work) to maxLevel
+}
+
+// maxLevel is the most intense heatmap colour. Each level is two more hours
+// of work, so a day with 8+ hours worked has the darkest colour
+const maxLevel = 4
+
+// calendarData is the data rendered into week.html.template,
+// month.html.template and year.html.template
+type calendarData struct {
+	Title string
+	// Links to the previous/next period, and the other calendar pages
+	Prev, Next, Today, Week, Month, Year string
+	// Rows of seven days (Monday through Sunday). Days that are outside of the
+	// period (e.g. before the first of the month) are nil
+	Weeks [][]*day
+	Total int64
+}
+
+// CalendarOp has all of the internal data structures retrieved/computed while
+// generating the /week, /month and /year pages
+type CalendarOp struct {
+	//// Not Owned
+	// The 'server' that handles incoming requests
+	Server api.APIServer
+	// The clock used by 'server' for testing
+	Clock api.Clock
+	// The http response writer that must receive the page
+	Writer http.ResponseWriter
+	// The page's template (if nil, the built-in one)
+	Templates *Templates
+
+	//// Owned
+	// The span of time to render
+	Period Period
+	// Any date in the week/month/year to render. If zero, the current one
+	Date time.Time
+	// The width of each day's bar (in /week and /month)
+	BgWidth float64
+	// If true, render intervals at the wall-clock time at which they were
+	// recorded, rather than in Clock's time zone (see api.InRecordedZone)
+	InRecordedZone bool
+
+	// The first day of the period, and the first day after it
+	start, end time.Time
+	// The intervals in [start, end)
+	intervals []api.Interval
+	// 'intervals', split into days
+	days []*day
+	// The colour of each label's intervals
+	colors api.LabelColors
+}
+
+func (c *CalendarOp) Start() {
+	if c.Date.IsZero() {
+		c.Date = c.Clock.Now()
+	}
+	c.computePeriod()
+	c.getIntervals()
+}
+
+// midnight returns the start of the day containing 't'
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// computePeriod sets 'start' and 'end' to the bounds of the period containing
+// 'Date'
+func (c *CalendarOp) computePeriod() {
+	d := midnight(c.Date.In(c.Clock.Now().Location()))
+	switch c.Period {
+	case Week:
+		// time.Weekday starts on Sunday, but weeks start on Monday
+		c.start = d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7))
+		c.end = c.start.AddDate(0, 0, 7)
+	case Month:
+		c.start = d.AddDate(0, 0, 1-d.Day())
+		c.end = c.start.AddDate(0, 1, 0)
+	case Year:
+		c.start = time.Date(d.Year(), 1, 1, 0, 0, 0, 0, d.Location())
+		c.end = c.start.AddDate(1, 0, 0)
+	}
+}
+
+func (c *CalendarOp) getIntervals() {
+	req := &api.GetIntervalsRequest{
+		Start:   c.start.Unix(),
+		End:     c.end.Unix(),
+		ByLabel: true,
+	}
+	if c.InRecordedZone {
+		// Intervals outside of the period may be shifted into it
+		req.Start = c.start.Add(-api.MaxZoneShift).Unix()
+		req.End = c.end.Add(api.MaxZoneShift).Unix()
+	}
+	result, err := c.Server.GetIntervals(req)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	c.intervals = result.Intervals
+	if c.colors, err = labelColors(c.Server); err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if c.InRecordedZone {
+		c.intervals = api.InRecordedZone(c.intervals, c.start.Location())
+	}
+	c.computeDays()
+}
+
+// computeDays splits 'intervals' into days, and lays out each day's bar
+func (c *CalendarOp) computeDays() {
+	for d := c.start; d.Before(c.end); d = d.AddDate(0, 0, 1) {
+		// Days aren't always 24h long (e.g. DST transitions)
+		next := d.AddDate(0, 0, 1)
+		intervals := api.ClampIntervals(c.intervals, d.Unix(), next.Unix())
+		total := totalSecs(intervals)
+		level := int((total + 2*60*60 - 1) / (2 * 60 * 60))
+		if level > maxLevel {
+			level = maxLevel
+		}
+		c.days = append(c.days, &day{
+			Date:  d,
+			Divs:  layoutDivs(intervals, d.Unix(), next.Sub(d), c.BgWidth, c.colors),
+			Total: total,
+			Level: level,
+		})
+	}
+	c.generateTemplate()
+}
+
+// link returns a relative URL for the calendar page 'page' showing the period
+// containing 'date'
+func (c *CalendarOp) link(page string, date time.Time) string {
+	url := page + "?date=" + date.Format(DateFormat)
+	if c.InRecordedZone {
+		url += "&zone=recorded"
+	}
+	return url
+}
+
+// dayLink returns a relative URL for the /day page showing 'date'
+func (c *CalendarOp) dayLink(date time.Time) string {
+	url := "day/" + date.Format(DateFormat)
+	if c.InRecordedZone {
+		url += "?zone=recorded"
+	}
+	return url
+}
+
+// weeks arranges 'days' into rows of seven, from Monday to Sunday
+func (c *CalendarOp) weeks() [][]*day {
+	var weeks [][]*day
+	// Pad the first week with the days before the period starts
+	week := make([]*day, (int(c.start.Weekday())+6)%7)
+	for _, d := range c.days {
+		week = append(week, d)
+		if len(week) == 7 {
+			weeks = append(weeks, week)
+			week = nil
+		}
+	}
+	if len(week) > 0 {
+		weeks = append(weeks, append(week, make([]*day, 7-len(week))...))
+	}
+	return weeks
+}
+
+func (c *CalendarOp) generateTemplate() {
+	data := calendarData{
+		Weeks: c.weeks(),
+		Total: totalSecs(api.ClampIntervals(c.intervals, c.start.Unix(), c.end.Unix())),
+	}
+	var prev time.Time
+	switch c.Period {
+	case Week:
+		data.Title = "Week of " + c.start.Format("Jan 2, 2006")
+		prev = c.start.AddDate(0, 0, -7)
+	case Month:
+		data.Title = c.start.Format("January 2006")
+		prev = c.start.AddDate(0, -1, 0)
+	case Year:
+		data.Title = c.start.Format("2006")
+		prev = c.start.AddDate(-1, 0, 0)
+	}
+	data.Prev = c.link(c.Period.String(), prev)
+	data.Next = c.link(c.Period.String(), c.end)
+	data.Today = "today"
+	if c.InRecordedZone {
+		data.Today += "?zone=recorded"
+	}
+	data.Week = c.link(Week.String(), c.start)
+	data.Month = c.link(Month.String(), c.start)
+	data.Year = c.link(Year.String(), c.start)
+
+	c.Templates.execute(c.Writer, c.Period.String()+".html.template", template.FuncMap{
+		"bgWidth":        func() int { return int(c.BgWidth) },
+		"formatDuration": formatDuration,
+		"link":           c.link,
+		"dayLink":        c.dayLink,
+	}, data)
+}
@@ -0,0 +1,197 @@
+package webui
+
+import (
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// themeFile is the stylesheet (in a template directory's 'static'
+// subdirectory) that is added to every page, so that pages can be restyled
+// without copying their templates
+const themeFile = "theme.css"
+
+// templateFuncs has the functions that templates may call. The functions that
+// depend on the page being rendered are replaced (with Funcs) before the
+// template is executed; these versions only exist so that templates can be
+// parsed and validated ahead of time
+var templateFuncs = template.FuncMap{
+	"bgWidth":        func() int { return 0 },
+	"formatDuration": formatDuration,
+	"percent":        func(f float64) int { return int(100*f + 0.5) },
+	"link":           func(string, time.Time) string { return "" },
+	"dayLink":        func(time.Time) string { return "" },
+	"themeCSS":       func() template.CSS { return "" },
+}
+
+// Templates loads the web UI's page templates. Each template is read from a
+// directory of overrides if it's there, and from the defaults compiled into
+// bindata.go otherwise. A nil *Templates only uses the defaults
+type Templates struct {
+	// The directory containing template overrides (and, in its 'static'
+	// subdirectory, static assets such as theme.css). May be empty
+	dir string
+
+	// If true, templates are re-read and re-parsed for every page, so that
+	// changes appear without restarting the server
+	dev bool
+
+	// Parsed templates, by name, and the theme stylesheet (unused in dev mode)
+	parsed map[string]*template.Template
+	theme  template.CSS
+}
+
+// LoadTemplates reads and parses every template (from 'dir' if it has an
+// override, or from the defaults), so that mistakes in overrides are reported
+// at startup rather than when a page is first loaded. 'dir' may be empty or
+// nonexistent, in which case only the defaults are used. If 'dev' is true,
+// templates are also re-read for every page
+func LoadTemplates(dir string, dev bool) (*Templates, error) {
+	t := &Templates{
+		dev:    dev,
+		parsed: make(map[string]*template.Template),
+	}
+	if dir != "" {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			t.dir = dir
+		} else if err == nil {
+			return nil, fmt.Errorf("template directory %s is not a directory", dir)
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("could not read template directory %s: %v", dir, err)
+		}
+	}
+
+	// Templates in 'dir' that don't override a default are almost certainly
+	// misnamed, and would be silently ignored
+	names := AssetNames()
+	sort.Strings(names)
+	if t.dir != "" {
+		overrides, err := filepath.Glob(filepath.Join(t.dir, "*.html.template"))
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range overrides {
+			if _, err := AssetInfo(filepath.Base(o)); err != nil {
+				return nil, fmt.Errorf("%s does not override any page's template "+
+					"(templates are: %s)", o, strings.Join(names, ", "))
+			}
+		}
+	}
+	for _, name := range names {
+		tmpl, err := t.parse(name)
+		if err != nil {
+			return nil, err
+		}
+		t.parsed[name] = tmpl
+	}
+	theme, err := t.readTheme()
+	if err != nil {
+		return nil, err
+	}
+	t.theme = theme
+	return t, nil
+}
+
+// parse reads and parses the template 'name'
+func (t *Templates) parse(name string) (*template.Template, error) {
+	var (
+		text   []byte
+		source = name + " (built in)"
+		err    error
+	)
+	if t != nil && t.dir != "" {
+		path := filepath.Join(t.dir, name)
+		text, err = ioutil.ReadFile(path)
+		if err == nil {
+			source = path
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("could not read template %s: %v", path, err)
+		}
+	}
+	if text == nil {
+		if text, err = Asset(name); err != nil {
+			return nil, fmt.Errorf("could not load %s: %v", name, err)
+		}
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %s: %v", source, err)
+	}
+	return tmpl, nil
+}
+
+// readTheme returns the contents of the theme stylesheet, or "" if there isn't
+// one
+func (t *Templates) readTheme() (template.CSS, error) {
+	if t == nil || t.dir == "" {
+		return "", nil
+	}
+	path := filepath.Join(t.dir, "static", themeFile)
+	css, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("could not read %s: %v", path, err)
+	}
+	return template.CSS(css), nil
+}
+
+// get returns the template 'name', ready to be executed with 'funcs' (which
+// replace the placeholders in templateFuncs)
+func (t *Templates) get(name string, funcs template.FuncMap) (*template.Template, error) {
+	var (
+		tmpl  *template.Template
+		theme template.CSS
+		err   error
+	)
+	if t == nil || t.dev {
+		if tmpl, err = t.parse(name); err != nil {
+			return nil, err
+		}
+		if theme, err = t.readTheme(); err != nil {
+			return nil, err
+		}
+	} else {
+		tmpl, theme = t.parsed[name], t.theme
+		if tmpl == nil {
+			return nil, fmt.Errorf("no template named %s", name)
+		}
+		// Each page replaces the template's functions, so pages can't share it
+		if tmpl, err = tmpl.Clone(); err != nil {
+			return nil, err
+		}
+	}
+	return tmpl.Funcs(funcs).Funcs(template.FuncMap{
+		"themeCSS": func() template.CSS { return theme },
+	}), nil
+}
+
+// execute renders the template 'name' with 'data' and 'funcs' to 'w', or
+// writes an error to 'w' if it can't
+func (t *Templates) execute(w http.ResponseWriter, name string, funcs template.FuncMap,
+	data interface{}) {
+	tmpl, err := t.get(name, funcs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// StaticHandler serves the files in the template directory's 'static'
+// subdirectory (e.g. images referenced by overridden templates)
+func (t *Templates) StaticHandler() http.Handler {
+	if t == nil || t.dir == "" {
+		return http.NotFoundHandler()
+	}
+	return http.FileServer(http.Dir(filepath.Join(t.dir, "static")))
+}
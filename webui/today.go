@@ -1,8 +1,11 @@
 package webui
 
 import (
+	"encoding/json"
+	"fmt"
 	"html/template"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/msteffen/golang-time-tracker/api"
@@ -10,10 +13,106 @@ import (
 
 type div struct {
 	Left, Width int
+	Label       string
+	Color       string // "#rrggbb"
+}
+
+// layoutDivs converts 'intervals' into divs positioned on a background that is
+// 'width' points wide and represents the 'span' starting at 'start' (seconds
+// since epoch), coloured by label. Used by every page that draws intervals as
+// bars
+func layoutDivs(intervals []api.Interval, start int64, span time.Duration, width float64,
+	colors api.LabelColors) []div {
+	spanSecs := span.Seconds()
+	divs := make([]div, 0, len(intervals))
+	for _, i := range intervals {
+		divs = append(divs, div{
+			Left:  int(width * float64(i.Start-start) / spanSecs),
+			Width: int(width * float64(i.End-i.Start) / spanSecs),
+			Label: i.Label,
+			Color: colors.Color(i.Label),
+		})
+	}
+	return divs
+}
+
+// legendEntry is one row in the legend below a page's bars
+type legendEntry struct {
+	Label string
+	Color string // "#rrggbb"
+	Total int64  // seconds spent on 'Label'
+}
+
+// legend returns the time spent on each label in 'intervals', most first
+func legend(intervals []api.Interval, colors api.LabelColors) []legendEntry {
+	totals := make(map[string]int64)
+	for _, i := range intervals {
+		totals[i.Label] += i.End - i.Start
+	}
+	entries := make([]legendEntry, 0, len(totals))
+	for label, total := range totals {
+		entries = append(entries, legendEntry{
+			Label: label,
+			Color: colors.Color(label),
+			Total: total,
+		})
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		if entries[a].Total != entries[b].Total {
+			return entries[a].Total > entries[b].Total
+		}
+		return entries[a].Label < entries[b].Label
+	})
+	return entries
+}
+
+// labelColors returns the colours of the labels in 'server'
+func labelColors(server api.APIServer) (api.LabelColors, error) {
+	resp, err := server.GetLabels()
+	if err != nil {
+		return nil, err
+	}
+	return api.NewLabelColors(resp.Labels), nil
+}
+
+// totalSecs returns the number of seconds covered by 'intervals'
+func totalSecs(intervals []api.Interval) int64 {
+	var total int64
+	for _, i := range intervals {
+		total += i.End - i.Start
+	}
+	return total
+}
+
+// todayData is the data rendered into today.html.template. It's also the
+// response to /today?format=json (and /day/<date>?format=json), which the page
+// fetches to update itself as new ticks arrive
+type todayData struct {
+	Divs   []div
+	Legend []legendEntry
+	// The day being rendered, as YYYY-MM-DD
+	Date string
+	// The start of the day, as seconds since epoch (the page reloads itself
+	// once the day is over)
+	Morning int64
+	// If true, the day being rendered is today, so the page updates itself as
+	// ticks arrive
+	Live bool
+	// Seconds worked in the day, up to the time the page was generated
+	DayTotal int64
+	// How fragmented the day's work was
+	Focus api.Focus
+	// If the last interval is still open, the number of seconds since its last
+	// tick (the page extends the interval until EndGap reaches MaxGap)
+	EndGap, MaxGap int64
+	BgWidth        float64
+	// Relative links to the previous/next day, today, and the web UI's root
+	// (e.g. "../" from /day/<date>, for /events and the calendar pages)
+	Prev, Next, Today, Root string
 }
 
 // TodayOp has all of the internal data structures retrieved/computed while
-// generating the /today page
+// generating the /today and /day/<date> pages
 type TodayOp struct {
 	//// Not Owned
 	// The 'server' that handles incoming requests
@@ -22,6 +121,8 @@ type TodayOp struct {
 	Clock api.Clock
 	// The http response writer that must receive the result of /today
 	Writer http.ResponseWriter
+	// The page's template (if nil, the built-in one)
+	Templates *Templates
 
 	//// Owned
 	// the set of intervals we request from 'server' and must render
@@ -30,58 +131,148 @@ type TodayOp struct {
 	divs []div
 	// The width of the result html page's background
 	BgWidth float64
+	// If true, render intervals at the wall-clock time at which they were
+	// recorded, rather than in Clock's time zone (see api.InRecordedZone)
+	InRecordedZone bool
+	// If true, write todayData as JSON instead of rendering the HTML page
+	AsJSON bool
+	// The day to render. If zero, today
+	Date time.Time
+	// The time zone whose midnights bound the day. If nil, Clock's
+	Location *time.Location
+	// The relative path from the page to the web UI's root (e.g. "../"), and
+	// the query string to carry over into links to other days
+	Root, Query string
+	// The start and end of the day being rendered
+	morning, night time.Time
+	// Seconds since the last tick, if the last interval is still open
+	endGap int64
+	// The colour of each label's intervals
+	colors api.LabelColors
 }
 
 func (t *TodayOp) Start() {
+	now := t.Clock.Now()
+	if t.Location == nil {
+		t.Location = now.Location()
+	}
+	if t.Date.IsZero() {
+		t.Date = now
+	}
+	t.morning = midnight(t.Date.In(t.Location))
+	// Days aren't always 24h long (e.g. DST transitions)
+	t.night = t.morning.AddDate(0, 0, 1)
 	t.getIntervals()
 }
 
+// live returns true if the day being rendered is today
+func (t *TodayOp) live() bool {
+	now := t.Clock.Now()
+	return !now.Before(t.morning) && now.Before(t.night)
+}
+
 // getIntervals generates 'div' structs indicating where "work" divs should be
 // placed (which indicate time when I was working)
 func (t *TodayOp) getIntervals() {
-	now := t.Clock.Now()
-	morning := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	result, err := t.Server.GetIntervals(&api.GetIntervalsRequest{
-		Start: morning.Unix(),
-		End:   morning.Add(24 * time.Hour).Unix(),
-	})
+	result, err := getDayIntervals(t.Server, t.morning, t.night, t.InRecordedZone, true)
 	if err != nil {
 		http.Error(t.Writer, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	t.intervals = result.Intervals
+	if t.live() {
+		t.endGap = result.EndGap
+	}
+	if t.colors, err = labelColors(t.Server); err != nil {
+		http.Error(t.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	t.computeDivs()
 }
 
+// getDayIntervals retrieves the intervals in [morning, night) from 'server'.
+// If 'inRecordedZone' is set, intervals are moved into the zone in which they
+// were recorded (see api.InRecordedZone), relative to morning's zone
+func getDayIntervals(server api.APIServer, morning, night time.Time, inRecordedZone,
+	byLabel bool) (*api.GetIntervalsResponse, error) {
+	req := &api.GetIntervalsRequest{
+		Start:   morning.Unix(),
+		End:     night.Unix(),
+		ByLabel: byLabel,
+	}
+	if inRecordedZone {
+		// Intervals outside of the day may be shifted into it
+		req.Start = morning.Add(-api.MaxZoneShift).Unix()
+		req.End = night.Add(api.MaxZoneShift).Unix()
+	}
+	result, err := server.GetIntervals(req)
+	if err != nil {
+		return nil, err
+	}
+	if inRecordedZone {
+		result.Intervals = api.ClampIntervals(
+			api.InRecordedZone(result.Intervals, morning.Location()), morning.Unix(), night.Unix())
+	}
+	return result, nil
+}
+
+// dayLink returns a relative link to the page for 'date'
+func (t *TodayOp) dayLink(date time.Time) string {
+	link := t.Root + "day/" + date.Format(DateFormat)
+	if t.Query != "" {
+		link += "?" + t.Query
+	}
+	return link
+}
+
 func (t *TodayOp) computeDivs() {
-	morning := func() int64 {
-		now := t.Clock.Now()
-		m := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-		return m.Unix()
-	}()
-	daySecs := (24 * time.Hour).Seconds()
-	t.divs = make([]div, 0, len(t.intervals))
-	for _, i := range t.intervals {
-		t.divs = append(t.divs, div{
-			Left:  int(t.BgWidth * float64(i.Start-morning) / daySecs),
-			Width: int(t.BgWidth * float64(i.End-i.Start) / daySecs),
-		})
+	t.divs = layoutDivs(t.intervals, t.morning.Unix(), t.night.Sub(t.morning), t.BgWidth, t.colors)
+	data := todayData{
+		Divs:     t.divs,
+		Legend:   legend(t.intervals, t.colors),
+		Date:     t.morning.Format(DateFormat),
+		Morning:  t.morning.Unix(),
+		Live:     t.live(),
+		DayTotal: totalSecs(t.intervals),
+		Focus:    api.ComputeFocus(t.intervals),
+		EndGap:   t.endGap,
+		MaxGap:   api.MaxEventGap,
+		BgWidth:  t.BgWidth,
+		Prev:     t.dayLink(t.morning.AddDate(0, 0, -1)),
+		Next:     t.dayLink(t.night),
+		Today:    t.Root + "today",
+		Root:     t.Root,
+	}
+	if t.Query != "" {
+		data.Today += "?" + t.Query
+	}
+	if t.AsJSON {
+		t.writeJSON(&data)
+	} else {
+		t.generateTemplate(&data)
 	}
-	t.generateTemplate()
 }
 
-func (t *TodayOp) generateTemplate() {
-	// Place generated divs into HTML template
-	data, err := Asset(`today.html.template`)
+func (t *TodayOp) writeJSON(data *todayData) {
+	dataJSON, err := json.Marshal(data)
 	if err != nil {
-		http.Error(t.Writer, "could not load today.html.template: "+err.Error(),
+		http.Error(t.Writer, "could not serialize result: "+err.Error(),
 			http.StatusInternalServerError)
-	}
-	err = template.Must(template.New("").Funcs(template.FuncMap{
-		"bgWidth": func() int { return int(t.BgWidth) },
-	}).Parse(string(data))).Execute(t.Writer, t.divs)
-	if err != nil {
-		http.Error(t.Writer, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	t.Writer.Header().Set("Content-Type", "application/json")
+	t.Writer.Write(dataJSON)
+}
+
+// formatDuration renders 'secs' like "3h 05m"
+func formatDuration(secs int64) string {
+	return fmt.Sprintf("%dh %02dm", secs/3600, (secs%3600)/60)
+}
+
+func (t *TodayOp) generateTemplate(data *todayData) {
+	// Place generated divs into HTML template
+	t.Templates.execute(t.Writer, "today.html.template", template.FuncMap{
+		"bgWidth":        func() int { return int(t.BgWidth) },
+		"formatDuration": formatDuration,
+	}, data)
 }